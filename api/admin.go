@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"web_music/api/providers"
+	"web_music/models"
+)
+
+// AdminUserRequest 定义管理员创建或修改用户的请求体
+type AdminUserRequest struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// AdminUsersHandler 列出所有用户(GET)或创建用户(POST)，仅管理员可用
+func AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		users, err := dataStore.Users()
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, users)
+
+	case "POST":
+		var req AdminUserRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		if req.Role == "" {
+			req.Role = models.RoleUser
+		}
+		user, err := dataStore.CreateUser(req.Username, req.Password, req.Role)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, user)
+
+	default:
+		http.Error(w, "仅支持GET和POST请求", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminUserHandler 修改用户的角色或密码(PATCH)，或删除用户(DELETE)，仅管理员可用
+func AdminUserHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "PUT, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	switch r.Method {
+	case "PUT", "PATCH":
+		var req AdminUserRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		user, err := dataStore.UpdateUser(id, req.Role, req.Password)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, user)
+
+	case "DELETE":
+		if err := dataStore.DeleteUser(id); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "仅支持PATCH和DELETE请求", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminSourceHandler 启用或禁用音乐源(PUT)，设置会保存到数据库，仅管理员可用
+func AdminSourceHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "PUT, PATCH, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "PUT" && r.Method != "PATCH" {
+		http.Error(w, "仅支持PUT请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Enabled == nil {
+		http.Error(w, "缺少必要参数", http.StatusBadRequest)
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := providers.SetEnabled(name, *req.Enabled); err != nil {
+		if errors.Is(err, providers.ErrUnknownProvider) {
+			http.Error(w, ErrUnsupportedProvider.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 只保存管理员的设置，环境变量DISABLED_SOURCES在启动时另外应用
+	if err := dataStore.SetSourceDisabled(name, !*req.Enabled); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	var info SourceInfo
+	for _, p := range providers.All() {
+		if p.Name() == name {
+			info = SourceInfo{Name: name, DisplayName: providers.DisplayName(p), Enabled: providers.IsEnabled(name)}
+		}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
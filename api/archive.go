@@ -0,0 +1,238 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"web_music/api/providers"
+	"web_music/models"
+	"web_music/playlist"
+)
+
+// 打包下载歌单的设置
+const (
+	archiveWorkers      = 3               // 同时下载的歌曲数量，也是临时文件的最大数量
+	archiveTrackTimeout = 5 * time.Minute // 单首歌曲的下载超时
+)
+
+// archiveTrack 表示已下载到临时文件的歌曲，下载失败时Err不为空
+type archiveTrack struct {
+	Path string
+	Ext  string
+	Err  error
+}
+
+// PlaylistDownloadHandler 将歌单中的歌曲打包为ZIP下载，文件名为 "序号 - 歌手 - 标题.扩展名"
+// 压缩包中附带M3U8歌单，无法下载的歌曲记录在errors.txt中
+func PlaylistDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 仅支持GET请求
+	if r.Method != "GET" {
+		http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	quality, ok := providers.ParseQuality(r.URL.Query().Get("quality"))
+	if !ok {
+		http.Error(w, ErrInvalidQuality.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := dataStore.Playlist(user.ID, r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": playlistFilename(p) + ".zip",
+	}))
+
+	if err := writePlaylistArchive(r.Context(), w, p, quality); err != nil {
+		// 响应已经开始发送，只能中断连接
+		log.Printf("打包歌单 %s 失败: %v", p.ID, err)
+	}
+}
+
+// writePlaylistArchive 并发下载歌曲并按歌单顺序写入ZIP
+// 最多同时保留archiveWorkers个下载完成但未写入的临时文件
+func writePlaylistArchive(ctx context.Context, w io.Writer, p *models.UserPlaylist, quality providers.Quality) error {
+	results := make([]chan archiveTrack, len(p.Songs))
+	for i := range results {
+		results[i] = make(chan archiveTrack, 1)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	next := 0
+	defer func() {
+		// 提前结束时等待进行中的下载，删除已下载但未写入的临时文件
+		cancel()
+		go func() {
+			wg.Wait()
+			for _, result := range results[next:] {
+				select {
+				case track := <-result:
+					if track.Path != "" {
+						os.Remove(track.Path)
+					}
+				default:
+				}
+			}
+		}()
+	}()
+
+	sem := make(chan struct{}, archiveWorkers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i, song := range p.Songs {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] <- archiveDownload(ctx, song, quality)
+			}()
+		}
+	}()
+
+	zw := zip.NewWriter(w)
+	width := max(2, len(strconv.Itoa(len(p.Songs))))
+	var entries []playlist.Entry
+	var failures strings.Builder
+
+	for i, song := range p.Songs {
+		var track archiveTrack
+		select {
+		case track = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		prefix := fmt.Sprintf("%0*d", width, i+1)
+		next = i + 1
+		if track.Err != nil {
+			log.Printf("打包下载 %s:%s 失败: %v", song.Source, song.ID, track.Err)
+			fmt.Fprintf(&failures, "%s - %s (%s:%s): %v\r\n", prefix, playlist.DisplayTitle(song), song.Source, song.ID, track.Err)
+			<-sem
+			continue
+		}
+
+		name := prefix + " - " + trackFilename(song, track.Ext)
+		err := addArchiveFile(zw, name, track.Path)
+		os.Remove(track.Path)
+		<-sem
+		if err != nil {
+			return err
+		}
+		entries = append(entries, playlist.Entry{Song: song, URL: name})
+	}
+	next = len(p.Songs)
+
+	var m3u bytes.Buffer
+	playlist.WriteM3U8(&m3u, p.Name, entries)
+	if err := addArchiveBytes(zw, playlistFilename(p)+".m3u8", m3u.Bytes()); err != nil {
+		return err
+	}
+	if failures.Len() > 0 {
+		if err := addArchiveBytes(zw, "errors.txt", []byte(failures.String())); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// playlistFilename 返回歌单的文件名，不含扩展名
+func playlistFilename(p *models.UserPlaylist) string {
+	if name := safeFilename(p.Name); name != "" {
+		return name
+	}
+	return "playlist"
+}
+
+// archiveDownload 打包时下载单首歌曲，测试时替换
+var archiveDownload = downloadToTemp
+
+// downloadToTemp 下载写入标签后的歌曲到临时文件
+func downloadToTemp(ctx context.Context, song models.Song, quality providers.Quality) archiveTrack {
+	ctx, cancel := context.WithTimeout(ctx, archiveTrackTimeout)
+	defer cancel()
+
+	track, err := openTrack(ctx, song, quality)
+	if err != nil {
+		return archiveTrack{Err: err}
+	}
+	defer track.Close()
+
+	f, err := os.CreateTemp("", "web_music-*."+track.Ext)
+	if err != nil {
+		return archiveTrack{Err: fmt.Errorf("创建临时文件失败: %w", err)}
+	}
+	_, err = io.Copy(f, track)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return archiveTrack{Err: fmt.Errorf("下载音频失败: %w", err)}
+	}
+	return archiveTrack{Path: f.Name(), Ext: track.Ext}
+}
+
+// addArchiveFile 将文件不压缩地写入ZIP，音频本身已经是压缩格式
+func addArchiveFile(zw *zip.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, f)
+	return err
+}
+
+// addArchiveBytes 将文本内容压缩后写入ZIP
+func addArchiveBytes(zw *zip.Writer, name string, data []byte) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"web_music/api/providers"
+	"web_music/models"
+)
+
+// setArchiveDownload 替换打包时下载歌曲的函数，测试结束后恢复
+func setArchiveDownload(t *testing.T, download func(ctx context.Context, song models.Song, quality providers.Quality) archiveTrack) {
+	t.Helper()
+	old := archiveDownload
+	archiveDownload = download
+	t.Cleanup(func() { archiveDownload = old })
+}
+
+// fakeTrack 将歌曲ID作为音频内容写入dir中的临时文件
+func fakeTrack(dir string, song models.Song) archiveTrack {
+	path := filepath.Join(dir, song.ID+".mp3")
+	if err := os.WriteFile(path, []byte("audio-"+song.ID), 0o644); err != nil {
+		return archiveTrack{Err: err}
+	}
+	return archiveTrack{Path: path, Ext: "mp3"}
+}
+
+// readArchive 按顺序返回ZIP中的文件名和内容
+func readArchive(t *testing.T, data []byte) ([]string, map[string]string) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+		contents[f.Name] = string(b)
+	}
+	return names, contents
+}
+
+func TestWritePlaylistArchiveOrder(t *testing.T) {
+	dir := t.TempDir()
+	p := &models.UserPlaylist{ID: "1", Name: "我的歌单", Songs: []models.Song{
+		{ID: "a", Source: "qq", Title: "晴天", Artist: "周杰伦"},
+		{ID: "b", Source: "qq", Title: "七里香", Artist: "周杰伦"},
+		{ID: "c", Source: "netease", Title: "A/B"},
+	}}
+	// 后面的歌曲先下载完成
+	setArchiveDownload(t, func(ctx context.Context, song models.Song, quality providers.Quality) archiveTrack {
+		time.Sleep(time.Duration('d'-song.ID[0]) * 20 * time.Millisecond)
+		return fakeTrack(dir, song)
+	})
+
+	var buf bytes.Buffer
+	if err := writePlaylistArchive(context.Background(), &buf, p, providers.QualityStandard); err != nil {
+		t.Fatal(err)
+	}
+
+	names, contents := readArchive(t, buf.Bytes())
+	want := []string{"01 - 周杰伦 - 晴天.mp3", "02 - 周杰伦 - 七里香.mp3", "03 - A_B.mp3", "我的歌单.m3u8"}
+	if strings.Join(names, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("文件 = %q, want %q", names, want)
+	}
+	for i, id := range []string{"a", "b", "c"} {
+		if contents[want[i]] != "audio-"+id {
+			t.Errorf("%s 的内容 = %q", want[i], contents[want[i]])
+		}
+	}
+	if m3u := contents["我的歌单.m3u8"]; !strings.Contains(m3u, "#EXTINF:-1,周杰伦 - 晴天\n01 - 周杰伦 - 晴天.mp3\n") {
+		t.Errorf("歌单 = %q", m3u)
+	}
+
+	// 写入后删除临时文件
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("剩余 %d 个临时文件", len(files))
+	}
+}
+
+func TestWritePlaylistArchiveConcurrency(t *testing.T) {
+	dir := t.TempDir()
+	p := &models.UserPlaylist{Name: "list"}
+	for i := 0; i < 12; i++ {
+		p.Songs = append(p.Songs, models.Song{ID: string(rune('a' + i)), Source: "qq", Title: "歌曲"})
+	}
+
+	var mu sync.Mutex
+	started := make(map[string]string) // 已开始下载的歌曲，下载完成后为临时文件路径
+	maxPending := 0
+	setArchiveDownload(t, func(ctx context.Context, song models.Song, quality providers.Quality) archiveTrack {
+		mu.Lock()
+		// 下载中的歌曲和下载完成但还未写入的临时文件
+		pending := 1
+		for _, path := range started {
+			if path == "" {
+				pending++
+			} else if _, err := os.Stat(path); err == nil {
+				pending++
+			}
+		}
+		maxPending = max(maxPending, pending)
+		started[song.ID] = ""
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		track := fakeTrack(dir, song)
+
+		mu.Lock()
+		started[song.ID] = track.Path
+		mu.Unlock()
+		return track
+	})
+
+	var buf bytes.Buffer
+	if err := writePlaylistArchive(context.Background(), &buf, p, providers.QualityStandard); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := readArchive(t, buf.Bytes()); len(names) != len(p.Songs)+1 {
+		t.Errorf("文件数量 = %d, want %d", len(names), len(p.Songs)+1)
+	}
+	if maxPending > archiveWorkers {
+		t.Errorf("同时下载和等待写入的歌曲数 = %d, 超过 %d", maxPending, archiveWorkers)
+	}
+	if maxPending < 2 {
+		t.Errorf("同时下载的歌曲数 = %d, 没有并发下载", maxPending)
+	}
+}
+
+func TestWritePlaylistArchiveSkipsFailures(t *testing.T) {
+	dir := t.TempDir()
+	p := &models.UserPlaylist{Name: "list", Songs: []models.Song{
+		{ID: "a", Source: "qq", Title: "晴天", Artist: "周杰伦"},
+		{ID: "b", Source: "kuwo", Title: "下架歌曲"},
+		{ID: "c", Source: "qq", Title: "稻香", Artist: "周杰伦"},
+	}}
+	setArchiveDownload(t, func(ctx context.Context, song models.Song, quality providers.Quality) archiveTrack {
+		if song.ID == "b" {
+			return archiveTrack{Err: errors.New("无法获取播放地址")}
+		}
+		return fakeTrack(dir, song)
+	})
+
+	var buf bytes.Buffer
+	if err := writePlaylistArchive(context.Background(), &buf, p, providers.QualityStandard); err != nil {
+		t.Fatal(err)
+	}
+
+	names, contents := readArchive(t, buf.Bytes())
+	want := []string{"01 - 周杰伦 - 晴天.mp3", "03 - 周杰伦 - 稻香.mp3", "list.m3u8", "errors.txt"}
+	if strings.Join(names, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("文件 = %q, want %q", names, want)
+	}
+	if got := contents["errors.txt"]; got != "02 - 下架歌曲 (kuwo:b): 无法获取播放地址\r\n" {
+		t.Errorf("errors.txt = %q", got)
+	}
+	if m3u := contents["list.m3u8"]; strings.Contains(m3u, "下架歌曲") || !strings.Contains(m3u, "03 - 周杰伦 - 稻香.mp3") {
+		t.Errorf("歌单 = %q", m3u)
+	}
+}
@@ -0,0 +1,354 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"web_music/models"
+	"web_music/store"
+)
+
+// 登录会话的Cookie名称
+const sessionCookieName = "web_music_session"
+
+// ErrUnauthorized 未登录或登录已失效
+var ErrUnauthorized = errors.New("请先登录")
+
+// ErrForbidden 需要管理员权限
+var ErrForbidden = errors.New("需要管理员权限")
+
+// AuthOptions 定义用户认证相关的设置
+type AuthOptions struct {
+	RequireLogin      bool // 是否所有接口都需要登录
+	AllowRegistration bool // 是否允许自行注册，关闭时只有管理员可以创建用户
+}
+
+// authOptions 当前的认证设置
+var authOptions AuthOptions
+
+// SetAuthOptions 设置用户认证选项
+func SetAuthOptions(opts AuthOptions) {
+	authOptions = opts
+}
+
+// AuthRequest 定义注册和登录的请求体
+type AuthRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AuthStatus 定义当前的登录状态
+type AuthStatus struct {
+	User              *models.User `json:"user"`
+	SetupRequired     bool         `json:"setupRequired"` // 还没有用户，需要创建管理员
+	AllowRegistration bool         `json:"allowRegistration"`
+	RequireLogin      bool         `json:"requireLogin"`
+}
+
+// TokenResponse 定义创建API令牌的响应，令牌只返回这一次
+type TokenResponse struct {
+	models.APIToken
+	Token string `json:"token"`
+}
+
+// AuthGate 在要求登录时拦截未登录用户对API的访问，登录相关接口除外
+func AuthGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authOptions.RequireLogin && r.Method != "OPTIONS" &&
+			strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/api/auth/") {
+			if _, ok := requireUser(w, r); !ok {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RegisterHandler 注册用户，第一个用户自动成为管理员
+func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if !handleAuthPreflight(w, r) {
+		return
+	}
+
+	count, err := dataStore.UserCount()
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if count > 0 && !authOptions.AllowRegistration {
+		http.Error(w, "未开放注册，请联系管理员创建账号", http.StatusForbidden)
+		return
+	}
+
+	var req AuthRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	user, err := dataStore.CreateUser(req.Username, req.Password, models.RoleUser)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	// 注册后直接登录
+	if !startSession(w, r, user) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// LoginHandler 使用用户名和密码登录，成功后设置会话Cookie
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if !handleAuthPreflight(w, r) {
+		return
+	}
+
+	var req AuthRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	user, err := dataStore.Authenticate(req.Username, req.Password)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if !startSession(w, r, user) {
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// LogoutHandler 退出登录并删除会话
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if !handleAuthPreflight(w, r) {
+		return
+	}
+
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := dataStore.DeleteSession(cookie.Value); err != nil {
+			log.Printf("删除登录会话失败: %v", err)
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MeHandler 返回当前登录的用户和服务器的认证设置
+func MeHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 仅支持GET请求
+	if r.Method != "GET" {
+		http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if dataStore == nil {
+		http.Error(w, ErrStoreUnavailable.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	count, err := dataStore.UserCount()
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, AuthStatus{
+		User:              currentUser(r),
+		SetupRequired:     count == 0,
+		AllowRegistration: count == 0 || authOptions.AllowRegistration,
+		RequireLogin:      authOptions.RequireLogin,
+	})
+}
+
+// TokensHandler 列出(GET)、创建(POST)或吊销(DELETE)当前用户的API令牌
+func TokensHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		tokens, err := dataStore.Tokens(user.ID)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, tokens)
+
+	case "POST":
+		var req struct {
+			Name string `json:"name"`
+		}
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		token, info, err := dataStore.CreateToken(user.ID, req.Name)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, TokenResponse{APIToken: *info, Token: token})
+
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "缺少必要参数", http.StatusBadRequest)
+			return
+		}
+		if err := dataStore.DeleteToken(user.ID, id); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "仅支持GET、POST和DELETE请求", http.StatusMethodNotAllowed)
+	}
+}
+
+// currentUser 根据请求中的API令牌或会话Cookie获取当前用户，未登录时返回nil
+func currentUser(r *http.Request) *models.User {
+	if dataStore == nil {
+		return nil
+	}
+
+	token := ""
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	} else if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		token = cookie.Value
+	}
+	if token == "" {
+		return nil
+	}
+
+	user, err := dataStore.UserByToken(token)
+	if err != nil {
+		if !errors.Is(err, store.ErrInvalidToken) {
+			log.Printf("校验登录状态失败: %v", err)
+		}
+		return nil
+	}
+	return user
+}
+
+// requireUser 获取当前用户，未登录时写入错误响应并返回false
+func requireUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	if dataStore == nil {
+		http.Error(w, ErrStoreUnavailable.Error(), http.StatusServiceUnavailable)
+		return nil, false
+	}
+	user := currentUser(r)
+	if user == nil {
+		http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+// requireAdmin 获取当前用户并确认是管理员，否则写入错误响应并返回false
+func requireAdmin(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !user.IsAdmin() {
+		http.Error(w, ErrForbidden.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
+
+// handleAuthPreflight 处理登录类接口的跨域头、预检请求和请求方法，返回false时表示已写入响应
+func handleAuthPreflight(w http.ResponseWriter, r *http.Request) bool {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return false
+	}
+
+	// 仅支持POST请求
+	if r.Method != "POST" {
+		http.Error(w, "仅支持POST请求", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	if dataStore == nil {
+		http.Error(w, ErrStoreUnavailable.Error(), http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// startSession 创建登录会话并设置Cookie，失败时写入错误响应并返回false
+func startSession(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	token, err := dataStore.CreateSession(user.ID)
+	if err != nil {
+		writeStoreError(w, err)
+		return false
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(store.SessionTTL),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return true
+}
+
+// decodeJSONBody 解析JSON请求体，失败时写入错误响应并返回false
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPlaylistBodySize)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "请求体格式错误", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
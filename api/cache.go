@@ -0,0 +1,148 @@
+package api
+
+import (
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"web_music/api/providers"
+	"web_music/audiocache"
+)
+
+// audioCache 本地音频缓存，为空时不缓存
+var audioCache *audiocache.Cache
+
+// SetAudioCache 设置播放和下载使用的本地音频缓存
+func SetAudioCache(c *audiocache.Cache) {
+	audioCache = c
+}
+
+// audioCacheKey 返回歌曲的缓存键，缓存中的音频与键中的音乐源、ID和音质完全对应
+func audioCacheKey(source, id string, quality providers.Quality) audiocache.Key {
+	return audiocache.Key{Source: source, ID: id, Quality: string(quality)}
+}
+
+// resolvedCacheKey 返回实际获取到的音频的缓存键，回退到其他音乐源或降低音质时与请求的不同
+// 上游没有返回实际音质时无法确定缓存的内容，返回空
+func resolvedCacheKey(resolved *resolveResult) *audiocache.Key {
+	if resolved.Quality == "" {
+		return nil
+	}
+	key := audioCacheKey(resolved.Source, resolved.ID, resolved.Quality)
+	return &key
+}
+
+// openCachedAudio 从缓存中打开歌曲，未启用缓存或未命中时返回false
+func openCachedAudio(key audiocache.Key) (*os.File, string, bool) {
+	if audioCache == nil {
+		return nil, "", false
+	}
+	return audioCache.Open(key)
+}
+
+// serveCachedAudio 发送缓存的音频文件，支持Range请求
+func serveCachedAudio(w http.ResponseWriter, r *http.Request, f *os.File, ext string) {
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "读取缓存失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", audioContentType("", "audio."+ext))
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("X-Cache", "HIT")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// cachingBody 读取上游音频的同时写入缓存，读到结尾时保存，中途关闭时丢弃
+type cachingBody struct {
+	io.ReadCloser
+	cache *audiocache.Writer
+	eof   bool
+}
+
+// newCachingBody 为上游的完整音频响应创建带缓存的响应体，未启用缓存时原样返回
+func newCachingBody(key audiocache.Key, resp *http.Response, audioURL string) io.ReadCloser {
+	if audioCache == nil {
+		return resp.Body
+	}
+
+	ext := audioExt(audioContentType(resp.Header.Get("Content-Type"), audioURL))
+	cw, err := audioCache.Create(key, ext)
+	if err != nil {
+		log.Printf("创建音频缓存失败: %v", err)
+		return resp.Body
+	}
+	return &cachingBody{ReadCloser: resp.Body, cache: cw}
+}
+
+// Read 读取音频数据并写入缓存
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.cache.Write(p[:n])
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+// Close 关闭上游响应，完整读取时保存缓存
+func (b *cachingBody) Close() error {
+	if b.eof {
+		if err := b.cache.Commit(); err != nil {
+			log.Printf("保存音频缓存失败: %v", err)
+		}
+	} else {
+		b.cache.Abort()
+	}
+	return b.ReadCloser.Close()
+}
+
+// isCompleteAudio 判断上游响应是否包含完整的音频文件
+func isCompleteAudio(resp *http.Response, rangeHeader string) bool {
+	if resp.StatusCode == http.StatusOK {
+		return rangeHeader == ""
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return false
+	}
+
+	// 形如 bytes 0-999/1000 的范围包含完整文件
+	spec, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes 0-")
+	if !ok {
+		return false
+	}
+	end, total, ok := strings.Cut(spec, "/")
+	if !ok {
+		return false
+	}
+	endN, err1 := strconv.ParseInt(end, 10, 64)
+	totalN, err2 := strconv.ParseInt(total, 10, 64)
+	return err1 == nil && err2 == nil && endN+1 == totalN
+}
+
+// audioExt 根据音频的Content-Type返回缓存文件的扩展名
+func audioExt(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	case "audio/flac", "audio/x-flac":
+		return "flac"
+	case "audio/mp4", "audio/x-m4a", "audio/m4a":
+		return "m4a"
+	case "audio/ogg":
+		return "ogg"
+	case "audio/aac":
+		return "aac"
+	case "audio/wav", "audio/x-wav":
+		return "wav"
+	}
+	return "bin"
+}
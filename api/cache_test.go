@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"web_music/api/providers"
+)
+
+func TestIsCompleteAudio(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		contentRange string
+		rangeHeader  string
+		want         bool
+	}{
+		{"完整响应", http.StatusOK, "", "", true},
+		{"请求了范围但上游返回完整文件", http.StatusOK, "", "bytes=100-", false},
+		{"范围覆盖整个文件", http.StatusPartialContent, "bytes 0-999/1000", "bytes=0-", true},
+		{"范围不完整", http.StatusPartialContent, "bytes 0-499/1000", "bytes=0-499", false},
+		{"不从0开始", http.StatusPartialContent, "bytes 1-999/1000", "bytes=1-", false},
+		{"总大小未知", http.StatusPartialContent, "bytes 0-999/*", "bytes=0-", false},
+		{"缺少Content-Range", http.StatusPartialContent, "", "bytes=0-", false},
+		{"格式错误", http.StatusPartialContent, "bytes 0-abc/1000", "bytes=0-", false},
+		{"错误状态码", http.StatusNotFound, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.contentRange != "" {
+				resp.Header.Set("Content-Range", tt.contentRange)
+			}
+			if got := isCompleteAudio(resp, tt.rangeHeader); got != tt.want {
+				t.Errorf("isCompleteAudio() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvedCacheKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolved resolveResult
+		want     string // 缓存键中的音乐源/ID/音质，为空表示不缓存
+	}{
+		{
+			name:     "请求的音质",
+			resolved: resolveResult{SongURL: providers.SongURL{Quality: providers.QualityLossless}, Source: "qq", ID: "1"},
+			want:     "qq/1/" + string(providers.QualityLossless),
+		},
+		{
+			name:     "回退到其他音乐源并降低音质",
+			resolved: resolveResult{SongURL: providers.SongURL{Quality: providers.QualityStandard}, Source: "kuwo", ID: "2", Fallback: true},
+			want:     "kuwo/2/" + string(providers.QualityStandard),
+		},
+		{
+			name:     "音质未知",
+			resolved: resolveResult{Source: "qq", ID: "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := resolvedCacheKey(&tt.resolved)
+			got := ""
+			if key != nil {
+				got = key.Source + "/" + key.ID + "/" + key.Quality
+			}
+			if got != tt.want {
+				t.Errorf("resolvedCacheKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioExt(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"audio/mpeg", "mp3"},
+		{"audio/flac; charset=binary", "flac"},
+		{"audio/x-m4a", "m4a"},
+		{"application/octet-stream", "bin"},
+		{"", "bin"},
+	}
+	for _, tt := range tests {
+		if got := audioExt(tt.contentType); got != tt.want {
+			t.Errorf("audioExt(%q) = %q, want %q", tt.contentType, got, tt.want)
+		}
+	}
+}
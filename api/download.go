@@ -0,0 +1,376 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"web_music/api/providers"
+	"web_music/lyrics"
+	"web_music/models"
+	"web_music/playlist"
+	"web_music/tagging"
+)
+
+// 下载歌曲时获取歌曲信息、封面和歌词的超时时间
+const downloadMetadataTimeout = 20 * time.Second
+
+// 封面图片的大小上限
+const maxCoverSize = 5 << 20
+
+// 下载封面时最多跟随的跳转次数
+const maxCoverRedirects = 5
+
+// coverClient 下载封面使用的HTTP客户端，跳转后的地址同样需要通过检查
+var coverClient = &http.Client{
+	Transport: providers.StreamTransport(),
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxCoverRedirects {
+			return fmt.Errorf("跳转次数超过 %d 次", maxCoverRedirects)
+		}
+		return checkCoverURL(req.Context(), req.URL)
+	},
+}
+
+// downloadTrack 表示一首写入了标签的歌曲文件
+type downloadTrack struct {
+	io.Reader
+	closer      io.Closer
+	Ext         string // 文件扩展名，不含点
+	ContentType string
+	Size        int64 // 文件大小，未知时为-1
+}
+
+// Close 关闭上游响应
+func (t *downloadTrack) Close() error {
+	return t.closer.Close()
+}
+
+// DownloadHandler 下载歌曲，MP3写入ID3v2.4标签，FLAC写入Vorbis注释，包括封面和歌词
+// 标题、歌手、专辑、封面可以通过参数提供，未提供标题时从音乐源获取歌曲信息
+// 封面只从音乐源的图片域名下载，其他地址会被忽略
+func DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Disposition")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 仅支持GET请求
+	if r.Method != "GET" {
+		http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 获取查询参数
+	query := r.URL.Query()
+	song := models.Song{
+		ID:     query.Get("id"),
+		Source: query.Get("source"),
+		Title:  query.Get("title"),
+		Artist: query.Get("artist"),
+		Album:  query.Get("album"),
+		Cover:  query.Get("cover"),
+	}
+	song.Duration, _ = strconv.Atoi(query.Get("duration"))
+
+	if song.ID == "" || song.Source == "" {
+		http.Error(w, "缺少必要参数", http.StatusBadRequest)
+		return
+	}
+	if _, ok := providers.Get(song.Source); !ok {
+		http.Error(w, ErrUnsupportedProvider.Error(), http.StatusBadRequest)
+		return
+	}
+
+	quality, ok := providers.ParseQuality(query.Get("quality"))
+	if !ok {
+		http.Error(w, ErrInvalidQuality.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if song.Title == "" {
+		ctx, cancel := context.WithTimeout(r.Context(), downloadMetadataTimeout)
+		fillSongInfo(ctx, &song)
+		cancel()
+	}
+
+	track, err := openTrack(r.Context(), song, quality)
+	if err != nil {
+		log.Printf("下载歌曲 %s:%s 失败: %v", song.Source, song.ID, err)
+		http.Error(w, "下载歌曲失败", http.StatusBadGateway)
+		return
+	}
+	defer track.Close()
+
+	w.Header().Set("Content-Type", track.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": trackFilename(song, track.Ext),
+	}))
+	if track.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(track.Size, 10))
+	}
+
+	if _, err := io.Copy(w, track); err != nil {
+		log.Printf("发送歌曲 %s:%s 中断: %v", song.Source, song.ID, err)
+	}
+}
+
+// fillSongInfo 从音乐源获取歌曲的标题、歌手、专辑和封面，获取失败时保持原样
+func fillSongInfo(ctx context.Context, song *models.Song) {
+	provider, ok := providers.Get(song.Source)
+	if !ok {
+		return
+	}
+	importer, ok := provider.(providers.Importer)
+	if !ok {
+		return
+	}
+
+	result, err := importer.Import(ctx, providers.ImportTarget{Kind: providers.ImportSong, ID: song.ID})
+	if err != nil || len(result.Songs) == 0 {
+		log.Printf("获取歌曲 %s:%s 的信息失败: %v", song.Source, song.ID, err)
+		return
+	}
+
+	info := result.Songs[0]
+	song.Title = info.Title
+	if song.Artist == "" {
+		song.Artist = info.Artist
+	}
+	if song.Album == "" {
+		song.Album = info.Album
+	}
+	if song.Cover == "" {
+		song.Cover = info.Cover
+	}
+	if song.Duration == 0 {
+		song.Duration = info.Duration
+	}
+}
+
+// openTrack 解析歌曲URL并请求音频，返回写入标签后的音频数据
+// 优先使用本地缓存，原音乐源不可用时回退到其他音乐源，歌词也从实际提供音频的音乐源获取
+func openTrack(ctx context.Context, song models.Song, quality providers.Quality) (*downloadTrack, error) {
+	metaCtx, cancel := context.WithTimeout(ctx, downloadMetadataTimeout)
+	defer cancel()
+
+	if f, ext, ok := openCachedAudio(audioCacheKey(song.Source, song.ID, quality)); ok {
+		provider, _ := providers.Get(song.Source)
+		return openCachedTrack(f, ext, trackMetadata(metaCtx, song, provider, song.ID))
+	}
+
+	resolved, err := resolveWithFallback(metaCtx, song.ID, song.Source, song.Title, song.Artist, song.Duration, quality)
+	if err != nil {
+		return nil, fmt.Errorf("获取歌曲URL失败: %w", err)
+	}
+	provider, ok := providers.Get(resolved.Source)
+	if !ok {
+		return nil, ErrUnsupportedProvider
+	}
+
+	meta := trackMetadata(metaCtx, song, provider, resolved.ID)
+
+	// 降低音质或回退到其他音乐源时，实际获取到的音频可能已经缓存
+	cacheKey := resolvedCacheKey(resolved)
+	if cacheKey != nil {
+		if f, ext, ok := openCachedAudio(*cacheKey); ok {
+			return openCachedTrack(f, ext, meta)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", resolved.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建音频请求失败: %w", err)
+	}
+	for key, values := range providers.StreamHeaders(provider) {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求音频失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		invalidateSongURL(resolved.ID, resolved.Source, quality)
+		return nil, fmt.Errorf("上游音频返回错误状态码: %d", resp.StatusCode)
+	}
+
+	// 缓存中保存的是未写入标签的原始音频
+	body := resp.Body
+	if cacheKey != nil {
+		body = newCachingBody(*cacheKey, resp, resolved.URL)
+	}
+	ext := resolved.Format
+	if ext == "" {
+		ext = audioExt(audioContentType(resp.Header.Get("Content-Type"), resolved.URL))
+	}
+	return newDownloadTrack(body, resp.ContentLength, ext, audioContentType(resp.Header.Get("Content-Type"), resolved.URL), meta)
+}
+
+// openCachedTrack 为缓存的音频写入标签
+func openCachedTrack(f *os.File, ext string, meta *tagging.Metadata) (*downloadTrack, error) {
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("读取缓存失败: %w", err)
+	}
+	return newDownloadTrack(f, info.Size(), ext, audioContentType("", "audio."+ext), meta)
+}
+
+// newDownloadTrack 为音频数据写入标签，size为原始音频的大小，未知时为-1
+// ext和contentType在格式不支持写入标签时使用
+func newDownloadTrack(body io.ReadCloser, size int64, ext, contentType string, meta *tagging.Metadata) (*downloadTrack, error) {
+	tagged, format, delta, err := tagging.Apply(body, meta)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("写入标签失败: %w", err)
+	}
+
+	track := &downloadTrack{
+		Reader:      tagged,
+		closer:      body,
+		Ext:         format,
+		ContentType: contentType,
+		Size:        -1,
+	}
+	switch format {
+	case tagging.FormatMP3:
+		track.ContentType = "audio/mpeg"
+	case tagging.FormatFLAC:
+		track.ContentType = "audio/flac"
+	default:
+		// 不支持写入标签的格式原样下载
+		track.Ext = ext
+	}
+	if size >= 0 {
+		track.Size = size + delta
+	}
+	return track, nil
+}
+
+// trackMetadata 生成写入音频文件的标签，封面和歌词获取失败时跳过
+func trackMetadata(ctx context.Context, song models.Song, provider providers.Provider, id string) *tagging.Metadata {
+	meta := &tagging.Metadata{
+		Title:  song.Title,
+		Artist: song.Artist,
+		Album:  song.Album,
+	}
+
+	if song.Cover != "" {
+		cover, mimeType, err := fetchCover(ctx, song.Cover)
+		if err != nil {
+			log.Printf("获取封面 %s 失败: %v", song.Cover, err)
+		} else {
+			meta.Cover = cover
+			meta.CoverMIME = mimeType
+		}
+	}
+
+	// 音乐源被禁用时provider为空，不获取歌词
+	if lp, ok := provider.(providers.LyricsProvider); ok {
+		result, err := lp.Lyrics(ctx, id)
+		if err != nil {
+			log.Printf("从 %s 获取歌词失败: %v", provider.Name(), err)
+		} else {
+			lyrics.Parse(result)
+			meta.Lyrics = result.Lines
+		}
+	}
+
+	return meta
+}
+
+// fetchCover 下载封面图片，只接受JPEG和PNG
+func fetchCover(ctx context.Context, coverURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", coverURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := checkCoverURL(ctx, req.URL); err != nil {
+		return nil, "", err
+	}
+	resp, err := coverClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("返回错误状态码: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxCoverSize {
+		return nil, "", fmt.Errorf("图片超过 %d 字节", maxCoverSize)
+	}
+
+	mimeType := http.DetectContentType(data)
+	if mimeType != "image/jpeg" && mimeType != "image/png" {
+		return nil, "", fmt.Errorf("不支持的图片类型: %s", mimeType)
+	}
+	return data, mimeType, nil
+}
+
+// checkCoverURL 检查封面地址，只允许音乐源的图片域名，并且不能解析到内网或本机地址
+func checkCoverURL(ctx context.Context, u *url.URL) error {
+	if !providers.IsImageURL(u) {
+		return fmt.Errorf("不是音乐源的图片地址: %s", u.Redacted())
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("解析图片地址失败: %w", err)
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("%s 解析到非公网地址 %s", u.Hostname(), addr.IP)
+		}
+	}
+	return nil
+}
+
+// isPublicIP 判断是否为公网单播地址
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// trackFilename 返回 "歌手 - 标题.扩展名" 形式的文件名
+func trackFilename(song models.Song, ext string) string {
+	name := playlist.DisplayTitle(song)
+	if name == "" {
+		name = song.Source + "-" + song.ID
+	}
+	return safeFilename(name) + "." + ext
+}
+
+// safeFilename 替换文件名中各系统不允许的字符
+func safeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return strings.TrimSpace(name)
+}
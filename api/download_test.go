@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestCheckCoverURLRejectsOtherHosts(t *testing.T) {
+	tests := []string{
+		"http://127.0.0.1/cover.jpg",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://localhost:8080/admin",
+		"file:///etc/passwd",
+		"ftp://y.gtimg.cn/cover.jpg",
+		"https://y.gtimg.cn.example.com/cover.jpg",
+	}
+	for _, raw := range tests {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkCoverURL(context.Background(), u); err == nil {
+			t.Errorf("checkCoverURL(%q) = nil, want error", raw)
+		}
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"203.0.113.10", true},
+		{"2001:db8::1", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"192.168.1.1", false},
+		{"172.16.0.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"web_music/api/providers"
+	"web_music/models"
+	"web_music/playlist"
+)
+
+// 导出时解析上游播放地址的超时时间和并发数
+const (
+	exportTimeout = 60 * time.Second
+	exportWorkers = 4
+)
+
+// ErrInvalidExportFormat 不支持的导出格式
+var ErrInvalidExportFormat = errors.New("不支持的导出格式，可选m3u8、xspf、json")
+
+// 导出歌单中的播放地址类型
+const (
+	linksStream = "stream" // 指向本服务的/api/stream，长期有效
+	linksDirect = "direct" // 上游的播放地址，会过期
+)
+
+// PlaylistExportHandler 将歌单导出为M3U8、XSPF或JSON文件
+// format选择格式，links为stream时使用本服务的播放地址，为direct时使用上游的播放地址
+func PlaylistExportHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 仅支持GET请求
+	if r.Method != "GET" {
+		http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	formatName := r.URL.Query().Get("format")
+	if formatName == "" {
+		formatName = "m3u8"
+	}
+	format, ok := playlist.Formats[formatName]
+	if !ok {
+		http.Error(w, ErrInvalidExportFormat.Error(), http.StatusBadRequest)
+		return
+	}
+
+	links := r.URL.Query().Get("links")
+	if links == "" {
+		links = linksStream
+	}
+	if links != linksStream && links != linksDirect {
+		http.Error(w, "links参数只能是stream或direct", http.StatusBadRequest)
+		return
+	}
+
+	quality, ok := providers.ParseQuality(r.URL.Query().Get("quality"))
+	if !ok {
+		http.Error(w, ErrInvalidQuality.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := dataStore.Playlist(user.ID, r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	var entries []playlist.Entry
+	if links == linksDirect {
+		ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+		defer cancel()
+		entries = directEntries(ctx, baseURL(r), p.Songs, quality)
+	} else {
+		entries = make([]playlist.Entry, 0, len(p.Songs))
+		for _, song := range p.Songs {
+			entries = append(entries, playlist.Entry{Song: song, URL: streamURL(baseURL(r), song, quality)})
+		}
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": p.Name + format.Ext,
+	}))
+	if err := format.Write(w, p.Name, entries); err != nil {
+		log.Printf("导出歌单 %s 失败: %v", p.ID, err)
+	}
+}
+
+// directEntries 并发解析歌曲的上游播放地址，解析失败的歌曲使用本服务的播放地址
+func directEntries(ctx context.Context, base string, songs []models.Song, quality providers.Quality) []playlist.Entry {
+	entries := make([]playlist.Entry, len(songs))
+	sem := make(chan struct{}, exportWorkers)
+	var wg sync.WaitGroup
+
+	for i, song := range songs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			entries[i] = playlist.Entry{Song: song, URL: streamURL(base, song, quality)}
+			songURL, err := getSongURL(ctx, song.ID, song.Source, quality)
+			if err != nil {
+				log.Printf("解析 %s:%s 的播放地址失败，使用代理地址: %v", song.Source, song.ID, err)
+				return
+			}
+			entries[i].URL = songURL.URL
+		}()
+	}
+
+	wg.Wait()
+	return entries
+}
+
+// streamURL 返回歌曲在本服务的播放地址，附带标题等信息以便原音乐源不可用时回退
+func streamURL(base string, song models.Song, quality providers.Quality) string {
+	query := url.Values{
+		"source":  {song.Source},
+		"id":      {song.ID},
+		"quality": {string(quality)},
+		"title":   {song.Title},
+	}
+	if song.Artist != "" {
+		query.Set("artist", song.Artist)
+	}
+	if song.Duration > 0 {
+		query.Set("duration", strconv.Itoa(song.Duration))
+	}
+	return base + "/api/stream?" + query.Encode()
+}
+
+// baseURL 返回客户端访问本服务使用的地址，如 http://localhost:8082
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	// 经过反向代理时使用代理转发的协议
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
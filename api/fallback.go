@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"web_music/api/providers"
+	"web_music/models"
+)
+
+// 跨音乐源回退设置
+const (
+	fallbackTimeout     = 20 * time.Second // 回退搜索和解析的总超时
+	fallbackPageSize    = 10               // 每个音乐源搜索的候选数量
+	maxFallbackAttempts = 3                // 最多尝试解析的候选歌曲数量
+)
+
+// resolveResult 定义歌曲URL的解析结果
+type resolveResult struct {
+	providers.SongURL
+	Source   string // 实际提供音频的音乐源
+	ID       string // 实际提供音频的歌曲ID
+	Fallback bool   // 是否使用了其他音乐源的歌曲
+}
+
+// resolveWithFallback 获取歌曲URL，失败时在其他音乐源中查找同名歌曲并返回其URL
+func resolveWithFallback(ctx context.Context, id, source, title, artist string, duration int, quality providers.Quality) (*resolveResult, error) {
+	songURL, err := getSongURL(ctx, id, source, quality)
+	if err == nil {
+		return &resolveResult{SongURL: *songURL, Source: source, ID: id}, nil
+	}
+
+	// 没有歌曲信息时无法在其他音乐源中查找
+	if strings.TrimSpace(title) == "" || ctx.Err() != nil {
+		return nil, err
+	}
+
+	log.Printf("从 %s 获取歌曲URL失败: %v，尝试其他音乐源", source, err)
+
+	ctx, cancel := context.WithTimeout(ctx, fallbackTimeout)
+	defer cancel()
+
+	candidates := findAlternatives(ctx, source, title, artist, duration)
+	for i, candidate := range candidates {
+		if i >= maxFallbackAttempts || ctx.Err() != nil {
+			break
+		}
+
+		songURL, resolveErr := getSongURL(ctx, candidate.ID, candidate.Source, quality)
+		if resolveErr != nil {
+			log.Printf("回退到 %s 的歌曲 %s 失败: %v", candidate.Source, candidate.ID, resolveErr)
+			continue
+		}
+
+		log.Printf("回退到 %s 的歌曲 %s 成功", candidate.Source, candidate.ID)
+		return &resolveResult{
+			SongURL:  *songURL,
+			Source:   candidate.Source,
+			ID:       candidate.ID,
+			Fallback: true,
+		}, nil
+	}
+
+	return nil, err
+}
+
+// findAlternatives 在除原音乐源外的所有启用音乐源中搜索同一首歌，按匹配程度排序
+func findAlternatives(ctx context.Context, excludeSource, title, artist string, duration int) []models.Song {
+	var sources []string
+	for _, p := range providers.Enabled() {
+		if p.Name() != excludeSource {
+			sources = append(sources, p.Name())
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	keyword := strings.TrimSpace(title + " " + artist)
+	query := providers.SearchQuery{
+		Keyword:  keyword,
+		Type:     providers.SearchTypeSong,
+		PageSize: fallbackPageSize,
+	}.Normalize()
+
+	wantTitle := normalizeText(title)
+	wantArtists := splitArtists(artist)
+
+	type candidate struct {
+		song  models.Song
+		score float64
+	}
+	var candidates []candidate
+	for result := range fanOutSearch(ctx, query, sources, false) {
+		for rank, song := range result.result.Songs {
+			if normalizeText(song.Title) != wantTitle {
+				continue
+			}
+			artists := splitArtists(song.Artist)
+			if len(wantArtists) > 0 && len(artists) > 0 && !overlaps(wantArtists, artists) {
+				continue
+			}
+			if song.Unavailable || !durationsMatch(duration, song.Duration) {
+				continue
+			}
+			candidates = append(candidates, candidate{
+				song:  song,
+				score: relevance(keyword, song, 1, rank),
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	songs := make([]models.Song, 0, len(candidates))
+	for _, c := range candidates {
+		songs = append(songs, c.song)
+	}
+	return songs
+}
@@ -1,14 +1,20 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"web_music/api/providers"
 	"web_music/models"
+	"web_music/urlcache"
 )
 
 // 定义错误
@@ -16,19 +22,81 @@ var (
 	ErrUnsupportedProvider = errors.New("不支持的音乐源")
 	ErrFetchFailed         = errors.New("获取数据失败")
 	ErrParseError          = errors.New("解析响应失败")
+	ErrInvalidSearchType   = errors.New("不支持的搜索类型")
+	ErrInvalidQuality      = errors.New("不支持的音质")
 )
 
 // SearchResponse 定义搜索结果响应结构
 type SearchResponse struct {
-	Songs []models.Song `json:"songs"`
-	Total int           `json:"total"`
+	Type      providers.SearchType `json:"type"`
+	Songs     []models.Song        `json:"songs"`
+	Albums    []models.Album       `json:"albums,omitempty"`
+	Artists   []models.Artist      `json:"artists,omitempty"`
+	Playlists []models.Playlist    `json:"playlists,omitempty"`
+	Merged    []MergedSong         `json:"merged,omitempty"`
+	Total     int                  `json:"total"`
+	Page      int                  `json:"page"`
+	PageSize  int                  `json:"pageSize"`
+	Sources   []SourceStatus       `json:"sources"`
 }
 
 // SongURLResponse 定义获取歌曲URL的响应结构
 type SongURLResponse struct {
-	URL  string `json:"url"`
-	Code int    `json:"code"`
-	Msg  string `json:"msg,omitempty"`
+	URL      string `json:"url"`
+	Code     int    `json:"code"`
+	Msg      string `json:"msg,omitempty"`
+	Source   string `json:"source,omitempty"`   // 实际提供音频的音乐源
+	ID       string `json:"id,omitempty"`       // 实际提供音频的歌曲ID
+	Fallback bool   `json:"fallback,omitempty"` // 是否回退到了其他音乐源
+	Quality  string `json:"quality,omitempty"`  // 实际获取到的音质等级
+	Bitrate  int    `json:"bitrate,omitempty"`  // 实际码率(kbps)
+	Format   string `json:"format,omitempty"`   // 实际文件格式
+	Expires  int64  `json:"expires,omitempty"`  // 播放地址的过期时间(Unix秒)
+}
+
+// SourceInfo 定义音乐源信息
+type SourceInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// SourcesResponse 定义音乐源列表的响应结构
+type SourcesResponse struct {
+	Sources []SourceInfo `json:"sources"`
+}
+
+// SourcesHandler 返回所有已注册的音乐源
+func SourcesHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 仅支持GET请求
+	if r.Method != "GET" {
+		http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := SourcesResponse{Sources: []SourceInfo{}}
+	for _, p := range providers.All() {
+		response.Sources = append(response.Sources, SourceInfo{
+			Name:        p.Name(),
+			DisplayName: providers.DisplayName(p),
+			Enabled:     providers.IsEnabled(p.Name()),
+		})
+	}
+
+	// 返回JSON响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 // SearchHandler 处理音乐搜索请求
@@ -37,6 +105,7 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "X-Cache")
 
 	// 处理预检请求
 	if r.Method == "OPTIONS" {
@@ -60,33 +129,170 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 解析音乐源
-	var sources []string
-	if sourcesParam != "" {
-		sources = strings.Split(sourcesParam, ",")
-	} else {
-		// 默认使用所有源
-		sources = []string{"qq", "netease", "kuwo"}
-	}
+	sources := parseSources(sourcesParam)
 
-	// 搜索歌曲
-	songs, err := searchAllProviders(keyword, sources)
+	// 解析搜索类型和分页参数
+	query, err := parseSearchQuery(r, keyword)
 	if err != nil {
-		log.Printf("搜索失败: %v", err)
-		http.Error(w, "搜索失败", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// 并发搜索，nocache=1时跳过缓存直接请求上游
+	noCache := r.URL.Query().Get("nocache") == "1"
+	result, statuses := searchAllProviders(r.Context(), query, sources, noCache)
+
 	// 构造响应
 	response := SearchResponse{
-		Songs: songs,
-		Total: len(songs),
+		Type:      query.Type,
+		Songs:     result.Songs,
+		Albums:    result.Albums,
+		Artists:   result.Artists,
+		Playlists: result.Playlists,
+		Total:     result.Total,
+		Page:      query.Page,
+		PageSize:  query.PageSize,
+		Sources:   statuses,
+	}
+
+	// 合并不同音乐源中的相同歌曲
+	if isMergeRequested(r, query) {
+		response.Merged = mergeSongs(keyword, result.Songs)
 	}
 
 	// 返回JSON响应
+	if searchCache != nil {
+		w.Header().Set("X-Cache", cacheStatus(statuses, noCache))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// SearchStreamEvent 定义流式搜索中单个音乐源的结果事件
+type SearchStreamEvent struct {
+	Source    string            `json:"source"`
+	Songs     []models.Song     `json:"songs"`
+	Albums    []models.Album    `json:"albums,omitempty"`
+	Artists   []models.Artist   `json:"artists,omitempty"`
+	Playlists []models.Playlist `json:"playlists,omitempty"`
+	Status    SourceStatus      `json:"status"`
+}
+
+// SearchStreamSummary 定义流式搜索结束时的汇总事件
+type SearchStreamSummary struct {
+	Type     providers.SearchType `json:"type"`
+	Merged   []MergedSong         `json:"merged,omitempty"`
+	Total    int                  `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"pageSize"`
+	Sources  []SourceStatus       `json:"sources"`
+}
+
+// SearchStreamHandler 以Server-Sent Events的形式推送各音乐源的搜索结果
+func SearchStreamHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 仅支持GET请求
+	if r.Method != "GET" {
+		http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 获取查询参数
+	keyword := r.URL.Query().Get("keyword")
+	sourcesParam := r.URL.Query().Get("sources")
+
+	if keyword == "" {
+		http.Error(w, "缺少关键词参数", http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseSearchQuery(r, keyword)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+
+	// 设置SSE响应头，流式搜索中各音乐源是否命中缓存见结果事件的status.cached
+	noCache := r.URL.Query().Get("nocache") == "1"
+	if searchCache != nil && noCache {
+		w.Header().Set("X-Cache", CacheBypass)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	sources := parseSources(sourcesParam)
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout)
+	defer cancel()
+
+	// 每个音乐源完成后立即推送结果
+	statuses := make([]SourceStatus, len(sources))
+	results := make([][]models.Song, len(sources))
+	total := 0
+	for result := range fanOutSearch(ctx, query, sources, noCache) {
+		statuses[result.index] = result.status
+		results[result.index] = result.result.Songs
+		total += result.status.Total
+
+		songs := result.result.Songs
+		if songs == nil {
+			songs = []models.Song{}
+		}
+		if err := writeSSE(w, "result", SearchStreamEvent{
+			Source:    result.status.Source,
+			Songs:     songs,
+			Albums:    result.result.Albums,
+			Artists:   result.result.Artists,
+			Playlists: result.result.Playlists,
+			Status:    result.status,
+		}); err != nil {
+			log.Printf("推送搜索结果失败: %v", err)
+			return
+		}
+		flusher.Flush()
+	}
+
+	// 推送汇总事件，需要合并时按音乐源顺序合并全部结果
+	summary := SearchStreamSummary{
+		Type:     query.Type,
+		Total:    total,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Sources:  statuses,
+	}
+	if isMergeRequested(r, query) {
+		var allSongs []models.Song
+		for _, songs := range results {
+			allSongs = append(allSongs, songs...)
+		}
+		summary.Merged = mergeSongs(keyword, allSongs)
+	}
+	if err := writeSSE(w, "done", summary); err != nil {
+		log.Printf("推送搜索汇总失败: %v", err)
+		return
+	}
+	flusher.Flush()
+}
+
 // SongHandler 处理获取歌曲URL的请求
 func SongHandler(w http.ResponseWriter, r *http.Request) {
 	// 设置跨域头
@@ -109,14 +315,24 @@ func SongHandler(w http.ResponseWriter, r *http.Request) {
 	// 获取查询参数
 	id := r.URL.Query().Get("id")
 	source := r.URL.Query().Get("source")
+	// 歌曲标题和歌手是可选的，提供时原音乐源失败会回退到其他音乐源
+	title := r.URL.Query().Get("title")
+	artist := r.URL.Query().Get("artist")
+	duration, _ := strconv.Atoi(r.URL.Query().Get("duration"))
 
 	if id == "" || source == "" {
 		http.Error(w, "缺少必要参数", http.StatusBadRequest)
 		return
 	}
 
+	quality, ok := providers.ParseQuality(r.URL.Query().Get("quality"))
+	if !ok {
+		http.Error(w, ErrInvalidQuality.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// 获取歌曲URL
-	url, err := getSongURL(id, source)
+	resolved, err := resolveWithFallback(r.Context(), id, source, title, artist, duration, quality)
 	if err != nil {
 		log.Printf("获取歌曲URL失败: %v", err)
 
@@ -133,54 +349,98 @@ func SongHandler(w http.ResponseWriter, r *http.Request) {
 	// 返回JSON响应
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(SongURLResponse{
-		URL:  url,
-		Code: http.StatusOK,
+		URL:      resolved.URL,
+		Code:     http.StatusOK,
+		Source:   resolved.Source,
+		ID:       resolved.ID,
+		Fallback: resolved.Fallback,
+		Quality:  string(resolved.Quality),
+		Bitrate:  resolved.Bitrate,
+		Format:   resolved.Format,
+		Expires:  resolved.Expires.Unix(),
 	})
 }
 
-// searchAllProviders 从多个音乐源搜索歌曲
-func searchAllProviders(keyword string, sources []string) ([]models.Song, error) {
-	var allSongs []models.Song
-
-	// 遍历所有请求的音乐源
-	for _, source := range sources {
-		var songs []models.Song
-		var err error
-
-		switch source {
-		case "qq":
-			songs, err = providers.SearchQQMusic(keyword)
-		case "netease":
-			songs, err = providers.SearchNetease(keyword)
-		case "kuwo":
-			songs, err = providers.SearchKuwo(keyword)
-		default:
-			log.Printf("不支持的音乐源: %s", source)
-			continue
+// parseSources 解析逗号分隔的音乐源参数，为空时使用所有启用的源
+func parseSources(sourcesParam string) []string {
+	var sources []string
+	if sourcesParam != "" {
+		for _, source := range strings.Split(sourcesParam, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				sources = append(sources, source)
+			}
 		}
+		return sources
+	}
 
-		if err != nil {
-			log.Printf("从 %s 搜索失败: %v", source, err)
-			continue
-		}
+	// 默认使用所有启用的源
+	for _, p := range providers.Enabled() {
+		sources = append(sources, p.Name())
+	}
+	return sources
+}
 
-		// 合并结果
-		allSongs = append(allSongs, songs...)
+// parseSearchQuery 解析搜索关键词、搜索类型和分页参数
+func parseSearchQuery(r *http.Request, keyword string) (providers.SearchQuery, error) {
+	searchType, ok := providers.ParseSearchType(r.URL.Query().Get("type"))
+	if !ok {
+		return providers.SearchQuery{}, ErrInvalidSearchType
 	}
 
-	return allSongs, nil
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+
+	return providers.SearchQuery{
+		Keyword:  keyword,
+		Type:     searchType,
+		Page:     page,
+		PageSize: pageSize,
+	}.Normalize(), nil
+}
+
+// isMergeRequested 判断是否需要合并结果，只有歌曲和歌词搜索支持合并
+func isMergeRequested(r *http.Request, query providers.SearchQuery) bool {
+	merge, _ := strconv.ParseBool(r.URL.Query().Get("merge"))
+	return merge && (query.Type == providers.SearchTypeSong || query.Type == providers.SearchTypeLyric)
+}
+
+// writeSSE 写入一条Server-Sent Events事件
+func writeSSE(w io.Writer, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
 }
 
-// getSongURL 获取歌曲的URL
-func getSongURL(id, source string) (string, error) {
-	switch source {
-	case "qq":
-		return providers.GetQQMusicURL(id)
-	case "netease":
-		return providers.GetNeteaseURL(id)
-	case "kuwo":
-		return providers.GetKuwoURL(id)
-	default:
-		return "", ErrUnsupportedProvider
+// urlCache 缓存解析过的播放地址，直到上游给出的过期时间
+var urlCache = urlcache.New(resolveSongURL, func(source string) time.Duration {
+	if provider, ok := providers.Get(source); ok {
+		return providers.URLTTL(provider)
 	}
+	return providers.DefaultURLTTL
+})
+
+// getSongURL 获取歌曲的URL，优先使用未过期的缓存
+func getSongURL(ctx context.Context, id, source string, quality providers.Quality) (*providers.SongURL, error) {
+	// 音乐源被禁用后不再返回缓存的地址
+	if _, ok := providers.Get(source); !ok {
+		return nil, ErrUnsupportedProvider
+	}
+	return urlCache.Get(ctx, urlcache.Key{Source: source, ID: id, Quality: quality})
+}
+
+// resolveSongURL 向音乐源解析歌曲的URL
+func resolveSongURL(ctx context.Context, key urlcache.Key) (*providers.SongURL, error) {
+	provider, ok := providers.Get(key.Source)
+	if !ok {
+		return nil, ErrUnsupportedProvider
+	}
+	return provider.ResolveURL(ctx, key.ID, key.Quality)
+}
+
+// invalidateSongURL 上游拒绝播放地址时删除缓存，下次重新解析
+func invalidateSongURL(id, source string, quality providers.Quality) {
+	urlCache.Invalidate(urlcache.Key{Source: source, ID: id, Quality: quality})
 }
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"web_music/api/providers"
+)
+
+// 音乐源的整体健康状态
+const (
+	HealthUnknown  = "unknown"  // 还没有请求过上游
+	HealthHealthy  = "healthy"  // 所有接口正常
+	HealthDegraded = "degraded" // 部分接口熔断
+	HealthDown     = "down"     // 所有接口熔断
+)
+
+// ProviderHealth 定义单个音乐源及其上游接口的健康状态
+type ProviderHealth struct {
+	Name        string                     `json:"name"`
+	DisplayName string                     `json:"displayName"`
+	Enabled     bool                       `json:"enabled"`
+	Status      string                     `json:"status"`
+	Endpoints   []providers.EndpointHealth `json:"endpoints"`
+}
+
+// ProvidersHealthResponse 定义音乐源健康状态的响应结构
+type ProvidersHealthResponse struct {
+	Providers []ProviderHealth `json:"providers"`
+}
+
+// ProvidersHealthHandler 返回各音乐源上游接口的熔断状态
+func ProvidersHealthHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 仅支持GET请求
+	if r.Method != "GET" {
+		http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 按音乐源分组，多个音乐源共用的接口会出现在每个音乐源下
+	endpoints := providers.EndpointsHealth()
+	response := ProvidersHealthResponse{Providers: []ProviderHealth{}}
+	for _, p := range providers.All() {
+		health := ProviderHealth{
+			Name:        p.Name(),
+			DisplayName: providers.DisplayName(p),
+			Enabled:     providers.IsEnabled(p.Name()),
+			Endpoints:   []providers.EndpointHealth{},
+		}
+		open := 0
+		for _, endpoint := range endpoints {
+			for _, name := range endpoint.Providers {
+				if name != p.Name() {
+					continue
+				}
+				health.Endpoints = append(health.Endpoints, endpoint)
+				if endpoint.State == providers.BreakerOpen {
+					open++
+				}
+			}
+		}
+
+		switch {
+		case len(health.Endpoints) == 0:
+			health.Status = HealthUnknown
+		case open == 0:
+			health.Status = HealthHealthy
+		case open == len(health.Endpoints):
+			health.Status = HealthDown
+		default:
+			health.Status = HealthDegraded
+		}
+		response.Providers = append(response.Providers, health)
+	}
+
+	// 返回JSON响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"web_music/models"
+)
+
+// HistoryHandler 获取(GET)、添加(POST)或清空(DELETE)当前用户的播放记录
+func HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		entries, err := dataStore.History(user.ID, limit)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+
+	case "POST":
+		var song models.Song
+		if !decodeJSONBody(w, r, &song) {
+			return
+		}
+		if song.Source == "" || song.ID == "" {
+			http.Error(w, "缺少歌曲信息", http.StatusBadRequest)
+			return
+		}
+		if err := dataStore.AddHistory(user.ID, song); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case "DELETE":
+		if err := dataStore.ClearHistory(user.ID); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "仅支持GET、POST和DELETE请求", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"web_music/api/providers"
+	"web_music/models"
+)
+
+// 导入歌单的超时时间，大歌单需要分页请求多次
+const importTimeout = 60 * time.Second
+
+// ImportRequest 定义保存导入歌单的请求体
+type ImportRequest struct {
+	URL  string `json:"url"`            // 分享链接，也可以是包含链接的分享文案
+	Name string `json:"name,omitempty"` // 保存的歌单名称，为空时使用原歌单名称
+}
+
+// ImportResponse 定义导入结果
+type ImportResponse struct {
+	Source string               `json:"source"`
+	Kind   providers.ImportKind `json:"kind"`
+	ID     string               `json:"id"`
+	Name   string               `json:"name"`
+	Songs  []models.Song        `json:"songs"`
+	Total  int                  `json:"total"` // 原歌单的歌曲总数，超过导入上限时大于songs的数量
+	Saved  *models.UserPlaylist `json:"playlist,omitempty"`
+}
+
+// ImportHandler 导入网易云、QQ音乐和酷我的歌单、专辑或歌曲分享链接
+// GET ?url= 返回其中的歌曲，POST 将歌曲保存为当前用户的歌单
+func ImportHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var (
+		req  ImportRequest
+		user *models.User
+	)
+	switch r.Method {
+	case "GET":
+		req.URL = r.URL.Query().Get("url")
+	case "POST":
+		var ok bool
+		if user, ok = requireUser(w, r); !ok {
+			return
+		}
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+	default:
+		http.Error(w, "仅支持GET和POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.TrimSpace(req.URL) == "" {
+		http.Error(w, "缺少必要参数", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), importTimeout)
+	defer cancel()
+
+	provider, target, err := providers.ResolveShareURL(ctx, req.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := provider.(providers.Importer).Import(ctx, target)
+	if err != nil {
+		if errors.Is(err, providers.ErrUnrecognizedURL) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("从 %s 导入 %s %s 失败: %v", provider.Name(), target.Kind, target.ID, err)
+		http.Error(w, "导入失败", http.StatusBadGateway)
+		return
+	}
+
+	response := ImportResponse{
+		Source: provider.Name(),
+		Kind:   target.Kind,
+		ID:     target.ID,
+		Name:   result.Name,
+		Songs:  result.Songs,
+		Total:  result.Total,
+	}
+	if user == nil {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	// 保存为歌单
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = result.Name
+	}
+	if name == "" {
+		name = providers.DisplayName(provider) + "导入"
+	}
+	response.Saved, err = dataStore.CreatePlaylist(user.ID, name, result.Songs)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
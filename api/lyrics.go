@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"web_music/api/providers"
+	"web_music/lyrics"
+)
+
+// 获取歌词的超时时间
+const lyricsTimeout = 15 * time.Second
+
+// ErrLyricsUnsupported 音乐源不支持获取歌词
+var ErrLyricsUnsupported = errors.New("音乐源不支持获取歌词")
+
+// LyricsHandler 获取歌曲歌词，返回原始LRC和解析后的歌词行，karaoke=1时同时返回逐字歌词
+func LyricsHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 仅支持GET请求
+	if r.Method != "GET" {
+		http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 获取查询参数
+	id := r.URL.Query().Get("id")
+	source := r.URL.Query().Get("source")
+
+	if id == "" || source == "" {
+		http.Error(w, "缺少必要参数", http.StatusBadRequest)
+		return
+	}
+
+	provider, ok := providers.Get(source)
+	if !ok {
+		http.Error(w, ErrUnsupportedProvider.Error(), http.StatusBadRequest)
+		return
+	}
+	lp, ok := provider.(providers.LyricsProvider)
+	if !ok {
+		http.Error(w, ErrLyricsUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), lyricsTimeout)
+	defer cancel()
+
+	result, err := lp.Lyrics(ctx, id)
+	if err != nil {
+		if errors.Is(err, providers.ErrLyricsNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("从 %s 获取歌词失败: %v", source, err)
+		http.Error(w, "获取歌词失败", http.StatusBadGateway)
+		return
+	}
+
+	// 请求逐字歌词时额外获取，获取失败不影响普通歌词
+	if r.URL.Query().Get("karaoke") == "1" {
+		if kp, ok := provider.(providers.KaraokeProvider); ok {
+			format, text, err := kp.KaraokeLyrics(ctx, id)
+			if err != nil {
+				log.Printf("从 %s 获取逐字歌词失败: %v", source, err)
+			} else {
+				result.KaraokeFormat = format
+				result.KaraokeText = text
+			}
+		}
+	}
+
+	lyrics.Parse(result)
+
+	// 返回JSON响应
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"web_music/models"
+)
+
+// MergedSong 定义跨音乐源合并后的歌曲
+type MergedSong struct {
+	models.Song
+	Alternatives []models.SongRef `json:"alternatives"`
+	Score        float64          `json:"score"`
+}
+
+// songGroup 合并过程中的一组等价歌曲
+type songGroup struct {
+	songs    []models.Song
+	title    string
+	artists  []string
+	duration int
+	bestRank int
+}
+
+// mergeSongs 将不同音乐源中的等价歌曲合并为一条，并按与关键词的相关度排序
+func mergeSongs(keyword string, songs []models.Song) []MergedSong {
+	// 记录每首歌在所属音乐源中的排名
+	ranks := make(map[string]int)
+
+	var groups []*songGroup
+	for _, song := range songs {
+		rank := ranks[song.Source]
+		ranks[song.Source]++
+
+		title := normalizeText(song.Title)
+		artists := splitArtists(song.Artist)
+
+		var matched *songGroup
+		for _, g := range groups {
+			if g.matches(title, artists, song) {
+				matched = g
+				break
+			}
+		}
+		if matched == nil {
+			matched = &songGroup{title: title, artists: artists, bestRank: rank}
+			groups = append(groups, matched)
+		}
+		matched.songs = append(matched.songs, song)
+		if matched.duration == 0 {
+			matched.duration = song.Duration
+		}
+		if rank < matched.bestRank {
+			matched.bestRank = rank
+		}
+	}
+
+	merged := make([]MergedSong, 0, len(groups))
+	for _, g := range groups {
+		merged = append(merged, g.merge(keyword))
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+
+	return merged
+}
+
+// matches 判断歌曲是否与该组等价：标题相同、歌手有交集、时长相近，且同一音乐源只保留一首
+func (g *songGroup) matches(title string, artists []string, song models.Song) bool {
+	if title == "" || title != g.title {
+		return false
+	}
+	for _, s := range g.songs {
+		if s.Source == song.Source {
+			return false
+		}
+	}
+	if len(artists) > 0 && len(g.artists) > 0 && !overlaps(artists, g.artists) {
+		return false
+	}
+	return durationsMatch(g.duration, song.Duration)
+}
+
+// merge 生成合并结果，优先使用信息最完整的歌曲作为代表
+func (g *songGroup) merge(keyword string) MergedSong {
+	best := g.songs[0]
+	for _, s := range g.songs[1:] {
+		// 优先选择可播放且有封面的歌曲
+		if (best.Unavailable && !s.Unavailable) || (best.Cover == "" && s.Cover != "" && best.Unavailable == s.Unavailable) {
+			best = s
+		}
+	}
+
+	alternatives := make([]models.SongRef, 0, len(g.songs))
+	for _, s := range g.songs {
+		alternatives = append(alternatives, s.Ref())
+	}
+
+	return MergedSong{
+		Song:         best,
+		Alternatives: alternatives,
+		Score:        relevance(keyword, best, len(g.songs), g.bestRank),
+	}
+}
+
+// relevance 计算歌曲与关键词的相关度
+func relevance(keyword string, song models.Song, sourceCount, bestRank int) float64 {
+	kw := normalizeText(keyword)
+	title := normalizeText(song.Title)
+	artist := normalizeText(song.Artist)
+
+	score := 0.0
+
+	// 标题匹配程度
+	switch {
+	case kw == title:
+		score += 1.0
+	case kw != "" && strings.Contains(kw, title) && title != "":
+		// 关键词包含标题，常见于"歌手 歌名"形式的搜索
+		score += 0.8
+	case kw != "" && strings.Contains(title, kw):
+		score += 0.6
+	}
+
+	// 关键词各部分在标题和歌手中的覆盖率
+	tokens := strings.Fields(strings.ToLower(keyword))
+	if len(tokens) > 0 {
+		hit := 0
+		for _, token := range tokens {
+			token = normalizeText(token)
+			if token != "" && (strings.Contains(title, token) || strings.Contains(artist, token)) {
+				hit++
+			}
+		}
+		score += 0.5 * float64(hit) / float64(len(tokens))
+	}
+
+	// 多个音乐源都能找到的歌曲通常更热门
+	score += 0.1 * float64(sourceCount-1)
+
+	// 上游排名越靠前越相关
+	score += 0.2 / float64(bestRank+1)
+
+	return score
+}
+
+// normalizeText 归一化文本：转小写、全角转半角，并去掉空白和标点
+func normalizeText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		// 全角字符转半角
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// splitArtists 拆分并归一化歌手列表
+func splitArtists(s string) []string {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '/' || r == '&' || r == '、' || r == '，' || r == ';'
+	})
+
+	var artists []string
+	for _, part := range parts {
+		if name := normalizeText(part); name != "" {
+			artists = append(artists, name)
+		}
+	}
+	return artists
+}
+
+// 时长相差在该范围内视为同一版本
+const durationTolerance = 5
+
+// durationsMatch 判断两个时长(秒)是否相近，任一未知时视为相近
+func durationsMatch(a, b int) bool {
+	if a <= 0 || b <= 0 {
+		return true
+	}
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= durationTolerance
+}
+
+// overlaps 判断两个歌手列表是否有交集
+func overlaps(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"web_music/models"
+)
+
+// refs 返回合并结果中每首歌曲的代表和所有音乐源
+func refs(merged []MergedSong) [][]string {
+	var groups [][]string
+	for _, m := range merged {
+		group := []string{m.Source + ":" + m.ID}
+		for _, alt := range m.Alternatives {
+			group = append(group, alt.Source+":"+alt.ID)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func TestMergeSongs(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyword string
+		songs   []models.Song
+		want    [][]string // 每组的代表歌曲，之后是所有等价歌曲
+	}{
+		{
+			name:    "标题和歌手相同",
+			keyword: "晴天",
+			songs: []models.Song{
+				{Source: "qq", ID: "1", Title: "晴天", Artist: "周杰伦", Duration: 269},
+				{Source: "netease", ID: "2", Title: "晴天 ", Artist: "周杰伦", Duration: 270},
+				{Source: "kuwo", ID: "3", Title: "ＱＩＮＧ天", Artist: "周杰伦"},
+			},
+			want: [][]string{
+				{"qq:1", "qq:1", "netease:2"},
+				{"kuwo:3", "kuwo:3"},
+			},
+		},
+		{
+			name:    "歌手有交集即可合并",
+			keyword: "Stay",
+			songs: []models.Song{
+				{Source: "qq", ID: "1", Title: "Stay", Artist: "The Kid LAROI / Justin Bieber"},
+				{Source: "kuwo", ID: "2", Title: "STAY", Artist: "Justin Bieber&The Kid LAROI"},
+				{Source: "netease", ID: "3", Title: "Stay", Artist: "Rihanna"},
+			},
+			want: [][]string{
+				{"qq:1", "qq:1", "kuwo:2"},
+				{"netease:3", "netease:3"},
+			},
+		},
+		{
+			name:    "时长相差过大视为不同版本",
+			keyword: "海阔天空",
+			songs: []models.Song{
+				{Source: "qq", ID: "1", Title: "海阔天空", Artist: "Beyond", Duration: 326},
+				{Source: "netease", ID: "2", Title: "海阔天空", Artist: "Beyond", Duration: 400},
+			},
+			want: [][]string{
+				{"qq:1", "qq:1"},
+				{"netease:2", "netease:2"},
+			},
+		},
+		{
+			name:    "同一音乐源不合并",
+			keyword: "晴天",
+			songs: []models.Song{
+				{Source: "qq", ID: "1", Title: "晴天", Artist: "周杰伦"},
+				{Source: "qq", ID: "2", Title: "晴天", Artist: "周杰伦"},
+			},
+			want: [][]string{
+				{"qq:1", "qq:1"},
+				{"qq:2", "qq:2"},
+			},
+		},
+		{
+			name:    "优先使用可播放且有封面的歌曲",
+			keyword: "晴天",
+			songs: []models.Song{
+				{Source: "qq", ID: "1", Title: "晴天", Artist: "周杰伦", Unavailable: true, Cover: "c"},
+				{Source: "netease", ID: "2", Title: "晴天", Artist: "周杰伦"},
+				{Source: "kuwo", ID: "3", Title: "晴天", Artist: "周杰伦", Cover: "c"},
+			},
+			want: [][]string{
+				{"kuwo:3", "qq:1", "netease:2", "kuwo:3"},
+			},
+		},
+		{
+			name:    "按相关度排序",
+			keyword: "周杰伦 稻香",
+			songs: []models.Song{
+				{Source: "qq", ID: "1", Title: "稻香(Live)", Artist: "某人"},
+				{Source: "qq", ID: "2", Title: "稻香", Artist: "周杰伦"},
+			},
+			want: [][]string{
+				{"qq:2", "qq:2"},
+				{"qq:1", "qq:1"},
+			},
+		},
+		{
+			name:    "空结果",
+			keyword: "test",
+			songs:   nil,
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refs(mergeSongs(tt.keyword, tt.songs)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeSongs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hello, World!", "helloworld"},
+		{"ＡＢＣ１２３", "abc123"},
+		{"晴天 (Live)", "晴天live"},
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeText(tt.in); got != tt.want {
+			t.Errorf("normalizeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitArtists(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"周杰伦", []string{"周杰伦"}},
+		{"A / B & C、D，E;F,G", []string{"a", "b", "c", "d", "e", "f", "g"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitArtists(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArtists(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
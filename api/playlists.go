@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"web_music/models"
+	"web_music/playlist"
+	"web_music/store"
+)
+
+// 歌单接口请求体的大小上限
+const maxPlaylistBodySize = 4 << 20
+
+// ErrStoreUnavailable 没有可用的本地存储
+var ErrStoreUnavailable = errors.New("本地存储不可用")
+
+// dataStore 保存用户、歌单和收藏的本地存储，为空时相关接口不可用
+var dataStore *store.Store
+
+// SetStore 设置歌单和收藏使用的本地存储
+func SetStore(s *store.Store) {
+	dataStore = s
+}
+
+// PlaylistRequest 定义创建、重命名歌单和添加歌曲的请求体
+// 导出的JSON歌单可以直接作为创建歌单的请求体重新导入
+type PlaylistRequest struct {
+	// 导出的JSON歌单的格式版本，普通请求不需要
+	Version int    `json:"version,omitempty"`
+	Name    string `json:"name,omitempty"`
+	// 要添加的歌曲，也可以直接提交单首歌曲的字段
+	Songs []models.Song `json:"songs,omitempty"`
+	models.Song
+}
+
+// songs 返回请求中的所有歌曲
+func (req *PlaylistRequest) songs() []models.Song {
+	songs := req.Songs
+	if req.Song.ID != "" && req.Song.Source != "" {
+		songs = append(songs, req.Song)
+	}
+	return songs
+}
+
+// PlaylistsHandler 获取歌单列表(GET)或创建歌单(POST)
+func PlaylistsHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		playlists, err := dataStore.Playlists(user.ID)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, playlists)
+
+	case "POST":
+		var req PlaylistRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		if req.Version > playlist.JSONVersion {
+			http.Error(w, "不支持的歌单文件版本，请升级服务器", http.StatusBadRequest)
+			return
+		}
+		created, err := dataStore.CreatePlaylist(user.ID, req.Name, req.songs())
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+
+	default:
+		http.Error(w, "仅支持GET和POST请求", http.StatusMethodNotAllowed)
+	}
+}
+
+// PlaylistHandler 获取(GET)、重命名(PUT)或删除(DELETE)歌单
+func PlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	switch r.Method {
+	case "GET":
+		pl, err := dataStore.Playlist(user.ID, id)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, pl)
+
+	case "PUT", "PATCH":
+		var req PlaylistRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		pl, err := dataStore.RenamePlaylist(user.ID, id, req.Name)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, pl)
+
+	case "DELETE":
+		if err := dataStore.DeletePlaylist(user.ID, id); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "仅支持GET、PUT和DELETE请求", http.StatusMethodNotAllowed)
+	}
+}
+
+// PlaylistSongsHandler 向歌单添加歌曲(POST)、移除歌曲(DELETE)或调整歌曲顺序(PUT)
+func PlaylistSongsHandler(w http.ResponseWriter, r *http.Request) {
+	handlePlaylistSongs(w, r, r.PathValue("id"))
+}
+
+// FavoritesHandler 获取收藏列表(GET)、添加收藏(POST)、取消收藏(DELETE)或调整顺序(PUT)
+func FavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	handlePlaylistSongs(w, r, store.FavoritesID)
+}
+
+// handlePlaylistSongs 处理歌单中歌曲的增删和排序
+func handlePlaylistSongs(w http.ResponseWriter, r *http.Request, id string) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		pl  *models.UserPlaylist
+		err error
+	)
+	switch r.Method {
+	case "GET":
+		pl, err = dataStore.Playlist(user.ID, id)
+
+	case "POST":
+		var req PlaylistRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		songs := req.songs()
+		if len(songs) == 0 {
+			http.Error(w, "缺少歌曲信息", http.StatusBadRequest)
+			return
+		}
+		pl, err = dataStore.AddSongs(user.ID, id, songs)
+
+	case "DELETE":
+		ref := models.SongRef{
+			Source: r.URL.Query().Get("source"),
+			ID:     r.URL.Query().Get("id"),
+		}
+		if ref.Source == "" || ref.ID == "" {
+			http.Error(w, "缺少必要参数", http.StatusBadRequest)
+			return
+		}
+		pl, err = dataStore.RemoveSong(user.ID, id, ref)
+
+	case "PUT":
+		// 请求体为按新顺序排列的歌曲标识列表
+		var order []models.SongRef
+		if !decodeJSONBody(w, r, &order) {
+			return
+		}
+		pl, err = dataStore.ReorderSongs(user.ID, id, order)
+
+	default:
+		http.Error(w, "仅支持GET、POST、PUT和DELETE请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pl)
+}
+
+// writeStoreError 将存储层的错误转换为HTTP响应
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, store.ErrEmptyName), errors.Is(err, store.ErrInvalidOrder):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, store.ErrUserNotFound), errors.Is(err, store.ErrTokenNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, store.ErrUserExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, store.ErrInvalidUsername), errors.Is(err, store.ErrWeakPassword),
+		errors.Is(err, store.ErrInvalidRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, store.ErrInvalidCredentials), errors.Is(err, store.ErrInvalidToken):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, store.ErrReserved), errors.Is(err, store.ErrLastAdmin):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("存储操作失败: %v", err)
+		http.Error(w, "存储操作失败", http.StatusInternalServerError)
+	}
+}
+
+// writeJSON 以指定状态码返回JSON响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
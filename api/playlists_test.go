@@ -0,0 +1,48 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"web_music/models"
+	"web_music/playlist"
+)
+
+func TestPlaylistRequestImportsJSONExport(t *testing.T) {
+	entries := []playlist.Entry{
+		{
+			Song: models.Song{ID: "1", Source: "qq", Title: "晴天", Artist: "周杰伦", Album: "叶惠美", Duration: 269, Qualities: []string{"128k", "flac"}},
+			URL:  "http://localhost:8082/api/stream?id=1&source=qq",
+		},
+		{
+			Song: models.Song{ID: "2", Source: "netease", Title: "Rock & Roll <Live>", Unavailable: true},
+			URL:  "http://localhost:8082/api/stream?id=2&source=netease",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := playlist.WriteJSON(&buf, "我的歌单", entries); err != nil {
+		t.Fatal(err)
+	}
+	var req PlaylistRequest
+	if err := json.Unmarshal(buf.Bytes(), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Version != playlist.JSONVersion || req.Name != "我的歌单" {
+		t.Errorf("version = %d, name = %q", req.Version, req.Name)
+	}
+	songs := req.songs()
+	if len(songs) != len(entries) {
+		t.Fatalf("导入 %d 首歌曲, want %d", len(songs), len(entries))
+	}
+	for i, e := range entries {
+		want := e.Song
+		want.URL = e.URL
+		if !reflect.DeepEqual(songs[i], want) {
+			t.Errorf("songs[%d] = %+v, want %+v", i, songs[i], want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"    // 正常请求
+	BreakerOpen     = "open"      // 连续失败，直接跳过
+	BreakerHalfOpen = "half-open" // 冷却结束，放行一个试探请求
+)
+
+// 熔断设置
+const (
+	breakerFailureThreshold = 3                // 连续失败多少次后打开
+	breakerMinCooldown      = 30 * time.Second // 打开后第一次试探前的等待时间
+	breakerMaxCooldown      = 5 * time.Minute  // 试探一直失败时等待时间的上限
+)
+
+// ErrCircuitOpen 上游接口连续失败，熔断期间不再请求
+var ErrCircuitOpen = errors.New("上游接口暂时不可用")
+
+// EndpointHealth 定义一个上游接口的健康状态
+type EndpointHealth struct {
+	Endpoint    string     `json:"endpoint"`       // 接口链中的接口地址，其他请求为主机名，如 music.163.com
+	Type        string     `json:"type,omitempty"` // 接口链中的接口类型，如 qqmusics
+	Providers   []string   `json:"providers"`      // 使用该接口的音乐源
+	State       string     `json:"state"`
+	Failures    int        `json:"failures"` // 连续失败次数
+	Requests    int64      `json:"requests"`
+	Errors      int64      `json:"errors"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	RetryAt     *time.Time `json:"retryAt,omitempty"` // 熔断打开时下一次试探的时间
+}
+
+// breaker 单个上游接口的熔断器
+type breaker struct {
+	mu          sync.Mutex
+	providers   map[string]bool
+	state       string
+	failures    int
+	cooldown    time.Duration
+	openedAt    time.Time
+	probing     bool // 半开状态下是否已有试探请求
+	requests    int64
+	errors      int64
+	lastError   string
+	lastFailure time.Time
+	lastSuccess time.Time
+}
+
+// breakerKey 熔断器对应的上游接口
+// 接口链中的接口按类型和地址区分，同一主机上的不同接口分别熔断，其他请求按主机名区分
+type breakerKey struct {
+	endpoint string
+	typ      string
+}
+
+// breakerKeyOf 返回请求对应的熔断器
+func breakerKeyOf(req *http.Request) breakerKey {
+	if e := scopeOf(req).endpoint; e != nil {
+		return breakerKey{endpoint: e.URL, typ: e.Type}
+	}
+	return breakerKey{endpoint: req.URL.Hostname()}
+}
+
+// breakers 按上游接口保存的熔断器
+var breakers = struct {
+	sync.Mutex
+	byKey map[breakerKey]*breaker
+}{byKey: make(map[breakerKey]*breaker)}
+
+// getBreaker 返回上游接口对应的熔断器，不存在时创建
+func getBreaker(key breakerKey, provider string) *breaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+
+	b, ok := breakers.byKey[key]
+	if !ok {
+		b = &breaker{providers: make(map[string]bool), state: BreakerClosed, cooldown: breakerMinCooldown}
+		breakers.byKey[key] = b
+	}
+	b.mu.Lock()
+	b.providers[provider] = true
+	b.mu.Unlock()
+	return b
+}
+
+// allow 判断是否可以发出请求，半开状态下只放行一个试探请求
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record 记录请求结果，err为空表示成功
+func (b *breaker) record(now time.Time, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.requests++
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		b.cooldown = breakerMinCooldown
+		b.probing = false
+		b.lastSuccess = now
+		return
+	}
+
+	b.errors++
+	b.failures++
+	b.lastError = err.Error()
+	b.lastFailure = now
+
+	switch {
+	case b.state == BreakerHalfOpen:
+		// 试探失败，加倍等待时间
+		b.cooldown = min(b.cooldown*2, breakerMaxCooldown)
+		b.state = BreakerOpen
+		b.openedAt = now
+		b.probing = false
+	case b.failures >= breakerFailureThreshold:
+		b.state = BreakerOpen
+		b.openedAt = now
+	}
+}
+
+// release 请求被调用者取消或超过调用者的截止时间，没有结果时允许再次试探
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// health 返回熔断器的状态
+func (b *breaker) health(key breakerKey) EndpointHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := EndpointHealth{
+		Endpoint:  key.endpoint,
+		Type:      key.typ,
+		State:     b.state,
+		Failures:  b.failures,
+		Requests:  b.requests,
+		Errors:    b.errors,
+		LastError: b.lastError,
+	}
+	for name := range b.providers {
+		h.Providers = append(h.Providers, name)
+	}
+	sort.Strings(h.Providers)
+	if !b.lastFailure.IsZero() {
+		t := b.lastFailure
+		h.LastFailure = &t
+	}
+	if !b.lastSuccess.IsZero() {
+		t := b.lastSuccess
+		h.LastSuccess = &t
+	}
+	if b.state == BreakerOpen {
+		t := b.openedAt.Add(b.cooldown)
+		h.RetryAt = &t
+	}
+	return h
+}
+
+// EndpointsHealth 返回所有请求过的上游接口的健康状态，按地址和类型排序
+func EndpointsHealth() []EndpointHealth {
+	breakers.Lock()
+	keys := make([]breakerKey, 0, len(breakers.byKey))
+	list := make(map[breakerKey]*breaker, len(breakers.byKey))
+	for key, b := range breakers.byKey {
+		keys = append(keys, key)
+		list[key] = b
+	}
+	breakers.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].typ < keys[j].typ
+	})
+	result := make([]EndpointHealth, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, list[key].health(key))
+	}
+	return result
+}
+
+// breakerTransport 为每个上游接口维护熔断器的RoundTripper
+// 网络错误、接口超时和5xx响应计为失败，熔断打开时直接返回ErrCircuitOpen
+type breakerTransport struct {
+	provider string
+	base     http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := breakerKeyOf(req)
+	b := getBreaker(key, t.provider)
+	if !b.allow(time.Now()) {
+		return nil, fmt.Errorf("%s: %w", key.endpoint, ErrCircuitOpen)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil && scopeOf(req).caller.Err() != nil:
+		// 调用者取消或到了调用者的截止时间，不代表上游故障
+		b.release()
+	case err != nil:
+		b.record(time.Now(), err)
+	case resp.StatusCode >= 500:
+		b.record(time.Now(), fmt.Errorf("上游返回状态码 %d", resp.StatusCode))
+	default:
+		b.record(time.Now(), nil)
+	}
+	return resp, err
+}
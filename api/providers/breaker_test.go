@@ -0,0 +1,264 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// resetBreakers 清空所有熔断器，避免测试之间互相影响
+func resetBreakers(t *testing.T) {
+	t.Helper()
+	breakers.Lock()
+	breakers.byKey = make(map[breakerKey]*breaker)
+	breakers.Unlock()
+}
+
+// breakerState 返回接口对应的熔断器的状态和连续失败次数
+func breakerState(key breakerKey) (string, int) {
+	breakers.Lock()
+	b, ok := breakers.byKey[key]
+	breakers.Unlock()
+	if !ok {
+		return "", 0
+	}
+	h := b.health(key)
+	return h.State, h.Failures
+}
+
+// get 通过客户端发送GET请求，成功时读完并关闭响应体
+func get(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestBreakerKeyedByEndpoint(t *testing.T) {
+	resetBreakers(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	transport := &breakerTransport{provider: "qq", base: http.DefaultTransport}
+	musicu := Endpoint{Type: EndpointQQ, URL: server.URL, Timeout: time.Second}
+	musics := Endpoint{Type: EndpointQQMusics, URL: server.URL, Timeout: time.Second}
+
+	for i := 0; i < breakerFailureThreshold; i++ {
+		get(context.Background(), musicu.client(transport), musicu.url("/fail"))
+	}
+	if err := get(context.Background(), musicu.client(transport), musicu.url("/ok")); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("连续失败后 err = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// 同一主机上的其他接口和接口链以外的请求不受影响
+	if err := get(context.Background(), musics.client(transport), musics.url("/ok")); err != nil {
+		t.Errorf("其他接口 err = %v", err)
+	}
+	if err := get(context.Background(), newClient(transport, time.Second), server.URL+"/ok"); err != nil {
+		t.Errorf("接口链以外的请求 err = %v", err)
+	}
+
+	health := EndpointsHealth()
+	if len(health) != 3 {
+		t.Fatalf("EndpointsHealth() = %+v", health)
+	}
+	states := make(map[string]string)
+	for _, h := range health {
+		states[h.Type] = h.State
+	}
+	want := map[string]string{EndpointQQ: BreakerOpen, EndpointQQMusics: BreakerClosed, "": BreakerClosed}
+	for typ, state := range want {
+		if states[typ] != state {
+			t.Errorf("类型 %q 的状态 = %q, want %q", typ, states[typ], state)
+		}
+	}
+}
+
+func TestBreakerCallerDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	transport := &breakerTransport{provider: "netease", base: http.DefaultTransport}
+
+	tests := []struct {
+		name         string
+		timeout      time.Duration // 接口的超时
+		callerCancel func(ctx context.Context) (context.Context, context.CancelFunc)
+		wantFailures int
+	}{
+		{
+			name:    "调用者的截止时间",
+			timeout: time.Minute,
+			callerCancel: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return context.WithTimeout(ctx, 20*time.Millisecond)
+			},
+			wantFailures: 0,
+		},
+		{
+			name:    "调用者取消",
+			timeout: time.Minute,
+			callerCancel: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(ctx)
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantFailures: 0,
+		},
+		{
+			name:    "接口超时",
+			timeout: 20 * time.Millisecond,
+			callerCancel: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return context.WithTimeout(ctx, time.Minute)
+			},
+			wantFailures: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetBreakers(t)
+			e := Endpoint{Type: EndpointNetease, URL: server.URL, Timeout: tt.timeout}
+			ctx, cancel := tt.callerCancel(context.Background())
+			defer cancel()
+
+			if err := get(ctx, e.client(transport), e.url("/")); err == nil {
+				t.Fatal("请求应当失败")
+			}
+			if _, failures := breakerState(breakerKey{endpoint: e.URL, typ: e.Type}); failures != tt.wantFailures {
+				t.Errorf("失败次数 = %d, want %d", failures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	failure := errors.New("上游返回状态码 503")
+	start := time.Unix(1700000000, 0)
+
+	type step struct {
+		after     time.Duration // 距开始的时间
+		op        string        // allow、success、failure、release
+		wantAllow bool          // op为allow时的期望结果
+		wantState string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "连续失败后打开",
+			steps: []step{
+				{0, "failure", false, BreakerClosed},
+				{0, "failure", false, BreakerClosed},
+				{0, "allow", true, BreakerClosed},
+				{0, "failure", false, BreakerOpen},
+				{breakerMinCooldown - time.Second, "allow", false, BreakerOpen},
+			},
+		},
+		{
+			name: "成功后重新计数",
+			steps: []step{
+				{0, "failure", false, BreakerClosed},
+				{0, "failure", false, BreakerClosed},
+				{0, "success", false, BreakerClosed},
+				{0, "failure", false, BreakerClosed},
+				{0, "failure", false, BreakerClosed},
+			},
+		},
+		{
+			name: "冷却后只放行一个试探请求，试探成功后关闭",
+			steps: []step{
+				{0, "failure", false, BreakerClosed},
+				{0, "failure", false, BreakerClosed},
+				{0, "failure", false, BreakerOpen},
+				{breakerMinCooldown, "allow", true, BreakerHalfOpen},
+				{breakerMinCooldown, "allow", false, BreakerHalfOpen},
+				{breakerMinCooldown, "success", false, BreakerClosed},
+				{breakerMinCooldown, "allow", true, BreakerClosed},
+			},
+		},
+		{
+			name: "试探失败后等待时间加倍",
+			steps: []step{
+				{0, "failure", false, BreakerClosed},
+				{0, "failure", false, BreakerClosed},
+				{0, "failure", false, BreakerOpen},
+				{breakerMinCooldown, "allow", true, BreakerHalfOpen},
+				{breakerMinCooldown, "failure", false, BreakerOpen},
+				{3*breakerMinCooldown - time.Second, "allow", false, BreakerOpen},
+				{3 * breakerMinCooldown, "allow", true, BreakerHalfOpen},
+			},
+		},
+		{
+			name: "试探请求被取消后允许再次试探",
+			steps: []step{
+				{0, "failure", false, BreakerClosed},
+				{0, "failure", false, BreakerClosed},
+				{0, "failure", false, BreakerOpen},
+				{breakerMinCooldown, "allow", true, BreakerHalfOpen},
+				{breakerMinCooldown, "release", false, BreakerHalfOpen},
+				{breakerMinCooldown, "allow", true, BreakerHalfOpen},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &breaker{providers: make(map[string]bool), state: BreakerClosed, cooldown: breakerMinCooldown}
+			for i, s := range tt.steps {
+				now := start.Add(s.after)
+				switch s.op {
+				case "allow":
+					if got := b.allow(now); got != s.wantAllow {
+						t.Fatalf("第%d步 allow() = %v, want %v", i+1, got, s.wantAllow)
+					}
+				case "success":
+					b.record(now, nil)
+				case "failure":
+					b.record(now, failure)
+				case "release":
+					b.release()
+				}
+				if b.state != s.wantState {
+					t.Fatalf("第%d步 %s 后状态 = %s, want %s", i+1, s.op, b.state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerCooldownLimit(t *testing.T) {
+	b := &breaker{providers: make(map[string]bool), state: BreakerOpen, cooldown: breakerMinCooldown}
+	now := time.Unix(1700000000, 0)
+	b.openedAt = now
+	for i := 0; i < 10; i++ {
+		now = now.Add(b.cooldown)
+		if !b.allow(now) {
+			t.Fatalf("第%d次冷却结束后没有放行试探请求", i+1)
+		}
+		b.record(now, errors.New("timeout"))
+	}
+	if b.cooldown != breakerMaxCooldown {
+		t.Errorf("cooldown = %s, want %s", b.cooldown, breakerMaxCooldown)
+	}
+	if h := b.health(breakerKey{endpoint: "example.com"}); h.RetryAt == nil || !h.RetryAt.Equal(now.Add(breakerMaxCooldown)) {
+		t.Errorf("RetryAt = %v, want %v", h.RetryAt, now.Add(breakerMaxCooldown))
+	}
+}
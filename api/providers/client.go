@@ -0,0 +1,304 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// 上游请求的默认设置
+const (
+	DefaultMaxRetries = 1                      // GET请求失败后的重试次数
+	DefaultRetryDelay = 500 * time.Millisecond // 第一次重试前的等待时间
+)
+
+// 连接池设置
+const (
+	maxIdleConns        = 100
+	maxIdleConnsPerHost = 10
+	idleConnTimeout     = 90 * time.Second
+	streamHeaderTimeout = 15 * time.Second // 请求音频时等待响应头的超时，音频数据本身不限时
+)
+
+// 连接池的种类
+const (
+	poolAPI          = iota // 请求音乐源接口
+	poolUncompressed        // 请求音乐源接口，禁用传输层压缩
+	poolStream              // 请求音频和封面
+)
+
+// ClientOptions 定义所有音乐源共用的HTTP客户端设置
+type ClientOptions struct {
+	Proxy      string        // 出站代理，支持http://、https://和socks5://，为空时使用HTTP_PROXY等环境变量
+	MaxRetries int           // GET请求遇到网络错误或502、503、504时的重试次数
+	RetryDelay time.Duration // 第一次重试前的等待时间，之后每次加倍
+}
+
+// RequestHook 每次向上游发出请求后调用，包括每次重试，被熔断跳过的请求不会调用，err不为空时resp为空
+// 钩子中不能读取或关闭响应体
+type RequestHook func(provider string, req *http.Request, resp *http.Response, err error, elapsed time.Duration)
+
+// 共用的客户端设置和连接池
+var clients = struct {
+	sync.RWMutex
+	opts  ClientOptions
+	pools []*http.Transport // 按连接池种类保存
+	hooks []RequestHook
+}{
+	opts:  ClientOptions{MaxRetries: DefaultMaxRetries, RetryDelay: DefaultRetryDelay},
+	pools: newPools(http.ProxyFromEnvironment),
+}
+
+// newPools 创建各种类的连接池，proxy为请求选择代理
+func newPools(proxy func(*http.Request) (*url.URL, error)) []*http.Transport {
+	api := http.DefaultTransport.(*http.Transport).Clone()
+	api.Proxy = proxy
+	api.MaxIdleConns = maxIdleConns
+	api.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	api.IdleConnTimeout = idleConnTimeout
+
+	uncompressed := api.Clone()
+	uncompressed.DisableCompression = true
+
+	stream := api.Clone()
+	stream.ResponseHeaderTimeout = streamHeaderTimeout
+
+	return []*http.Transport{poolAPI: api, poolUncompressed: uncompressed, poolStream: stream}
+}
+
+// SetClientOptions 检查并应用客户端设置，更换代理时关闭原有连接池中的空闲连接
+func SetClientOptions(opts ClientOptions) error {
+	if opts.MaxRetries < 0 {
+		return fmt.Errorf("无效的重试次数 %d", opts.MaxRetries)
+	}
+	if opts.RetryDelay < 0 {
+		return fmt.Errorf("无效的重试间隔 %s", opts.RetryDelay)
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		u, err := url.Parse(opts.Proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("无效的代理地址 %q", opts.Proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("不支持的代理协议 %q，可选 http、https、socks5", u.Scheme)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	clients.Lock()
+	old := clients.pools
+	clients.opts = opts
+	clients.pools = newPools(proxy)
+	clients.Unlock()
+
+	for _, pool := range old {
+		pool.CloseIdleConnections()
+	}
+	return nil
+}
+
+// AddRequestHook 添加请求钩子，用于记录日志或统计上游请求
+func AddRequestHook(hook RequestHook) {
+	clients.Lock()
+	defer clients.Unlock()
+	clients.hooks = append(clients.hooks, hook)
+}
+
+// LogRequest 记录每次上游请求的结果和耗时，可以作为请求钩子使用
+func LogRequest(provider string, req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	elapsed = elapsed.Round(time.Millisecond)
+	if err != nil {
+		log.Printf("[%s] %s %s%s 失败 (%s): %v", provider, req.Method, req.URL.Host, req.URL.Path, elapsed, err)
+		return
+	}
+	log.Printf("[%s] %s %s%s %d (%s)", provider, req.Method, req.URL.Host, req.URL.Path, resp.StatusCode, elapsed)
+}
+
+// StreamTransport 返回请求音频和封面使用的RoundTripper，与音乐源共用代理设置
+// 只限制等待响应头的时间，适合没有整体超时的客户端
+func StreamTransport() http.RoundTripper {
+	return pooledTransport{kind: poolStream}
+}
+
+// pooledTransport 使用当前的连接池发送请求，更换设置后立即生效
+type pooledTransport struct {
+	kind int
+}
+
+func (t pooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clients.RLock()
+	pool := clients.pools[t.kind]
+	clients.RUnlock()
+	return pool.RoundTrip(req)
+}
+
+// upstreamTransport 音乐源请求上游使用的RoundTripper
+// 补充音乐源的默认请求头，经过熔断器后按重试策略发送请求，熔断器只记录重试后的最终结果
+type upstreamTransport struct {
+	headers http.Header
+	breaker http.RoundTripper
+}
+
+// newUpstreamTransport 创建音乐源使用的RoundTripper，headers为请求中没有设置时使用的默认请求头
+func newUpstreamTransport(provider string, headers http.Header, pool int) *upstreamTransport {
+	return &upstreamTransport{
+		headers: headers,
+		breaker: &breakerTransport{
+			provider: provider,
+			base:     &retryTransport{provider: provider, base: pooledTransport{kind: pool}},
+		},
+	}
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper不能修改调用者的请求
+	req = req.Clone(req.Context())
+	for key, values := range t.headers {
+		if req.Header.Get(key) == "" {
+			req.Header[key] = values
+		}
+	}
+	return t.breaker.RoundTrip(req)
+}
+
+// retryTransport GET请求失败时按重试策略重试，每次请求后调用请求钩子
+type retryTransport struct {
+	provider string
+	base     http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clients.RLock()
+	opts := clients.opts
+	hooks := clients.hooks
+	clients.RUnlock()
+
+	delay := opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		resp, err := t.base.RoundTrip(req)
+		for _, hook := range hooks {
+			hook(t.provider, req, resp, err, time.Since(start))
+		}
+
+		if attempt >= opts.MaxRetries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			// 读完响应体以便复用连接
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			err = fmt.Errorf("上游返回状态码 %d", resp.StatusCode)
+		}
+		log.Printf("请求 %s 失败，%s 后重试(%d/%d): %v", req.URL.Host, delay, attempt+1, opts.MaxRetries, err)
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		delay *= 2
+	}
+}
+
+// shouldRetry 判断请求是否可以重试，只重试没有请求体的GET和HEAD请求
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		// 域名不存在时重试也会立即失败
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false
+		}
+		return !errors.Is(err, context.Canceled)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// newClient 返回使用共享连接池的HTTP客户端，timeout包括重试在内的总时间
+func newClient(transport http.RoundTripper, timeout time.Duration) *http.Client {
+	return &http.Client{Transport: &timeoutTransport{base: transport, timeout: timeout}}
+}
+
+// requestScope 上游请求所属的接口和调用者的上下文
+type requestScope struct {
+	caller   context.Context // 调用者的上下文，不包括客户端设置的超时
+	endpoint *Endpoint       // 接口链中的接口，其他请求为空
+}
+
+type requestScopeKey struct{}
+
+// scopeOf 返回请求所属的接口和调用者的上下文，没有记录时使用请求本身的上下文
+func scopeOf(req *http.Request) requestScope {
+	if scope, ok := req.Context().Value(requestScopeKey{}).(requestScope); ok {
+		return scope
+	}
+	return requestScope{caller: req.Context()}
+}
+
+// timeoutTransport 为请求设置超时，超时包括读取响应体的时间
+// 不使用http.Client.Timeout，以便熔断器区分调用者的截止时间和接口本身的超时
+type timeoutTransport struct {
+	base     http.RoundTripper
+	timeout  time.Duration
+	endpoint *Endpoint
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	scope := requestScope{caller: req.Context(), endpoint: t.endpoint}
+	ctx := context.WithValue(req.Context(), requestScopeKey{}, scope)
+	cancel := context.CancelFunc(func() {})
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody 关闭响应体时释放超时的计时器
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// 各音乐源请求上游使用的RoundTripper，默认请求头与播放音频时相同
+var (
+	neteaseTransport = newUpstreamTransport("netease", neteaseProvider{}.StreamHeaders(), poolAPI)
+	kuwoTransport    = newUpstreamTransport("kuwo", kuwoProvider{}.StreamHeaders(), poolAPI)
+	qqTransport      = newUpstreamTransport("qq", qqProvider{}.StreamHeaders(), poolAPI)
+
+	// qqUncompressedTransport 备用接口返回的压缩数据无法正常解压，禁用传输层压缩
+	qqUncompressedTransport = newUpstreamTransport("qq", qqProvider{}.StreamHeaders(), poolUncompressed)
+)
@@ -0,0 +1,124 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setTestClientOptions 设置测试使用的重试策略和请求钩子，测试结束后恢复
+func setTestClientOptions(t *testing.T, opts ClientOptions, hooks ...RequestHook) {
+	t.Helper()
+	clients.RLock()
+	oldOpts, oldHooks := clients.opts, clients.hooks
+	clients.RUnlock()
+	if err := SetClientOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	clients.Lock()
+	clients.hooks = hooks
+	clients.Unlock()
+
+	t.Cleanup(func() {
+		SetClientOptions(oldOpts)
+		clients.Lock()
+		clients.hooks = oldHooks
+		clients.Unlock()
+	})
+}
+
+func TestRetriesRecordOneBreakerOutcome(t *testing.T) {
+	resetBreakers(t)
+	var attempts, hookCalls atomic.Int32
+	setTestClientOptions(t, ClientOptions{MaxRetries: 2, RetryDelay: time.Millisecond},
+		func(string, *http.Request, *http.Response, error, time.Duration) { hookCalls.Add(1) })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	e := Endpoint{Type: EndpointKuwo, URL: server.URL, Timeout: time.Second}
+	client := e.client(newUpstreamTransport("kuwo", nil, poolAPI))
+	get(context.Background(), client, e.url("/"))
+
+	if attempts.Load() != 3 || hookCalls.Load() != 3 {
+		t.Errorf("请求次数 = %d, 钩子调用次数 = %d, want 3, 3", attempts.Load(), hookCalls.Load())
+	}
+	if state, failures := breakerState(breakerKey{endpoint: e.URL, typ: e.Type}); failures != 1 || state != BreakerClosed {
+		t.Errorf("熔断器 = %s, %d 次失败, want %s, 1 次失败", state, failures, BreakerClosed)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	setTestClientOptions(t, ClientOptions{MaxRetries: 1, RetryDelay: time.Millisecond})
+
+	tests := []struct {
+		name         string
+		method       string
+		statuses     []int // 每次请求返回的状态码
+		wantStatus   int
+		wantAttempts int32
+	}{
+		{"成功不重试", "GET", []int{200}, 200, 1},
+		{"503后重试成功", "GET", []int{503, 200}, 200, 2},
+		{"超过重试次数", "GET", []int{502, 504, 200}, 504, 2},
+		{"404不重试", "GET", []int{404, 200}, 404, 1},
+		{"POST不重试", "POST", []int{503, 200}, 503, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetBreakers(t)
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer server.Close()
+
+			req, err := http.NewRequest(tt.method, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := newClient(newUpstreamTransport("qq", nil, poolAPI), time.Second).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus || attempts.Load() != tt.wantAttempts {
+				t.Errorf("状态码 = %d, 请求次数 = %d, want %d, %d", resp.StatusCode, attempts.Load(), tt.wantStatus, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	setTestClientOptions(t, ClientOptions{})
+	resetBreakers(t)
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	transport := newUpstreamTransport("netease", http.Header{"Referer": {"https://music.163.com/"}, "User-Agent": {"default"}}, poolAPI)
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("User-Agent", "custom")
+	resp, err := newClient(transport, time.Second).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got.Get("Referer") != "https://music.163.com/" || got.Get("User-Agent") != "custom" {
+		t.Errorf("请求头 = %v", got)
+	}
+	if req.Header.Get("Referer") != "" {
+		t.Error("不应修改调用者的请求")
+	}
+}
@@ -0,0 +1,261 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 上游接口的类型，决定请求的路径、参数和响应的解析方式
+const (
+	EndpointNetease    = "netease"    // 网易云官方接口，如 https://music.163.com
+	EndpointNeteaseAPI = "neteaseapi" // NeteaseCloudMusicApi 兼容接口，如 https://musicapi.leanapp.cn
+	EndpointKuwo       = "kuwo"       // 酷我网页版接口，如 http://www.kuwo.cn
+	EndpointKuwoMobile = "kuwomobile" // 酷我移动版接口，如 http://m.kuwo.cn，只用于获取歌词
+	EndpointQQ         = "qq"         // QQ音乐 musicu.fcg 接口，如 https://u.y.qq.com
+	EndpointQQMusics   = "qqmusics"   // QQ音乐 musics.fcg 接口，只获取默认音质的URL
+	EndpointQQLyric    = "qqlyric"    // QQ音乐旧版歌词接口，如 https://c.y.qq.com
+	EndpointZhuolin    = "zhuolin"    // api.zhuolin.wang 兼容的第三方接口
+)
+
+// 接口链对应的操作
+const (
+	OpSearch = "search" // 搜索
+	OpURL    = "url"    // 获取播放地址
+	OpLyrics = "lyrics" // 获取歌词
+	OpImport = "import" // 导入歌单、专辑和歌曲
+)
+
+// 接口未设置超时时使用的默认值
+const defaultEndpointTimeout = 15 * time.Second
+
+// Endpoint 定义接口链中的一个上游接口
+type Endpoint struct {
+	Type    string            `yaml:"type"`
+	URL     string            `yaml:"url"`     // 接口的根地址，不含具体路径
+	Timeout time.Duration     `yaml:"timeout"` // 单次请求的超时，如 10s
+	Headers map[string]string `yaml:"headers"` // 覆盖默认请求头
+}
+
+// client 返回使用接口超时的HTTP客户端，请求按接口统计熔断状态
+func (e Endpoint) client(transport http.RoundTripper) *http.Client {
+	return &http.Client{Transport: &timeoutTransport{base: transport, timeout: e.Timeout, endpoint: &e}}
+}
+
+// url 拼接接口的根地址和路径
+func (e Endpoint) url(path string) string {
+	return strings.TrimSuffix(e.URL, "/") + path
+}
+
+// setHeaders 设置配置中的请求头，覆盖代码中设置的默认值
+func (e Endpoint) setHeaders(req *http.Request) {
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+}
+
+// EndpointChains 按音乐源和操作保存的接口链，请求时按顺序尝试直到成功
+type EndpointChains map[string]map[string][]Endpoint
+
+// endpointTypes 各音乐源的每个操作支持的接口类型
+var endpointTypes = map[string]map[string][]string{
+	"netease": {
+		OpSearch: {EndpointNetease, EndpointNeteaseAPI},
+		OpURL:    {EndpointNetease, EndpointNeteaseAPI},
+		OpLyrics: {EndpointNetease},
+		OpImport: {EndpointNetease},
+	},
+	"kuwo": {
+		OpSearch: {EndpointKuwo, EndpointNeteaseAPI},
+		OpURL:    {EndpointKuwo, EndpointNeteaseAPI},
+		OpLyrics: {EndpointKuwoMobile},
+		OpImport: {EndpointKuwo},
+	},
+	"qq": {
+		OpSearch: {EndpointQQ},
+		OpURL:    {EndpointQQ, EndpointQQMusics, EndpointZhuolin},
+		OpLyrics: {EndpointQQ, EndpointQQLyric},
+		OpImport: {EndpointQQ},
+	},
+}
+
+// DefaultEndpoints 返回内置的接口链，配置文件中没有设置的操作使用这些接口
+func DefaultEndpoints() EndpointChains {
+	return EndpointChains{
+		"netease": {
+			OpSearch: {
+				{Type: EndpointNetease, URL: "https://music.163.com"},
+				{Type: EndpointNeteaseAPI, URL: "https://musicapi.leanapp.cn"},
+			},
+			OpURL: {
+				{Type: EndpointNetease, URL: "https://music.163.com"},
+				{Type: EndpointNeteaseAPI, URL: "https://musicapi.leanapp.cn"},
+			},
+			OpLyrics: {
+				{Type: EndpointNetease, URL: "https://music.163.com"},
+			},
+			OpImport: {
+				{Type: EndpointNetease, URL: "https://music.163.com"},
+			},
+		},
+		"kuwo": {
+			OpSearch: {
+				{Type: EndpointKuwo, URL: "http://www.kuwo.cn"},
+				{Type: EndpointNeteaseAPI, URL: "https://musicapi.leanapp.cn"},
+			},
+			OpURL: {
+				{Type: EndpointKuwo, URL: "http://www.kuwo.cn"},
+				{Type: EndpointNeteaseAPI, URL: "https://musicapi.leanapp.cn"},
+			},
+			OpLyrics: {
+				{Type: EndpointKuwoMobile, URL: "http://m.kuwo.cn"},
+			},
+			OpImport: {
+				{Type: EndpointKuwo, URL: "http://www.kuwo.cn"},
+			},
+		},
+		"qq": {
+			OpSearch: {
+				{Type: EndpointQQ, URL: "https://u.y.qq.com", Timeout: 30 * time.Second},
+			},
+			OpURL: {
+				{Type: EndpointQQ, URL: "https://u.y.qq.com", Timeout: 30 * time.Second},
+				{Type: EndpointQQMusics, URL: "https://u.y.qq.com"},
+				{Type: EndpointZhuolin, URL: "https://api.zhuolin.wang"},
+			},
+			OpLyrics: {
+				{Type: EndpointQQ, URL: "https://u.y.qq.com"},
+				{Type: EndpointQQLyric, URL: "https://c.y.qq.com"},
+			},
+			OpImport: {
+				{Type: EndpointQQ, URL: "https://u.y.qq.com"},
+			},
+		},
+	}
+}
+
+// 当前使用的接口链
+var endpoints = struct {
+	sync.RWMutex
+	chains EndpointChains
+}{chains: withDefaultTimeouts(DefaultEndpoints())}
+
+// Endpoints 返回音乐源某个操作的接口链
+func Endpoints(provider, op string) []Endpoint {
+	endpoints.RLock()
+	defer endpoints.RUnlock()
+	return endpoints.chains[provider][op]
+}
+
+// SetEndpoints 检查并替换接口链，没有设置的音乐源和操作使用内置接口
+func SetEndpoints(chains EndpointChains) error {
+	merged := DefaultEndpoints()
+	for provider, ops := range chains {
+		types, ok := endpointTypes[provider]
+		if !ok {
+			return fmt.Errorf("%s: %w", provider, ErrUnknownProvider)
+		}
+		for op, chain := range ops {
+			allowed, ok := types[op]
+			if !ok {
+				return fmt.Errorf("%s: 不支持的操作 %q", provider, op)
+			}
+			if len(chain) == 0 {
+				return fmt.Errorf("%s.%s: 至少需要一个接口", provider, op)
+			}
+			for i, e := range chain {
+				if err := validateEndpoint(e, allowed); err != nil {
+					return fmt.Errorf("%s.%s[%d]: %w", provider, op, i, err)
+				}
+			}
+			merged[provider][op] = append([]Endpoint(nil), chain...)
+		}
+	}
+
+	endpoints.Lock()
+	endpoints.chains = withDefaultTimeouts(merged)
+	endpoints.Unlock()
+	return nil
+}
+
+// LoadEndpoints 从YAML文件读取接口链，文件有误时保留原有设置
+func LoadEndpoints(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取接口配置失败: %w", err)
+	}
+
+	var chains EndpointChains
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&chains); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("解析接口配置失败: %w", err)
+	}
+	return SetEndpoints(chains)
+}
+
+// validateEndpoint 检查接口的类型、地址和超时
+func validateEndpoint(e Endpoint, allowed []string) error {
+	supported := false
+	for _, t := range allowed {
+		if e.Type == t {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("不支持的接口类型 %q，可选 %s", e.Type, strings.Join(allowed, ", "))
+	}
+
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("无效的接口地址 %q", e.URL)
+	}
+	if e.Timeout < 0 {
+		return fmt.Errorf("无效的超时 %s", e.Timeout)
+	}
+	return nil
+}
+
+// withDefaultTimeouts 为没有设置超时的接口使用默认超时
+func withDefaultTimeouts(chains EndpointChains) EndpointChains {
+	for _, ops := range chains {
+		for _, chain := range ops {
+			for i := range chain {
+				if chain[i].Timeout == 0 {
+					chain[i].Timeout = defaultEndpointTimeout
+				}
+			}
+		}
+	}
+	return chains
+}
+
+// tryEndpoints 按顺序请求接口链中的接口，返回第一个成功的结果和最后一个接口的错误
+func tryEndpoints[T any](ctx context.Context, provider, op string, request func(e Endpoint) (T, error)) (T, error) {
+	var zero T
+	err := fmt.Errorf("%s 没有可用的 %s 接口", provider, op)
+	for _, e := range Endpoints(provider, op) {
+		var result T
+		result, err = request(e)
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return zero, ctx.Err()
+		}
+		log.Printf("%s 的 %s 接口 %s 请求失败: %v", provider, op, e.URL, err)
+	}
+	return zero, err
+}
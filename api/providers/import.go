@@ -0,0 +1,183 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"web_music/models"
+)
+
+// 导入歌单时每页请求的歌曲数量和最多导入的歌曲数量
+const (
+	importPageSize = 100
+	MaxImportSongs = 5000
+)
+
+// ErrUnrecognizedURL 无法识别的分享链接
+var ErrUnrecognizedURL = errors.New("无法识别的分享链接")
+
+// ImportKind 分享链接指向的内容类型
+type ImportKind string
+
+// 支持导入的内容类型
+const (
+	ImportPlaylist ImportKind = "playlist"
+	ImportAlbum    ImportKind = "album"
+	ImportSong     ImportKind = "song"
+)
+
+// ImportTarget 从分享链接中识别出的导入对象
+type ImportTarget struct {
+	Kind ImportKind
+	ID   string
+}
+
+// ImportResult 定义导入的歌单、专辑或单曲
+type ImportResult struct {
+	Name  string
+	Songs []models.Song
+	Total int // 上游返回的歌曲总数，超过导入上限时大于len(Songs)
+}
+
+// Importer 可选接口，识别分享链接并获取其中的全部歌曲
+type Importer interface {
+	// ParseShareURL 识别属于该音乐源的分享链接，无法识别时返回false
+	ParseShareURL(u *url.URL) (ImportTarget, bool)
+	// Import 分页获取歌单、专辑或单曲的全部歌曲
+	Import(ctx context.Context, target ImportTarget) (*ImportResult, error)
+}
+
+// 从分享文案中提取链接，如 "分享歌单《xxx》: https://y.qq.com/... (来自@QQ音乐)"
+var shareURLPattern = regexp.MustCompile(`https?://[^\s"'<>()（）]+`)
+
+// 需要跟随跳转才能得到实际地址的短链接域名
+var shortLinkHosts = map[string]bool{
+	"163cn.tv":   true,
+	"c.y.qq.com": true,
+	"url.cn":     true,
+}
+
+// ResolveShareURL 在所有启用的音乐源中识别分享链接，返回对应的音乐源和导入对象
+func ResolveShareURL(ctx context.Context, text string) (Provider, ImportTarget, error) {
+	raw := shareURLPattern.FindString(text)
+	if raw == "" {
+		return nil, ImportTarget{}, ErrUnrecognizedURL
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, ImportTarget{}, ErrUnrecognizedURL
+	}
+	if shortLinkHosts[strings.ToLower(u.Hostname())] {
+		u = followShortLink(ctx, u)
+	}
+
+	for _, p := range Enabled() {
+		importer, ok := p.(Importer)
+		if !ok {
+			continue
+		}
+		if target, ok := importer.ParseShareURL(u); ok {
+			return p, target, nil
+		}
+	}
+	return nil, ImportTarget{}, ErrUnrecognizedURL
+}
+
+// followShortLink 跟随短链接的跳转，失败时返回原链接
+func followShortLink(ctx context.Context, u *url.URL) *url.URL {
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return u
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 13_2_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.3 Mobile/15E148 Safari/604.1")
+
+	client := newClient(pooledTransport{kind: poolAPI}, 10*time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return u
+	}
+	resp.Body.Close()
+	return resp.Request.URL
+}
+
+// sharePath 返回链接的路径和查询参数，网易云的 /#/playlist?id= 形式的地址在fragment中
+func sharePath(u *url.URL) (string, url.Values) {
+	if strings.HasPrefix(u.Fragment, "/") {
+		if f, err := url.Parse(u.Fragment); err == nil {
+			return f.Path, f.Query()
+		}
+	}
+	return u.Path, u.Query()
+}
+
+// hostMatches 判断链接的域名是否为domain或其子域名
+func hostMatches(u *url.URL, domain string) bool {
+	host := strings.ToLower(u.Hostname())
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathID 返回路径中prefix之后的ID，如 /n/ryqq/playlist/123 中的123，去掉.html后缀
+func pathID(path, prefix string) string {
+	i := strings.Index(path, prefix)
+	if i < 0 {
+		return ""
+	}
+	id := path[i+len(prefix):]
+	if j := strings.IndexAny(id, "/?#"); j >= 0 {
+		id = id[:j]
+	}
+	return strings.TrimSuffix(id, ".html")
+}
+
+// newImportResult 构造导入结果，超过导入上限的歌曲会被截断
+func newImportResult(name string, songs []models.Song, total int) *ImportResult {
+	if songs == nil {
+		songs = []models.Song{}
+	}
+	if total < len(songs) {
+		total = len(songs)
+	}
+	if len(songs) > MaxImportSongs {
+		songs = songs[:MaxImportSongs]
+	}
+	return &ImportResult{Name: name, Songs: songs, Total: total}
+}
+
+// importPages 分页获取歌曲，直到取完total首、某一页为空或达到导入上限
+func importPages(ctx context.Context, fetch func(page int) (songs []models.Song, total int, err error)) ([]models.Song, int, error) {
+	var (
+		all   []models.Song
+		total int
+	)
+	for page := 1; len(all) < MaxImportSongs; page++ {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+
+		songs, pageTotal, err := fetch(page)
+		if err != nil {
+			// 第一页之后失败时返回已获取的歌曲
+			if page > 1 {
+				log.Printf("获取第%d页歌曲失败，只导入前%d首: %v", page, len(all), err)
+				return all, total, nil
+			}
+			return nil, 0, err
+		}
+		if pageTotal > total {
+			total = pageTotal
+		}
+		all = append(all, songs...)
+
+		if len(songs) == 0 || (total > 0 && page*importPageSize >= total) {
+			break
+		}
+	}
+	return all, total, nil
+}
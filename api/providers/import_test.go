@@ -0,0 +1,190 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"web_music/models"
+)
+
+func TestResolveShareURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		provider string
+		target   ImportTarget
+	}{
+		{"QQ音乐分享文案", "分享歌单《晴天》: https://y.qq.com/n/ryqq/playlist/7256912512 (来自@QQ音乐)", "qq", ImportTarget{ImportPlaylist, "7256912512"}},
+		{"QQ音乐全角括号", "https://y.qq.com/n/ryqq/songDetail/0039MnYb0qxYhV（来自@QQ音乐）", "qq", ImportTarget{ImportSong, "0039MnYb0qxYhV"}},
+		{"QQ音乐旧版专辑", "https://y.qq.com/n/yqq/album/002fRO0N4FftzY.html", "qq", ImportTarget{ImportAlbum, "002fRO0N4FftzY"}},
+		{"QQ音乐手机版歌单", "https://i.y.qq.com/n2/m/share/details/taoge.html?platform=11&id=7039&hosteuin=", "qq", ImportTarget{ImportPlaylist, "7039"}},
+		{"QQ音乐手机版歌曲", "https://i.y.qq.com/v8/playsong.html?songmid=001Qu4I30eVFYb&ADTAG=share", "qq", ImportTarget{ImportSong, "001Qu4I30eVFYb"}},
+		{"网易云网页版", "https://music.163.com/#/playlist?id=123", "netease", ImportTarget{ImportPlaylist, "123"}},
+		{"网易云网页版带斜杠", "https://music.163.com/#/album/?id=456", "netease", ImportTarget{ImportAlbum, "456"}},
+		{"网易云手机版", "分享单曲: https://y.music.163.com/m/song?id=33894312&userid=1 (来自@网易云音乐)", "netease", ImportTarget{ImportSong, "33894312"}},
+		{"网易云新版链接", "https://music.163.com/playlist/789/12345/?userid=12345", "netease", ImportTarget{ImportPlaylist, "789"}},
+		{"酷我歌单", "https://www.kuwo.cn/playlist_detail/3567349593", "kuwo", ImportTarget{ImportPlaylist, "3567349593"}},
+		{"酷我手机版专辑", "http://m.kuwo.cn/newh5app/album_detail/12345.html", "kuwo", ImportTarget{ImportAlbum, "12345"}},
+		{"酷我查询参数", "http://m.kuwo.cn/h5app/playlist?pid=999", "kuwo", ImportTarget{ImportPlaylist, "999"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, target, err := ResolveShareURL(context.Background(), tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Name() != tt.provider || target != tt.target {
+				t.Errorf("ResolveShareURL() = %s %+v, want %s %+v", p.Name(), target, tt.provider, tt.target)
+			}
+		})
+	}
+}
+
+func TestResolveShareURLUnrecognized(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"没有链接", "分享歌单《晴天》"},
+		{"其他网站", "https://example.com/playlist/1"},
+		{"相似的域名", "https://notqq.com/n/ryqq/playlist/1"},
+		{"不是导入页面", "https://www.kuwo.cn/search/list?key=晴天"},
+		{"网易云缺少ID", "https://music.163.com/#/playlist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ResolveShareURL(context.Background(), tt.text); !errors.Is(err, ErrUnrecognizedURL) {
+				t.Errorf("ResolveShareURL() error = %v, want %v", err, ErrUnrecognizedURL)
+			}
+		})
+	}
+
+	// 禁用的音乐源不识别
+	if err := SetEnabled("qq", false); err != nil {
+		t.Fatal(err)
+	}
+	defer SetEnabled("qq", true)
+	if _, _, err := ResolveShareURL(context.Background(), "https://y.qq.com/n/ryqq/playlist/1"); !errors.Is(err, ErrUnrecognizedURL) {
+		t.Errorf("禁用后 ResolveShareURL() error = %v, want %v", err, ErrUnrecognizedURL)
+	}
+}
+
+func TestParseShareURL(t *testing.T) {
+	tests := []struct {
+		importer Importer
+		raw      string
+		target   ImportTarget
+		ok       bool
+	}{
+		{neteaseProvider{}, "https://music.163.com/#/my/m/music/playlist?id=1", ImportTarget{ImportPlaylist, "1"}, true},
+		{neteaseProvider{}, "https://music.163.com/song/2.html", ImportTarget{ImportSong, "2"}, true},
+		{neteaseProvider{}, "https://y.qq.com/n/ryqq/playlist/1", ImportTarget{}, false},
+		{kuwoProvider{}, "https://www.kuwo.cn/play_detail/228908", ImportTarget{ImportSong, "228908"}, true},
+		{kuwoProvider{}, "https://www.kuwo.cn/album_detail/3.html?from=share", ImportTarget{ImportAlbum, "3"}, true},
+		{kuwoProvider{}, "https://m.kuwo.cn/h5app/album?albumId=4", ImportTarget{ImportAlbum, "4"}, true},
+		{kuwoProvider{}, "https://www.kuwo.cn/", ImportTarget{}, false},
+		{qqProvider{}, "https://y.qq.com/n/ryqq/albumDetail/5", ImportTarget{ImportAlbum, "5"}, true},
+		{qqProvider{}, "https://y.qq.com/n/m/detail/playsquare/6.html", ImportTarget{ImportPlaylist, "6"}, true},
+		{qqProvider{}, "https://i.y.qq.com/n2/m/share/details/album.html?albummid=7", ImportTarget{ImportAlbum, "7"}, true},
+		{qqProvider{}, "https://y.qq.com/", ImportTarget{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			u, err := url.Parse(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target, ok := tt.importer.ParseShareURL(u)
+			if ok != tt.ok || target != tt.target {
+				t.Errorf("ParseShareURL() = %+v, %v, want %+v, %v", target, ok, tt.target, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPathID(t *testing.T) {
+	tests := []struct {
+		path, prefix, want string
+	}{
+		{"/n/ryqq/playlist/123", "/playlist/", "123"},
+		{"/n/yqq/album/002fRO0N4FftzY.html", "/album/", "002fRO0N4FftzY"},
+		{"/playlist/123/456", "/playlist/", "123"},
+		{"/playlist/", "/playlist/", ""},
+		{"/album/123", "/playlist/", ""},
+	}
+	for _, tt := range tests {
+		if got := pathID(tt.path, tt.prefix); got != tt.want {
+			t.Errorf("pathID(%q, %q) = %q, want %q", tt.path, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+// testSongs 生成n首测试歌曲
+func testSongs(n int) []models.Song {
+	songs := make([]models.Song, n)
+	for i := range songs {
+		songs[i] = models.Song{ID: "1"}
+	}
+	return songs
+}
+
+func TestImportPages(t *testing.T) {
+	errPage := errors.New("请求失败")
+	tests := []struct {
+		name      string
+		pageSizes []int // 每页返回的歌曲数，超出时返回空页
+		total     int   // 上游返回的总数，为0时表示未知
+		failPage  int   // 在这一页返回错误
+		wantSongs int
+		wantTotal int
+		wantPages int
+		wantErr   bool
+	}{
+		{"按总数结束", []int{100, 100, 50}, 250, 0, 250, 250, 3, false},
+		{"总数为页大小的倍数", []int{100, 100, 100}, 200, 0, 200, 200, 2, false},
+		{"总数未知时遇到空页结束", []int{100, 30}, 0, 0, 130, 0, 3, false},
+		{"第一页为空", nil, 0, 0, 0, 0, 1, false},
+		{"第二页失败时返回已获取的歌曲", []int{100, 100}, 200, 2, 100, 200, 2, false},
+		{"第一页失败", []int{100}, 100, 1, 0, 0, 1, true},
+		{"达到导入上限", nil, 100000, 0, MaxImportSongs, 100000, MaxImportSongs / importPageSize, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := 0
+			songs, total, err := importPages(context.Background(), func(page int) ([]models.Song, int, error) {
+				pages++
+				if page == tt.failPage {
+					return nil, 0, errPage
+				}
+				if tt.pageSizes == nil && tt.total > 0 {
+					return testSongs(importPageSize), tt.total, nil
+				}
+				if page > len(tt.pageSizes) {
+					return nil, tt.total, nil
+				}
+				return testSongs(tt.pageSizes[page-1]), tt.total, nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("importPages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(songs) != tt.wantSongs || total != tt.wantTotal || pages != tt.wantPages {
+				t.Errorf("importPages() = %d首, 总数%d, 请求%d页, want %d首, 总数%d, 请求%d页",
+					len(songs), total, pages, tt.wantSongs, tt.wantTotal, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestImportPagesCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := importPages(ctx, func(page int) ([]models.Song, int, error) {
+		t.Error("取消后不应再请求")
+		return nil, 0, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("importPages() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -1,62 +1,96 @@
 package providers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/http/cookiejar"
-	"net/url"
-	"strings"
+	"sync"
 	"time"
 
-	"web_music/models"
+	"golang.org/x/sync/singleflight"
 )
 
 // 酷我音乐API常量
 const (
-	kuwoSearchURL = "http://www.kuwo.cn/api/www/search/searchMusicBykeyWord"
-	kuwoTokenURL  = "http://www.kuwo.cn/search/key"
-	kuwoPlayURL   = "http://www.kuwo.cn/api/v1/www/music/playUrl"
+	kuwoTokenPath = "/search/key" // 访问后在Cookie中返回Token，相对于酷我网页版接口的根地址
 	kuwoUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
-
-	// 备选API地址
-	kuwoMobileSearchURL = "http://mobilecdnbj.kugou.com/api/v3/search/song"
-	// kuwoMobilePlayURL   = "http://antiserver.kuwo.cn/anti.s?type=convert_url&format=mp3&response=url&rid=MUSIC_%s"
 )
 
-// 酷我音乐Token
-var (
-	kuwoToken    string
-	kuwoTokenExp time.Time
-	kuwoClient   *http.Client
-	retryCount   = 0
+// Token的有效期，过期后在下次请求时重新获取
+const (
+	kuwoTokenTTL        = 30 * time.Minute
+	kuwoTokenRetryDelay = time.Minute // 获取失败后等待多久再次尝试
 )
 
-func init() {
-	// 创建Cookie Jar
-	jar, _ := cookiejar.New(nil)
+// kuwoToken 酷我网页版接口的Token
+type kuwoToken struct {
+	value   string
+	expires time.Time
+}
 
-	// 创建HTTP客户端
-	kuwoClient = &http.Client{
-		Jar:     jar,
-		Timeout: 15 * time.Second,
+// 按接口地址保存的酷我音乐Token，第一次请求该接口时获取，同一接口同时只有一个刷新请求
+var kuwoTokens = struct {
+	sync.Mutex
+	byURL map[string]kuwoToken
+	group singleflight.Group
+}{byURL: make(map[string]kuwoToken)}
+
+// kuwoJar 酷我网页版接口共用的Cookie
+var kuwoJar, _ = cookiejar.New(nil)
+
+// getKuwoToken 返回接口的Token，过期时重新获取。已有Token时在后台刷新并继续返回原有的Token，
+// 第一次使用时等待获取完成，获取失败时返回原有的Token
+func getKuwoToken(ctx context.Context, e Endpoint) string {
+	kuwoTokens.Lock()
+	token := kuwoTokens.byURL[e.URL]
+	kuwoTokens.Unlock()
+	if time.Now().Before(token.expires) {
+		return token.value
+	}
+
+	// 刷新请求不随调用者取消，以免影响等待同一刷新的其他请求
+	ch := kuwoTokens.group.DoChan(e.URL, func() (interface{}, error) {
+		return updateKuwoToken(context.WithoutCancel(ctx), e), nil
+	})
+	if token.value != "" {
+		return token.value
+	}
+	select {
+	case res := <-ch:
+		return res.Val.(string)
+	case <-ctx.Done():
+		return ""
 	}
+}
 
-	// 初始获取一次Token
-	refreshKuwoToken()
+// updateKuwoToken 获取接口的Token并保存，返回最新可用的Token
+func updateKuwoToken(ctx context.Context, e Endpoint) string {
+	value, err := refreshKuwoToken(ctx, e)
+
+	kuwoTokens.Lock()
+	defer kuwoTokens.Unlock()
+	token := kuwoTokens.byURL[e.URL]
+	if err != nil {
+		log.Printf("获取酷我Token失败: %v", err)
+		token.expires = time.Now().Add(kuwoTokenRetryDelay)
+	} else {
+		token = kuwoToken{value: value, expires: time.Now().Add(kuwoTokenTTL)}
+	}
+	kuwoTokens.byURL[e.URL] = token
+	return token.value
 }
 
-// 刷新酷我音乐Token
-func refreshKuwoToken() {
+// refreshKuwoToken 请求酷我网页版接口，从Cookie中获取Token
+func refreshKuwoToken(ctx context.Context, e Endpoint) (string, error) {
 	log.Println("刷新酷我音乐Token")
 
 	// 创建请求
-	req, err := http.NewRequest("GET", kuwoTokenURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", e.url(kuwoTokenPath), nil)
 	if err != nil {
-		log.Printf("创建Token请求失败: %v", err)
-		return
+		return "", fmt.Errorf("创建Token请求失败: %w", err)
 	}
 
 	// 设置请求头
@@ -64,184 +98,23 @@ func refreshKuwoToken() {
 	req.Header.Set("Referer", "http://www.kuwo.cn/")
 	req.Header.Set("Accept", "*/*")
 	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
-	req.Header.Set("Connection", "keep-alive")
+	e.setHeaders(req)
 
-	// 发送请求
-	resp, err := kuwoClient.Do(req)
+	// 发送请求，使用接口的超时并与其他酷我请求共用Cookie
+	client := e.client(kuwoTransport)
+	client.Jar = kuwoJar
+	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("请求Token失败: %v", err)
-		return
+		return "", fmt.Errorf("请求Token失败: %w", err)
 	}
-	defer resp.Body.Close()
+	resp.Body.Close()
 
 	// 从Cookie中获取csrf Token
-	for _, cookie := range kuwoClient.Jar.Cookies(req.URL) {
+	for _, cookie := range client.Jar.Cookies(req.URL) {
 		if cookie.Name == "kw_token" {
-			kuwoToken = cookie.Value
-			break
-		}
-	}
-
-	// 设置Token过期时间
-	kuwoTokenExp = time.Now().Add(30 * time.Minute)
-	log.Printf("成功获取酷我Token: %s", kuwoToken)
-}
-
-// 使用标准API搜索
-func searchKuwoStandard(keyword string) ([]models.Song, error) {
-	// 构建请求URL
-	apiURL := fmt.Sprintf("%s?key=%s&pn=1&rn=20", kuwoSearchURL, url.QueryEscape(keyword))
-
-	// 创建请求
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	// 设置请求头
-	req.Header.Set("User-Agent", kuwoUserAgent)
-	req.Header.Set("Referer", "http://www.kuwo.cn/search/list?key="+url.QueryEscape(keyword))
-	req.Header.Set("csrf", kuwoToken)
-	req.Header.Set("Cookie", "kw_token="+kuwoToken)
-	req.Header.Set("Accept", "application/json, text/plain, */*")
-	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
-
-	// 发送请求
-	resp, err := kuwoClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	// 调试输出
-	log.Printf("酷我搜索响应: %s", string(body))
-
-	// 解析响应
-	var result struct {
-		Code int `json:"code"`
-		Data struct {
-			Total string `json:"total"`
-			List  []struct {
-				RID       string `json:"rid"`
-				Name      string `json:"name"`
-				Artist    string `json:"artist"`
-				AlbumName string `json:"album"`
-				PicURL    string `json:"pic"`
-				MusicRID  string `json:"musicrid"`
-			} `json:"list"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("解析JSON失败: %w", err)
-	}
-
-	// 检查响应状态
-	if result.Code != 200 {
-		return nil, fmt.Errorf("酷我音乐API返回错误代码: %d", result.Code)
-	}
-
-	// 检查是否有结果
-	if len(result.Data.List) == 0 {
-		return []models.Song{}, nil // 返回空数组而不是错误
-	}
-
-	// 转换为通用格式
-	var songs []models.Song
-	for _, item := range result.Data.List {
-		// 提取音乐ID
-		musicID := strings.TrimPrefix(item.MusicRID, "MUSIC_")
-
-		// 构建歌曲
-		song := models.Song{
-			ID:     musicID,
-			Title:  item.Name,
-			Artist: item.Artist,
-			Album:  item.AlbumName,
-			Cover:  item.PicURL,
-			Source: "kuwo",
-		}
-		songs = append(songs, song)
-	}
-
-	return songs, nil
-}
-
-// 使用移动端API搜索
-func searchKuwoMobile(keyword string) ([]models.Song, error) {
-	// 构建请求参数
-	params := url.Values{}
-	params.Set("keyword", keyword)
-	params.Set("page", "1")
-	params.Set("pagesize", "20")
-	params.Set("showtype", "1")
-
-	// 创建请求
-	req, err := http.NewRequest("GET", kuwoMobileSearchURL+"?"+params.Encode(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	// 设置请求头
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Linux; Android 10; SM-G981B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/80.0.3987.132 Mobile Safari/537.36")
-	req.Header.Set("Accept", "application/json")
-
-	// 发送请求
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	// 解析响应
-	var result struct {
-		Status int `json:"status"`
-		Data   struct {
-			Total int `json:"total"`
-			Info  []struct {
-				Hash       string `json:"hash"`
-				SongName   string `json:"songname"`
-				SingerName string `json:"singername"`
-				AlbumName  string `json:"album_name"`
-				AlbumID    string `json:"album_id"`
-				FileHash   string `json:"FileHash"`
-			} `json:"info"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("解析JSON失败: %w", err)
-	}
-
-	// 检查响应状态
-	if result.Status != 1 {
-		return nil, fmt.Errorf("酷我移动端API返回错误状态: %d", result.Status)
-	}
-
-	// 转换为通用格式
-	var songs []models.Song
-	for _, item := range result.Data.Info {
-		song := models.Song{
-			ID:     item.FileHash,
-			Title:  item.SongName,
-			Artist: item.SingerName,
-			Album:  item.AlbumName,
-			Source: "kuwo",
+			log.Printf("成功获取酷我Token: %s", cookie.Value)
+			return cookie.Value, nil
 		}
-		songs = append(songs, song)
 	}
-
-	return songs, nil
+	return "", errors.New("响应中没有Token")
 }
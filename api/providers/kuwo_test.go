@@ -0,0 +1,199 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setTestEndpoints 设置测试使用的接口链，测试结束后恢复
+func setTestEndpoints(t *testing.T, chains EndpointChains) {
+	t.Helper()
+	endpoints.RLock()
+	old := endpoints.chains
+	endpoints.RUnlock()
+	if err := SetEndpoints(chains); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		endpoints.Lock()
+		endpoints.chains = old
+		endpoints.Unlock()
+	})
+}
+
+// resetKuwoTokens 清空已获取的酷我Token，测试结束后恢复
+func resetKuwoTokens(t *testing.T) {
+	t.Helper()
+	kuwoTokens.Lock()
+	old := kuwoTokens.byURL
+	kuwoTokens.byURL = make(map[string]kuwoToken)
+	kuwoTokens.Unlock()
+	t.Cleanup(func() {
+		kuwoTokens.Lock()
+		kuwoTokens.byURL = old
+		kuwoTokens.Unlock()
+	})
+}
+
+func TestRefreshKuwoToken(t *testing.T) {
+	var path, header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, header = r.URL.Path, r.Header.Get("X-Test")
+		http.SetCookie(w, &http.Cookie{Name: "kw_token", Value: "token", Path: "/"})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		e       Endpoint
+		wantErr bool
+	}{
+		{"接口地址", Endpoint{Type: EndpointKuwo, URL: server.URL, Headers: map[string]string{"X-Test": "1"}}, false},
+		{"接口地址以斜杠结尾", Endpoint{Type: EndpointKuwo, URL: server.URL + "/", Headers: map[string]string{"X-Test": "1"}}, false},
+		{"无法连接", Endpoint{Type: EndpointKuwo, URL: "http://127.0.0.1:1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetBreakers(t)
+			path, header = "", ""
+
+			token, err := refreshKuwoToken(context.Background(), tt.e)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("refreshKuwoToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if token != "token" || path != kuwoTokenPath || header != "1" {
+				t.Errorf("token = %q, 请求路径 = %q, X-Test = %q", token, path, header)
+			}
+		})
+	}
+}
+
+func TestRequestKuwoWWW(t *testing.T) {
+	var tokenRequests atomic.Int32
+	var csrf string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == kuwoTokenPath {
+			tokenRequests.Add(1)
+			http.SetCookie(w, &http.Cookie{Name: "kw_token", Value: "token", Path: "/"})
+			return
+		}
+		csrf = r.Header.Get("csrf")
+		fmt.Fprintf(w, `{"code":200,"data":{"path":%q}}`, r.URL.RequestURI())
+	}))
+	defer server.Close()
+
+	resetBreakers(t)
+	resetKuwoTokens(t)
+	setTestEndpoints(t, EndpointChains{"kuwo": {OpImport: {
+		{Type: EndpointKuwo, URL: "http://127.0.0.1:1"},
+		{Type: EndpointKuwo, URL: server.URL},
+	}}})
+
+	for i := 0; i < 2; i++ {
+		data, err := requestKuwoWWW(context.Background(), "/api/www/music/musicInfo?mid=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data["path"] != "/api/www/music/musicInfo?mid=1" || csrf != "token" {
+			t.Errorf("请求路径 = %v, csrf = %q", data["path"], csrf)
+		}
+	}
+	if n := tokenRequests.Load(); n != 1 {
+		t.Errorf("Token请求次数 = %d, want 1", n)
+	}
+}
+
+// waitUntil 等待条件成立，超时后测试失败
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGetKuwoTokenRefresh(t *testing.T) {
+	var tokenRequests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		<-release
+		http.SetCookie(w, &http.Cookie{Name: "kw_token", Value: "new", Path: "/"})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	resetBreakers(t)
+	resetKuwoTokens(t)
+	e := Endpoint{Type: EndpointKuwo, URL: server.URL}
+	kuwoTokens.byURL[e.URL] = kuwoToken{value: "old", expires: time.Now().Add(-time.Second)}
+
+	// 刷新期间不等待，继续返回原有的Token，且只发出一个刷新请求
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token := getKuwoToken(context.Background(), e); token != "old" {
+				t.Errorf("刷新期间 getKuwoToken() = %q, want old", token)
+			}
+		}()
+	}
+	wg.Wait()
+	waitUntil(t, func() bool { return tokenRequests.Load() > 0 })
+	release <- struct{}{}
+	waitUntil(t, func() bool { return getKuwoToken(context.Background(), e) == "new" })
+	if n := tokenRequests.Load(); n != 1 {
+		t.Errorf("Token请求次数 = %d, want 1", n)
+	}
+}
+
+func TestGetKuwoTokenFirstUse(t *testing.T) {
+	var tokenRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		http.SetCookie(w, &http.Cookie{Name: "kw_token", Value: "token", Path: "/"})
+	}))
+	defer server.Close()
+
+	resetBreakers(t)
+	resetKuwoTokens(t)
+	e := Endpoint{Type: EndpointKuwo, URL: server.URL}
+
+	// 没有Token时等待同一个刷新请求完成
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token := getKuwoToken(context.Background(), e); token != "token" {
+				t.Errorf("getKuwoToken() = %q, want token", token)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := tokenRequests.Load(); n != 1 {
+		t.Errorf("Token请求次数 = %d, want 1", n)
+	}
+
+	// 调用者取消时不再等待
+	resetKuwoTokens(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if token := getKuwoToken(ctx, e); token != "" {
+		t.Errorf("取消后 getKuwoToken() = %q, want 空", token)
+	}
+}
@@ -1,33 +1,174 @@
 package providers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"web_music/lyrics"
 	"web_music/models"
 )
 
-// 使用国内稳定的音乐API聚合服务
-const (
-	// 此API支持网易云、QQ音乐和酷我等多个平台
-	apiBaseURL = "https://api.music.itooi.cn/v1"
-)
+// neteaseProvider 网易云音乐源
+type neteaseProvider struct{}
+
+func (neteaseProvider) Name() string        { return "netease" }
+func (neteaseProvider) DisplayName() string { return "网易云" }
+
+func (neteaseProvider) StreamHeaders() http.Header {
+	return http.Header{
+		"User-Agent": {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"},
+		"Referer":    {"https://music.163.com/"},
+	}
+}
+
+// ImageHosts 网易云的封面在 p1.music.126.net 等域名下
+func (neteaseProvider) ImageHosts() []string { return []string{"music.126.net"} }
+
+// URLTTL 网易云的播放地址通常20分钟后过期，接口的expi字段会给出准确时间
+func (neteaseProvider) URLTTL() time.Duration { return 20 * time.Minute }
+
+func (neteaseProvider) Search(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	return SearchNetease(ctx, q)
+}
+
+func (neteaseProvider) ResolveURL(ctx context.Context, id string, quality Quality) (*SongURL, error) {
+	return GetNeteaseURL(ctx, id, quality)
+}
+
+func (neteaseProvider) Lyrics(ctx context.Context, id string) (*models.Lyrics, error) {
+	return GetNeteaseLyrics(ctx, id)
+}
+
+func (neteaseProvider) KaraokeLyrics(ctx context.Context, id string) (string, string, error) {
+	text, err := GetNeteaseYRC(ctx, id)
+	return models.KaraokeYRC, text, err
+}
+
+// ParseShareURL 识别网易云的歌单、专辑和歌曲链接，如 music.163.com/#/playlist?id={id}
+func (neteaseProvider) ParseShareURL(u *url.URL) (ImportTarget, bool) {
+	if !hostMatches(u, "163.com") {
+		return ImportTarget{}, false
+	}
+
+	p, query := sharePath(u)
+	p = strings.TrimSuffix(p, "/")
+	for _, kind := range []ImportKind{ImportPlaylist, ImportAlbum, ImportSong} {
+		// 新版分享链接为 /playlist/{id}/{userid}
+		if id := pathID(p, "/"+string(kind)+"/"); id != "" {
+			return ImportTarget{kind, id}, true
+		}
+		if strings.HasSuffix(p, "/"+string(kind)) && query.Get("id") != "" {
+			return ImportTarget{kind, query.Get("id")}, true
+		}
+	}
+	return ImportTarget{}, false
+}
+
+func (neteaseProvider) Import(ctx context.Context, target ImportTarget) (*ImportResult, error) {
+	switch target.Kind {
+	case ImportPlaylist:
+		return ImportNeteasePlaylist(ctx, target.ID)
+	case ImportAlbum:
+		return ImportNeteaseAlbum(ctx, target.ID)
+	default:
+		return ImportNeteaseSong(ctx, target.ID)
+	}
+}
+
+// kuwoProvider 酷我音乐源
+type kuwoProvider struct{}
+
+func (kuwoProvider) Name() string        { return "kuwo" }
+func (kuwoProvider) DisplayName() string { return "酷我" }
+
+func (kuwoProvider) StreamHeaders() http.Header {
+	return http.Header{
+		"User-Agent": {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"},
+		"Referer":    {"http://www.kuwo.cn/"},
+	}
+}
 
-// SearchNetease 搜索网易云音乐
-func SearchNetease(keyword string) ([]models.Song, error) {
-	log.Printf("搜索网易云音乐(使用聚合API): %s", keyword)
+// ImageHosts 酷我的封面在 img1.kwcdn.kuwo.cn、star.kuwo.cn 等域名下
+func (kuwoProvider) ImageHosts() []string { return []string{"kuwo.cn"} }
 
-	// 尝试使用稳定的第三方API
-	apiURL := fmt.Sprintf("https://music.163.com/api/search/get?s=%s&type=1&limit=20&offset=0", url.QueryEscape(keyword))
+// URLTTL 酷我的接口不返回过期时间，按经验值处理
+func (kuwoProvider) URLTTL() time.Duration { return 30 * time.Minute }
+
+func (kuwoProvider) Search(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	return SearchKuwo(ctx, q)
+}
+
+func (kuwoProvider) ResolveURL(ctx context.Context, id string, quality Quality) (*SongURL, error) {
+	return GetKuwoURL(ctx, id, quality)
+}
+
+func (kuwoProvider) Lyrics(ctx context.Context, id string) (*models.Lyrics, error) {
+	return GetKuwoLyrics(ctx, id)
+}
+
+// ParseShareURL 识别酷我的歌单、专辑和歌曲链接，如 www.kuwo.cn/playlist_detail/{id}
+func (kuwoProvider) ParseShareURL(u *url.URL) (ImportTarget, bool) {
+	if !hostMatches(u, "kuwo.cn") {
+		return ImportTarget{}, false
+	}
+
+	p, query := sharePath(u)
+	switch {
+	case pathID(p, "/playlist_detail/") != "":
+		return ImportTarget{ImportPlaylist, pathID(p, "/playlist_detail/")}, true
+	case pathID(p, "/album_detail/") != "":
+		return ImportTarget{ImportAlbum, pathID(p, "/album_detail/")}, true
+	case pathID(p, "/play_detail/") != "":
+		return ImportTarget{ImportSong, pathID(p, "/play_detail/")}, true
+	case query.Get("pid") != "":
+		return ImportTarget{ImportPlaylist, query.Get("pid")}, true
+	case query.Get("albumId") != "":
+		return ImportTarget{ImportAlbum, query.Get("albumId")}, true
+	case query.Get("rid") != "":
+		return ImportTarget{ImportSong, strings.TrimPrefix(query.Get("rid"), "MUSIC_")}, true
+	}
+	return ImportTarget{}, false
+}
+
+func (kuwoProvider) Import(ctx context.Context, target ImportTarget) (*ImportResult, error) {
+	switch target.Kind {
+	case ImportPlaylist:
+		return ImportKuwoPlaylist(ctx, target.ID)
+	case ImportAlbum:
+		return ImportKuwoAlbum(ctx, target.ID)
+	default:
+		return ImportKuwoSong(ctx, target.ID)
+	}
+}
+
+// SearchNetease 搜索网易云音乐，按配置的接口链依次尝试
+func SearchNetease(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	q = q.Normalize()
+	return tryEndpoints(ctx, "netease", OpSearch, func(e Endpoint) (*SearchResult, error) {
+		if e.Type == EndpointNeteaseAPI {
+			return searchNeteaseAPI(ctx, e, q, "netease", neteaseSearchTypes[q.Type])
+		}
+		return searchNeteaseOfficial(ctx, e, q)
+	})
+}
+
+// searchNeteaseOfficial 使用网易云官方接口搜索
+func searchNeteaseOfficial(ctx context.Context, e Endpoint, q SearchQuery) (*SearchResult, error) {
+	log.Printf("搜索网易云音乐: %s (第%d页)", q.Keyword, q.Page)
+
+	apiURL := e.url(fmt.Sprintf("/api/search/get?s=%s&type=%d&limit=%d&offset=%d", url.QueryEscape(q.Keyword), neteaseSearchTypes[q.Type], q.PageSize, q.Offset()))
 
 	// 创建请求
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("创建网易云请求失败: %w", err)
 	}
@@ -36,149 +177,99 @@ func SearchNetease(keyword string) ([]models.Song, error) {
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
 	req.Header.Set("Referer", "https://music.163.com/")
 	req.Header.Set("Accept", "application/json")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := e.client(neteaseTransport).Do(req)
 	if err != nil {
-		log.Printf("网易云API请求失败: %v，尝试备用API", err)
-		return searchNeteaseBackup(keyword)
+		return nil, fmt.Errorf("网易云API请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("读取网易云响应失败: %v，尝试备用API", err)
-		return searchNeteaseBackup(keyword)
+		return nil, fmt.Errorf("读取网易云响应失败: %w", err)
 	}
 
 	// 解析响应
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("解析网易云响应失败: %v，尝试备用API", err)
-		return searchNeteaseBackup(keyword)
+		return nil, fmt.Errorf("解析网易云响应失败: %w", err)
 	}
 
 	// 检查响应状态
 	code, ok := result["code"].(float64)
 	if !ok || code != 200 {
-		log.Printf("网易云API返回错误码: %v，尝试备用API", code)
-		return searchNeteaseBackup(keyword)
+		return nil, fmt.Errorf("网易云API返回错误码: %v", code)
 	}
 
-	// 提取歌曲列表
-	var songs []models.Song
-	if resultObj, ok := result["result"].(map[string]interface{}); ok {
-		if songsObj, ok := resultObj["songs"].([]interface{}); ok {
-			for _, item := range songsObj {
-				song, ok := item.(map[string]interface{})
-				if !ok {
-					continue
-				}
-
-				// 获取歌曲ID
-				id, ok := song["id"].(float64)
-				if !ok {
-					continue
-				}
-
-				// 获取歌曲名称
-				name, ok := song["name"].(string)
-				if !ok {
-					continue
-				}
-
-				// 获取歌手信息
-				var artists []string
-				if artistsObj, ok := song["artists"].([]interface{}); ok {
-					for _, a := range artistsObj {
-						artist, ok := a.(map[string]interface{})
-						if !ok {
-							continue
-						}
-						if artistName, ok := artist["name"].(string); ok {
-							artists = append(artists, artistName)
-						}
-					}
-				}
-
-				// 获取专辑信息
-				albumName := ""
-				coverURL := ""
-				if album, ok := song["album"].(map[string]interface{}); ok {
-					if aName, ok := album["name"].(string); ok {
-						albumName = aName
-					}
-					if picUrl, ok := album["picUrl"].(string); ok {
-						coverURL = picUrl
-					}
-				}
-
-				// 创建歌曲对象
-				songs = append(songs, models.Song{
-					ID:     fmt.Sprintf("%.0f", id),
-					Title:  name,
-					Artist: strings.Join(artists, ", "),
-					Album:  albumName,
-					Cover:  coverURL,
-					Source: "netease",
-				})
-			}
-		}
-	}
-
-	if len(songs) == 0 {
-		log.Printf("网易云音乐搜索无结果，尝试备用API")
-		return searchNeteaseBackup(keyword)
-	}
-
-	return songs, nil
+	return parseNeteaseSearch(q, result, "netease")
 }
 
-// 备用网易云搜索API
-func searchNeteaseBackup(keyword string) ([]models.Song, error) {
-	log.Printf("使用备用API搜索 netease: %s", keyword)
+// searchNeteaseAPI 使用NeteaseCloudMusicApi兼容接口搜索，酷我的备用搜索也使用这类接口
+func searchNeteaseAPI(ctx context.Context, e Endpoint, q SearchQuery, source string, searchType int) (*SearchResult, error) {
+	log.Printf("使用 %s 搜索 %s: %s (第%d页)", e.URL, source, q.Keyword, q.Page)
 
-	apiURL := fmt.Sprintf("https://musicapi.leanapp.cn/search?keywords=%s&limit=20", url.QueryEscape(keyword))
+	apiURL := e.url(fmt.Sprintf("/search?keywords=%s&type=%d&limit=%d&offset=%d", url.QueryEscape(q.Keyword), searchType, q.PageSize, q.Offset()))
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
-		return []models.Song{}, fmt.Errorf("创建备用网易云请求失败: %w", err)
+		return nil, fmt.Errorf("创建备用搜索请求失败: %w", err)
 	}
 
 	// 设置请求头
 	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 13_2_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.3 Mobile/15E148 Safari/604.1")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
+	transport := neteaseTransport
+	if source == "kuwo" {
+		transport = kuwoTransport
+	}
+	resp, err := e.client(transport).Do(req)
 	if err != nil {
-		return []models.Song{}, nil // 返回空结果而不是错误
+		return nil, fmt.Errorf("备用搜索请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return []models.Song{}, nil
+		return nil, fmt.Errorf("读取备用搜索响应失败: %w", err)
 	}
 
 	// 解析响应
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return []models.Song{}, nil
+		return nil, fmt.Errorf("解析备用搜索响应失败: %w", err)
 	}
 
 	// 检查响应状态
 	code, ok := result["code"].(float64)
 	if !ok || code != 200 {
-		return []models.Song{}, nil
+		return nil, fmt.Errorf("备用搜索API返回错误码: %v", code)
+	}
+
+	return parseNeteaseSearch(q, result, source)
+}
+
+// parseNeteaseSearch 解析网易云格式的搜索结果，没有结果时返回空的结果
+func parseNeteaseSearch(q SearchQuery, result map[string]interface{}, source string) (*SearchResult, error) {
+	// 专辑、歌手、歌单结果
+	if collections := parseNeteaseCollections(q, result); collections != nil {
+		return collections, nil
 	}
 
 	// 提取歌曲列表
 	var songs []models.Song
+	total := 0
 	if resultObj, ok := result["result"].(map[string]interface{}); ok {
+		// 提取结果总数
+		if songCount, ok := resultObj["songCount"].(float64); ok {
+			total = int(songCount)
+		}
+
 		if songsObj, ok := resultObj["songs"].([]interface{}); ok {
 			for _, item := range songsObj {
 				song, ok := item.(map[string]interface{})
@@ -186,219 +277,725 @@ func searchNeteaseBackup(keyword string) ([]models.Song, error) {
 					continue
 				}
 
-				// 获取歌曲ID
-				id, ok := song["id"].(float64)
-				if !ok {
-					continue
+				if parsed, ok := parseNeteaseSong(song, source); ok {
+					songs = append(songs, parsed)
 				}
+			}
+		}
+	}
 
-				// 获取歌曲名称
-				name, ok := song["name"].(string)
-				if !ok {
-					continue
-				}
+	return newSearchResult(q, songs, total), nil
+}
 
-				// 获取歌手信息
-				var artists []string
-				if artistsObj, ok := song["artists"].([]interface{}); ok {
-					for _, a := range artistsObj {
-						artist, ok := a.(map[string]interface{})
-						if !ok {
-							continue
-						}
-						if artistName, ok := artist["name"].(string); ok {
-							artists = append(artists, artistName)
-						}
-					}
-				}
+// 解析网易云音乐的歌曲信息，兼容artists/album和ar/al两种字段格式
+func parseNeteaseSong(song map[string]interface{}, source string) (models.Song, bool) {
+	// 获取歌曲ID
+	id, ok := song["id"].(float64)
+	if !ok {
+		return models.Song{}, false
+	}
 
-				// 获取专辑信息
-				albumName := ""
-				coverURL := ""
-				if album, ok := song["album"].(map[string]interface{}); ok {
-					if aName, ok := album["name"].(string); ok {
-						albumName = aName
-					}
-					if picUrl, ok := album["picUrl"].(string); ok {
-						coverURL = picUrl
-					}
-				}
+	// 获取歌曲名称
+	name, ok := song["name"].(string)
+	if !ok {
+		return models.Song{}, false
+	}
+
+	parsed := models.Song{
+		ID:     fmt.Sprintf("%.0f", id),
+		Title:  name,
+		Source: source,
+	}
 
-				// 创建歌曲对象
-				songs = append(songs, models.Song{
-					ID:     fmt.Sprintf("%.0f", id),
-					Title:  name,
-					Artist: strings.Join(artists, ", "),
-					Album:  albumName,
-					Cover:  coverURL,
-					Source: "netease",
-				})
+	// 获取歌手信息
+	artistsObj, ok := song["artists"].([]interface{})
+	if !ok {
+		artistsObj, _ = song["ar"].([]interface{})
+	}
+	var artists []string
+	for _, a := range artistsObj {
+		artist, ok := a.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if artistName, ok := artist["name"].(string); ok {
+			artists = append(artists, artistName)
+		}
+		if artistID, ok := artist["id"].(float64); ok && artistID > 0 {
+			parsed.ArtistIDs = append(parsed.ArtistIDs, fmt.Sprintf("%.0f", artistID))
+		}
+	}
+	parsed.Artist = strings.Join(artists, ", ")
+
+	// 获取专辑信息
+	album, ok := song["album"].(map[string]interface{})
+	if !ok {
+		album, _ = song["al"].(map[string]interface{})
+	}
+	if album != nil {
+		if aName, ok := album["name"].(string); ok {
+			parsed.Album = aName
+		}
+		if picUrl, ok := album["picUrl"].(string); ok {
+			parsed.Cover = picUrl
+		}
+		if albumID, ok := album["id"].(float64); ok && albumID > 0 {
+			parsed.AlbumID = fmt.Sprintf("%.0f", albumID)
+		}
+	}
+
+	// 获取时长，网易云返回毫秒
+	duration, ok := song["duration"].(float64)
+	if !ok {
+		duration, _ = song["dt"].(float64)
+	}
+	parsed.Duration = int(duration / 1000)
+
+	// 获取可用音质
+	for _, item := range []struct {
+		keys    []string
+		quality string
+	}{
+		{[]string{"lMusic", "l"}, models.Quality128k},
+		{[]string{"hMusic", "h"}, models.Quality320k},
+		{[]string{"sqMusic", "sq"}, models.QualityFLAC},
+	} {
+		for _, key := range item.keys {
+			if info, ok := song[key].(map[string]interface{}); ok && info != nil {
+				parsed.Qualities = append(parsed.Qualities, item.quality)
+				break
 			}
 		}
 	}
 
-	return songs, nil
+	// 获取付费信息：1为会员歌曲，4为付费专辑
+	if fee, ok := song["fee"].(float64); ok && (fee == 1 || fee == 4) {
+		parsed.VIP = true
+	}
+
+	// 状态小于0表示歌曲已下架
+	if status, ok := song["status"].(float64); ok && status < 0 {
+		parsed.Unavailable = true
+	}
+
+	return parsed, true
+}
+
+// 网易云音乐搜索类型
+var neteaseSearchTypes = map[SearchType]int{
+	SearchTypeSong:     1,
+	SearchTypeAlbum:    10,
+	SearchTypeArtist:   100,
+	SearchTypePlaylist: 1000,
+	SearchTypeLyric:    1006,
+}
+
+// 解析网易云音乐的专辑、歌手、歌单搜索结果，歌曲和歌词搜索返回nil
+func parseNeteaseCollections(q SearchQuery, result map[string]interface{}) *SearchResult {
+	var listKey, countKey string
+	switch q.Type {
+	case SearchTypeAlbum:
+		listKey, countKey = "albums", "albumCount"
+	case SearchTypeArtist:
+		listKey, countKey = "artists", "artistCount"
+	case SearchTypePlaylist:
+		listKey, countKey = "playlists", "playlistCount"
+	default:
+		return nil
+	}
+
+	searchResult := &SearchResult{}
+	resultObj, ok := result["result"].(map[string]interface{})
+	if !ok {
+		return searchResult
+	}
+	if count, ok := resultObj[countKey].(float64); ok {
+		searchResult.Total = int(count)
+	}
+	list, _ := resultObj[listKey].([]interface{})
+
+	for _, item := range list {
+		info, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		id, ok := info["id"].(float64)
+		if !ok {
+			continue
+		}
+		name, ok := info["name"].(string)
+		if !ok {
+			continue
+		}
+
+		switch q.Type {
+		case SearchTypeAlbum:
+			artist := ""
+			if artistObj, ok := info["artist"].(map[string]interface{}); ok {
+				artist, _ = artistObj["name"].(string)
+			}
+			cover, _ := info["picUrl"].(string)
+			size, _ := info["size"].(float64)
+			publishTime := ""
+			if ts, ok := info["publishTime"].(float64); ok && ts > 0 {
+				publishTime = time.UnixMilli(int64(ts)).Format("2006-01-02")
+			}
+			searchResult.Albums = append(searchResult.Albums, models.Album{
+				ID:          fmt.Sprintf("%.0f", id),
+				Name:        name,
+				Artist:      artist,
+				Cover:       cover,
+				SongCount:   int(size),
+				PublishTime: publishTime,
+				Source:      "netease",
+			})
+		case SearchTypeArtist:
+			avatar, _ := info["picUrl"].(string)
+			if avatar == "" {
+				avatar, _ = info["img1v1Url"].(string)
+			}
+			songCount, _ := info["musicSize"].(float64)
+			albumCount, _ := info["albumSize"].(float64)
+			searchResult.Artists = append(searchResult.Artists, models.Artist{
+				ID:         fmt.Sprintf("%.0f", id),
+				Name:       name,
+				Avatar:     avatar,
+				SongCount:  int(songCount),
+				AlbumCount: int(albumCount),
+				Source:     "netease",
+			})
+		case SearchTypePlaylist:
+			creator := ""
+			if creatorObj, ok := info["creator"].(map[string]interface{}); ok {
+				creator, _ = creatorObj["nickname"].(string)
+			}
+			cover, _ := info["coverImgUrl"].(string)
+			trackCount, _ := info["trackCount"].(float64)
+			playCount, _ := info["playCount"].(float64)
+			searchResult.Playlists = append(searchResult.Playlists, models.Playlist{
+				ID:        fmt.Sprintf("%.0f", id),
+				Name:      name,
+				Creator:   creator,
+				Cover:     cover,
+				SongCount: int(trackCount),
+				PlayCount: int(playCount),
+				Source:    "netease",
+			})
+		}
+	}
+
+	return fixTotal(q, searchResult)
+}
+
+// neteaseBitrates 网易云各音质对应的br参数(bps)
+var neteaseBitrates = map[Quality]int{
+	QualityStandard: 128000,
+	QualityHigh:     320000,
+	QualityLossless: 999000,
+}
+
+// GetNeteaseURL 获取网易云音乐URL，按配置的接口链依次尝试
+func GetNeteaseURL(ctx context.Context, id string, quality Quality) (*SongURL, error) {
+	log.Printf("获取网易云音乐URL: %s (%s)", id, quality)
+
+	return tryEndpoints(ctx, "netease", OpURL, func(e Endpoint) (*SongURL, error) {
+		if e.Type == EndpointNeteaseAPI {
+			return getNeteaseAPIURL(ctx, e, id, fmt.Sprintf("br=%d", neteaseBitrates[quality]), neteaseTransport)
+		}
+		return getNeteaseOfficialURL(ctx, e, id, quality)
+	})
 }
 
-// GetNeteaseURL 获取网易云音乐URL
-func GetNeteaseURL(id string) (string, error) {
-	log.Printf("获取网易云音乐URL: %s", id)
+// getNeteaseOfficialURL 使用网易云官方接口获取URL，请求的音质不可用时依次尝试更低的音质
+func getNeteaseOfficialURL(ctx context.Context, e Endpoint, id string, quality Quality) (*SongURL, error) {
+	var err error
+	for _, tier := range quality.Fallbacks() {
+		var songURL *SongURL
+		songURL, err = requestNeteaseURL(ctx, e, id, neteaseBitrates[tier])
+		if err == nil {
+			return songURL, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("获取网易云%s音质URL失败: %v", tier, err)
+		if !errors.Is(err, errQualityUnavailable) {
+			break
+		}
+	}
+	return nil, err
+}
 
-	apiURL := fmt.Sprintf("https://music.163.com/api/song/enhance/player/url?ids=[%s]&br=320000", id)
+// requestNeteaseURL 按指定码率请求网易云音乐URL
+func requestNeteaseURL(ctx context.Context, e Endpoint, id string, br int) (*SongURL, error) {
+	apiURL := e.url(fmt.Sprintf("/api/song/enhance/player/url?ids=[%s]&br=%d", id, br))
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("创建网易云URL请求失败: %w", err)
+		return nil, fmt.Errorf("创建网易云URL请求失败: %w", err)
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
 	req.Header.Set("Referer", "https://music.163.com/")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := e.client(neteaseTransport).Do(req)
 	if err != nil {
-		log.Printf("获取网易云URL失败: %v，尝试备用API", err)
-		return getNeteaseURLBackup(id)
+		return nil, fmt.Errorf("网易云URL请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("读取网易云URL响应失败: %v，尝试备用API", err)
-		return getNeteaseURLBackup(id)
+		return nil, fmt.Errorf("读取网易云URL响应失败: %w", err)
 	}
 
 	// 解析响应
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("解析网易云URL响应失败: %v，尝试备用API", err)
-		return getNeteaseURLBackup(id)
+		return nil, fmt.Errorf("解析网易云URL响应失败: %w", err)
 	}
 
 	// 检查响应状态
 	code, ok := result["code"].(float64)
 	if !ok || code != 200 {
-		log.Printf("网易云URL API返回错误码: %v，尝试备用API", code)
-		return getNeteaseURLBackup(id)
+		return nil, fmt.Errorf("网易云URL API返回错误码: %v", code)
 	}
 
 	// 提取URL
 	if data, ok := result["data"].([]interface{}); ok && len(data) > 0 {
 		if item, ok := data[0].(map[string]interface{}); ok {
-			if url, ok := item["url"].(string); ok && url != "" {
-				return url, nil
+			if songURL, ok := parseSongURLItem(item); ok {
+				return songURL, nil
 			}
 		}
 	}
 
-	return getNeteaseURLBackup(id)
+	return nil, errQualityUnavailable
 }
 
-// 备用网易云音乐URL获取API
-func getNeteaseURLBackup(id string) (string, error) {
-	log.Printf("使用备用API获取网易云URL: %s", id)
+// getNeteaseAPIURL 使用NeteaseCloudMusicApi兼容接口获取URL，params为附加的查询参数
+// 酷我的备用接口也使用这类接口，通过source=kuwo参数区分
+func getNeteaseAPIURL(ctx context.Context, e Endpoint, id, params string, transport http.RoundTripper) (*SongURL, error) {
+	log.Printf("使用 %s 获取URL: %s", e.URL, id)
 
-	apiURL := fmt.Sprintf("https://musicapi.leanapp.cn/song/url?id=%s", id)
+	apiURL := e.url(fmt.Sprintf("/song/url?id=%s&%s", id, params))
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("创建备用网易云URL请求失败: %w", err)
+		return nil, fmt.Errorf("创建备用URL请求失败: %w", err)
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 13_2_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.3 Mobile/15E148 Safari/604.1")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := e.client(transport).Do(req)
 	if err != nil {
-		return "", fmt.Errorf("备用网易云URL请求失败: %w", err)
+		return nil, fmt.Errorf("备用URL请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取备用网易云URL响应失败: %w", err)
+		return nil, fmt.Errorf("读取备用URL响应失败: %w", err)
 	}
 
 	// 解析响应
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("解析备用网易云URL响应失败: %w", err)
+		return nil, fmt.Errorf("解析备用URL响应失败: %w", err)
 	}
 
 	// 检查响应状态
 	code, ok := result["code"].(float64)
 	if !ok || code != 200 {
-		return "", fmt.Errorf("备用网易云URL API返回错误码: %v", code)
+		return nil, fmt.Errorf("备用URL API返回错误码: %v", code)
 	}
 
 	// 提取URL
 	if data, ok := result["data"].([]interface{}); ok && len(data) > 0 {
 		if item, ok := data[0].(map[string]interface{}); ok {
-			if url, ok := item["url"].(string); ok && url != "" {
-				return url, nil
+			if songURL, ok := parseSongURLItem(item); ok {
+				return songURL, nil
 			}
 		}
 	}
 
-	return "", fmt.Errorf("无法获取网易云音乐URL")
+	return nil, fmt.Errorf("无法获取歌曲URL")
+}
+
+// GetNeteaseLyrics 获取网易云音乐歌词，包括翻译和罗马音，按配置的接口链依次尝试
+func GetNeteaseLyrics(ctx context.Context, id string) (*models.Lyrics, error) {
+	log.Printf("获取网易云歌词: %s", id)
+
+	return tryEndpoints(ctx, "netease", OpLyrics, func(e Endpoint) (*models.Lyrics, error) {
+		return requestNeteaseLyrics(ctx, e, id)
+	})
+}
+
+// requestNeteaseLyrics 使用网易云官方接口获取歌词
+func requestNeteaseLyrics(ctx context.Context, e Endpoint, id string) (*models.Lyrics, error) {
+	apiURL := e.url(fmt.Sprintf("/api/song/lyric?id=%s&lv=1&tv=1&rv=1", url.QueryEscape(id)))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建网易云歌词请求失败: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Referer", "https://music.163.com/")
+	e.setHeaders(req)
+
+	// 发送请求
+	resp, err := e.client(neteaseTransport).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("网易云歌词请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取网易云歌词响应失败: %w", err)
+	}
+
+	// 解析响应
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析网易云歌词响应失败: %w", err)
+	}
+
+	// 检查响应状态
+	code, ok := result["code"].(float64)
+	if !ok || code != 200 {
+		return nil, fmt.Errorf("网易云歌词API返回错误码: %v", code)
+	}
+
+	// 纯音乐或未收录歌词
+	if noLyric, _ := result["nolyric"].(bool); noLyric {
+		return nil, ErrLyricsNotFound
+	}
+
+	songLyrics := &models.Lyrics{
+		Source:       "netease",
+		ID:           id,
+		LRC:          neteaseLyricText(result["lrc"]),
+		Translation:  neteaseLyricText(result["tlyric"]),
+		Romanization: neteaseLyricText(result["romalrc"]),
+	}
+	if songLyrics.LRC == "" {
+		return nil, ErrLyricsNotFound
+	}
+
+	return songLyrics, nil
+}
+
+// GetNeteaseYRC 获取网易云的YRC逐字歌词，按配置的接口链依次尝试
+func GetNeteaseYRC(ctx context.Context, id string) (string, error) {
+	log.Printf("获取网易云逐字歌词: %s", id)
+
+	return tryEndpoints(ctx, "netease", OpLyrics, func(e Endpoint) (string, error) {
+		return requestNeteaseYRC(ctx, e, id)
+	})
+}
+
+// requestNeteaseYRC 使用网易云新版歌词接口获取逐字歌词，yv=1时返回逐字歌词
+func requestNeteaseYRC(ctx context.Context, e Endpoint, id string) (string, error) {
+	apiURL := e.url(fmt.Sprintf("/api/song/lyric/v1?id=%s&cp=false&lv=0&tv=0&rv=0&kv=0&yv=1&ytv=0&yrv=0", url.QueryEscape(id)))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建网易云逐字歌词请求失败: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Referer", "https://music.163.com/")
+	e.setHeaders(req)
+
+	// 发送请求
+	resp, err := e.client(neteaseTransport).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("网易云逐字歌词请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取网易云逐字歌词响应失败: %w", err)
+	}
+
+	// 解析响应
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析网易云逐字歌词响应失败: %w", err)
+	}
+
+	// 检查响应状态
+	code, ok := result["code"].(float64)
+	if !ok || code != 200 {
+		return "", fmt.Errorf("网易云逐字歌词API返回错误码: %v", code)
+	}
+
+	text := neteaseLyricText(result["yrc"])
+	if text == "" {
+		return "", ErrLyricsNotFound
+	}
+	return text, nil
+}
+
+// neteaseLyricText 提取网易云歌词对象中的歌词文本
+func neteaseLyricText(v interface{}) string {
+	if obj, ok := v.(map[string]interface{}); ok {
+		if text, ok := obj["lyric"].(string); ok {
+			return text
+		}
+	}
+	return ""
 }
 
-// SearchKuwo 搜索酷我音乐
-func SearchKuwo(keyword string) ([]models.Song, error) {
-	log.Printf("搜索酷我音乐(使用聚合API): %s", keyword)
+// ImportNeteasePlaylist 获取网易云歌单的全部歌曲，歌单详情只返回完整的歌曲ID列表，歌曲信息需要分页获取
+func ImportNeteasePlaylist(ctx context.Context, id string) (*ImportResult, error) {
+	log.Printf("导入网易云歌单: %s", id)
+
+	result, err := requestNeteaseAPI(ctx, fmt.Sprintf("/api/v6/playlist/detail?id=%s&n=100000&s=0", url.QueryEscape(id)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	playlist, ok := result["playlist"].(map[string]interface{})
+	if !ok {
+		return nil, ErrParseError
+	}
+	name, _ := playlist["name"].(string)
+
+	var ids []string
+	trackIDs, _ := playlist["trackIds"].([]interface{})
+	for _, item := range trackIDs {
+		if track, ok := item.(map[string]interface{}); ok {
+			if trackID := jsonString(track["id"]); trackID != "" {
+				ids = append(ids, trackID)
+			}
+		}
+	}
+
+	songs, _, err := importPages(ctx, func(page int) ([]models.Song, int, error) {
+		start := (page - 1) * importPageSize
+		if start >= len(ids) {
+			return nil, len(ids), nil
+		}
+		end := min(start+importPageSize, len(ids))
+		songs, err := getNeteaseSongDetails(ctx, ids[start:end])
+		return songs, len(ids), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newImportResult(name, songs, len(ids)), nil
+}
+
+// ImportNeteaseAlbum 获取网易云专辑的全部歌曲
+func ImportNeteaseAlbum(ctx context.Context, id string) (*ImportResult, error) {
+	log.Printf("导入网易云专辑: %s", id)
+
+	result, err := requestNeteaseAPI(ctx, "/api/v1/album/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ""
+	if album, ok := result["album"].(map[string]interface{}); ok {
+		name, _ = album["name"].(string)
+	}
+
+	var songs []models.Song
+	list, _ := result["songs"].([]interface{})
+	for _, item := range list {
+		if info, ok := item.(map[string]interface{}); ok {
+			if song, ok := parseNeteaseSong(info, "netease"); ok {
+				songs = append(songs, song)
+			}
+		}
+	}
+	return newImportResult(name, songs, len(songs)), nil
+}
+
+// ImportNeteaseSong 获取网易云单曲的信息
+func ImportNeteaseSong(ctx context.Context, id string) (*ImportResult, error) {
+	log.Printf("导入网易云歌曲: %s", id)
+
+	songs, err := getNeteaseSongDetails(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(songs) == 0 {
+		return nil, ErrParseError
+	}
+	return newImportResult(songs[0].Title, songs, 1), nil
+}
+
+// getNeteaseSongDetails 批量获取网易云歌曲的详细信息
+func getNeteaseSongDetails(ctx context.Context, ids []string) ([]models.Song, error) {
+	c := make([]map[string]string, 0, len(ids))
+	for _, id := range ids {
+		c = append(c, map[string]string{"id": id})
+	}
+	cJSON, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("构建请求参数失败: %w", err)
+	}
+
+	result, err := requestNeteaseAPI(ctx, "/api/v3/song/detail", url.Values{"c": {string(cJSON)}})
+	if err != nil {
+		return nil, err
+	}
+
+	var songs []models.Song
+	list, _ := result["songs"].([]interface{})
+	for _, item := range list {
+		if info, ok := item.(map[string]interface{}); ok {
+			if song, ok := parseNeteaseSong(info, "netease"); ok {
+				songs = append(songs, song)
+			}
+		}
+	}
+	return songs, nil
+}
+
+// requestNeteaseAPI 按导入使用的接口链请求网易云的接口，path为相对于接口根地址的路径，form不为空时使用POST表单提交
+func requestNeteaseAPI(ctx context.Context, path string, form url.Values) (map[string]interface{}, error) {
+	return tryEndpoints(ctx, "netease", OpImport, func(e Endpoint) (map[string]interface{}, error) {
+		return requestNeteaseEndpoint(ctx, e, path, form)
+	})
+}
+
+// requestNeteaseEndpoint 请求网易云官方接口，返回解析后的响应
+func requestNeteaseEndpoint(ctx context.Context, e Endpoint, path string, form url.Values) (map[string]interface{}, error) {
+	method, body := "GET", io.Reader(nil)
+	if form != nil {
+		method, body = "POST", strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, e.url(path), body)
+	if err != nil {
+		return nil, fmt.Errorf("创建网易云请求失败: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Referer", "https://music.163.com/")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	e.setHeaders(req)
+
+	// 发送请求
+	resp, err := e.client(neteaseTransport).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("网易云请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取网易云响应失败: %w", err)
+	}
+
+	// 解析响应
+	var result map[string]interface{}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析网易云响应失败: %w", err)
+	}
+
+	// 检查响应状态
+	code, ok := result["code"].(float64)
+	if !ok || code != 200 {
+		return nil, fmt.Errorf("网易云API返回错误码: %v", code)
+	}
+	return result, nil
+}
+
+// SearchKuwo 搜索酷我音乐，按配置的接口链依次尝试
+func SearchKuwo(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	q = q.Normalize()
+	if _, ok := kuwoCollectionSearchPaths[q.Type]; !ok && q.Type != SearchTypeSong {
+		return nil, ErrUnsupportedSearchType
+	}
+
+	return tryEndpoints(ctx, "kuwo", OpSearch, func(e Endpoint) (*SearchResult, error) {
+		switch {
+		case e.Type == EndpointNeteaseAPI && q.Type == SearchTypeSong:
+			return searchNeteaseAPI(ctx, e, q, "kuwo", 1002)
+		case e.Type == EndpointNeteaseAPI:
+			// 备用接口只支持搜索歌曲
+			return nil, ErrUnsupportedSearchType
+		case q.Type == SearchTypeSong:
+			return searchKuwoSongs(ctx, e, q)
+		default:
+			return searchKuwoCollections(ctx, e, q)
+		}
+	})
+}
+
+// searchKuwoSongs 使用酷我网页版接口搜索歌曲
+func searchKuwoSongs(ctx context.Context, e Endpoint, q SearchQuery) (*SearchResult, error) {
+	log.Printf("搜索酷我音乐: %s (第%d页)", q.Keyword, q.Page)
 
 	// 构建请求
-	apiURL := fmt.Sprintf("http://www.kuwo.cn/api/www/search/searchMusicBykeyWord?key=%s&pn=1&rn=20", url.QueryEscape(keyword))
+	apiURL := e.url(fmt.Sprintf("/api/www/search/searchMusicBykeyWord?key=%s&pn=%d&rn=%d", url.QueryEscape(q.Keyword), q.Page, q.PageSize))
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("创建酷我请求失败: %w", err)
 	}
 
 	// 设置请求头
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-	req.Header.Set("Referer", "http://www.kuwo.cn/search/list?key="+url.QueryEscape(keyword))
+	req.Header.Set("Referer", "http://www.kuwo.cn/search/list?key="+url.QueryEscape(q.Keyword))
 	req.Header.Set("Cookie", "kw_token=JQOEP7QK8RS")
 	req.Header.Set("csrf", "JQOEP7QK8RS")
 	req.Header.Set("Accept", "application/json")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := e.client(kuwoTransport).Do(req)
 	if err != nil {
-		log.Printf("酷我API请求失败: %v，尝试备用API", err)
-		return searchKuwoBackup(keyword)
+		return nil, fmt.Errorf("酷我API请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("读取酷我响应失败: %v，尝试备用API", err)
-		return searchKuwoBackup(keyword)
+		return nil, fmt.Errorf("读取酷我响应失败: %w", err)
 	}
 
 	// 解析响应
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("解析酷我响应失败: %v，尝试备用API", err)
-		return searchKuwoBackup(keyword)
+		return nil, fmt.Errorf("解析酷我响应失败: %w", err)
 	}
 
 	// 检查响应状态
 	code, ok := result["code"].(float64)
 	if !ok || code != 200 {
-		log.Printf("酷我API返回错误码: %v，尝试备用API", code)
-		return searchKuwoBackup(keyword)
+		return nil, fmt.Errorf("酷我API返回错误码: %v", code)
 	}
 
 	// 提取歌曲列表
 	var songs []models.Song
+	total := 0
 	if data, ok := result["data"].(map[string]interface{}); ok {
+		// 提取结果总数，酷我返回的总数是字符串
+		if totalStr, ok := data["total"].(string); ok {
+			total, _ = strconv.Atoi(totalStr)
+		}
+
 		if list, ok := data["list"].([]interface{}); ok {
 			for _, item := range list {
 				song, ok := item.(map[string]interface{})
@@ -406,164 +1003,273 @@ func SearchKuwo(keyword string) ([]models.Song, error) {
 					continue
 				}
 
-				// 获取歌曲ID
-				rid, ok := song["rid"].(float64)
-				if !ok {
-					continue
+				if parsed, ok := parseKuwoSong(song); ok {
+					songs = append(songs, parsed)
 				}
+			}
+		}
+	}
 
-				// 获取歌曲名称
-				name, ok := song["name"].(string)
-				if !ok {
-					continue
-				}
+	return newSearchResult(q, songs, total), nil
+}
 
-				// 获取歌手
-				artist, ok := song["artist"].(string)
-				if !ok {
-					artist = ""
-				}
+// 解析酷我音乐的歌曲信息
+func parseKuwoSong(song map[string]interface{}) (models.Song, bool) {
+	// 获取歌曲ID
+	rid := jsonString(song["rid"])
+	if rid == "" {
+		return models.Song{}, false
+	}
 
-				// 获取专辑
-				album, ok := song["album"].(string)
-				if !ok {
-					album = ""
-				}
+	// 获取歌曲名称
+	name, ok := song["name"].(string)
+	if !ok {
+		return models.Song{}, false
+	}
 
-				// 获取封面
-				pic, ok := song["pic"].(string)
-				if !ok {
-					pic = ""
-				}
+	parsed := models.Song{
+		ID:     rid,
+		Title:  name,
+		Source: "kuwo",
+	}
 
-				// 创建歌曲对象
-				songs = append(songs, models.Song{
-					ID:     fmt.Sprintf("%.0f", rid),
-					Title:  name,
-					Artist: artist,
-					Album:  album,
-					Cover:  pic,
-					Source: "kuwo",
-				})
-			}
-		}
+	// 获取歌手、专辑和封面
+	parsed.Artist, _ = song["artist"].(string)
+	parsed.Album, _ = song["album"].(string)
+	parsed.Cover, _ = song["pic"].(string)
+	parsed.AlbumID = jsonString(song["albumid"])
+	if artistID := jsonString(song["artistid"]); artistID != "" {
+		parsed.ArtistIDs = []string{artistID}
 	}
 
-	if len(songs) == 0 {
-		log.Printf("酷我音乐搜索无结果，尝试备用API")
-		return searchKuwoBackup(keyword)
+	// 获取时长，酷我返回秒
+	duration, _ := strconv.Atoi(jsonString(song["duration"]))
+	parsed.Duration = duration
+
+	// 酷我的歌曲都有128k和320k音质，无损需要单独判断
+	parsed.Qualities = []string{models.Quality128k, models.Quality320k}
+	if hasLossless, ok := song["hasLossless"].(bool); ok && hasLossless {
+		parsed.Qualities = append(parsed.Qualities, models.QualityFLAC)
 	}
 
-	return songs, nil
+	// 获取付费信息
+	if isListenFee, ok := song["isListenFee"].(bool); ok && isListenFee {
+		parsed.VIP = true
+	}
+
+	return parsed, true
+}
+
+// 酷我音乐专辑、歌手、歌单搜索接口的路径
+var kuwoCollectionSearchPaths = map[SearchType]string{
+	SearchTypeAlbum:    "/api/www/search/searchAlbumBykeyWord",
+	SearchTypeArtist:   "/api/www/search/searchArtistBykeyWord",
+	SearchTypePlaylist: "/api/www/search/searchPlayListBykeyWord",
 }
 
-// 备用酷我搜索API
-func searchKuwoBackup(keyword string) ([]models.Song, error) {
-	log.Printf("使用备用API搜索 kuwo: %s", keyword)
+// 搜索酷我音乐的专辑、歌手、歌单，酷我不支持按歌词搜索
+func searchKuwoCollections(ctx context.Context, e Endpoint, q SearchQuery) (*SearchResult, error) {
+	path, ok := kuwoCollectionSearchPaths[q.Type]
+	if !ok {
+		return nil, ErrUnsupportedSearchType
+	}
+
+	log.Printf("搜索酷我音乐%s: %s (第%d页)", q.Type, q.Keyword, q.Page)
 
-	apiURL := fmt.Sprintf("https://musicapi.leanapp.cn/search?keywords=%s&type=1002&limit=20", url.QueryEscape(keyword))
+	apiURL := e.url(fmt.Sprintf("%s?key=%s&pn=%d&rn=%d", path, url.QueryEscape(q.Keyword), q.Page, q.PageSize))
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
-		return []models.Song{}, fmt.Errorf("创建备用酷我请求失败: %w", err)
+		return nil, fmt.Errorf("创建酷我请求失败: %w", err)
 	}
 
 	// 设置请求头
-	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 13_2_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.3 Mobile/15E148 Safari/604.1")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Referer", "http://www.kuwo.cn/search/list?key="+url.QueryEscape(q.Keyword))
+	req.Header.Set("Cookie", "kw_token=JQOEP7QK8RS")
+	req.Header.Set("csrf", "JQOEP7QK8RS")
+	req.Header.Set("Accept", "application/json")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := e.client(kuwoTransport).Do(req)
 	if err != nil {
-		return []models.Song{}, nil // 返回空结果而不是错误
+		return nil, fmt.Errorf("酷我API请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return []models.Song{}, nil
+		return nil, fmt.Errorf("读取酷我响应失败: %w", err)
 	}
 
 	// 解析响应
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return []models.Song{}, nil
+		return nil, fmt.Errorf("解析酷我响应失败: %w", err)
 	}
 
-	// 提取歌曲列表
-	var songs []models.Song
-	if resultObj, ok := result["result"].(map[string]interface{}); ok {
-		if songs, ok := resultObj["songs"].([]interface{}); ok {
-			for _, item := range songs {
-				song, ok := item.(map[string]interface{})
-				if !ok {
-					continue
-				}
-
-				// 获取歌曲ID
-				id, ok := song["id"].(float64)
-				if !ok {
-					continue
-				}
-
-				// 获取歌曲名称
-				name, ok := song["name"].(string)
-				if !ok {
-					continue
-				}
+	// 检查响应状态
+	code, ok := result["code"].(float64)
+	if !ok || code != 200 {
+		return nil, fmt.Errorf("酷我API返回错误码: %v", code)
+	}
 
-				// 获取歌手信息
-				var artists []string
-				if artistsObj, ok := song["ar"].([]interface{}); ok {
-					for _, a := range artistsObj {
-						artist, ok := a.(map[string]interface{})
-						if !ok {
-							continue
-						}
-						if artistName, ok := artist["name"].(string); ok {
-							artists = append(artists, artistName)
-						}
-					}
-				}
+	searchResult := &SearchResult{}
+	data, ok := result["data"].(map[string]interface{})
+	if !ok {
+		return fixTotal(q, searchResult), nil
+	}
 
-				// 获取专辑信息
-				albumName := ""
-				coverURL := ""
-				if album, ok := song["al"].(map[string]interface{}); ok {
-					if aName, ok := album["name"].(string); ok {
-						albumName = aName
-					}
-					if picUrl, ok := album["picUrl"].(string); ok {
-						coverURL = picUrl
-					}
-				}
+	// 提取结果总数，酷我返回的总数是字符串
+	if totalStr, ok := data["total"].(string); ok {
+		searchResult.Total, _ = strconv.Atoi(totalStr)
+	}
 
-				// 创建歌曲对象
-				songs = append(songs, models.Song{
-					ID:     fmt.Sprintf("%.0f", id),
-					Title:  name,
-					Artist: strings.Join(artists, ", "),
-					Album:  albumName,
-					Cover:  coverURL,
-					Source: "kuwo",
-				})
+	switch q.Type {
+	case SearchTypeAlbum:
+		list, _ := data["albumList"].([]interface{})
+		for _, item := range list {
+			info, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id := jsonString(info["albumid"])
+			name, _ := info["album"].(string)
+			if id == "" || name == "" {
+				continue
+			}
+			artist, _ := info["artist"].(string)
+			cover, _ := info["pic"].(string)
+			releaseDate, _ := info["releaseDate"].(string)
+			searchResult.Albums = append(searchResult.Albums, models.Album{
+				ID:          id,
+				Name:        name,
+				Artist:      artist,
+				Cover:       cover,
+				PublishTime: releaseDate,
+				Source:      "kuwo",
+			})
+		}
+	case SearchTypeArtist:
+		list, _ := data["artistList"].([]interface{})
+		for _, item := range list {
+			info, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id := jsonString(info["id"])
+			name, _ := info["name"].(string)
+			if id == "" || name == "" {
+				continue
 			}
+			avatar, _ := info["pic"].(string)
+			songCount, _ := strconv.Atoi(jsonString(info["musicNum"]))
+			albumCount, _ := strconv.Atoi(jsonString(info["albumNum"]))
+			searchResult.Artists = append(searchResult.Artists, models.Artist{
+				ID:         id,
+				Name:       name,
+				Avatar:     avatar,
+				SongCount:  songCount,
+				AlbumCount: albumCount,
+				Source:     "kuwo",
+			})
+		}
+	case SearchTypePlaylist:
+		list, _ := data["list"].([]interface{})
+		for _, item := range list {
+			info, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id := jsonString(info["id"])
+			name, _ := info["name"].(string)
+			if id == "" || name == "" {
+				continue
+			}
+			creator, _ := info["uname"].(string)
+			cover, _ := info["img"].(string)
+			songCount, _ := strconv.Atoi(jsonString(info["total"]))
+			playCount, _ := strconv.Atoi(jsonString(info["listencnt"]))
+			searchResult.Playlists = append(searchResult.Playlists, models.Playlist{
+				ID:        id,
+				Name:      name,
+				Creator:   creator,
+				Cover:     cover,
+				SongCount: songCount,
+				PlayCount: playCount,
+				Source:    "kuwo",
+			})
 		}
 	}
 
-	return songs, nil
+	return fixTotal(q, searchResult), nil
 }
 
-// GetKuwoURL 获取酷我音乐URL
-func GetKuwoURL(id string) (string, error) {
-	log.Printf("获取酷我音乐URL: %s", id)
+// 将JSON中可能是字符串或数字的字段统一转换为字符串
+func jsonString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// kuwoQualities 酷我各音质对应的br参数和码率(kbps)
+var kuwoQualities = map[Quality]struct {
+	br      string
+	bitrate int
+	format  string
+}{
+	QualityStandard: {"128kmp3", 128, "mp3"},
+	QualityHigh:     {"320kmp3", 320, "mp3"},
+	QualityLossless: {"2000kflac", 0, "flac"},
+}
 
-	apiURL := fmt.Sprintf("http://www.kuwo.cn/api/v1/www/music/playUrl?mid=%s&type=convert_url3&br=320kmp3", id)
+// GetKuwoURL 获取酷我音乐URL，按配置的接口链依次尝试
+func GetKuwoURL(ctx context.Context, id string, quality Quality) (*SongURL, error) {
+	log.Printf("获取酷我音乐URL: %s (%s)", id, quality)
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	return tryEndpoints(ctx, "kuwo", OpURL, func(e Endpoint) (*SongURL, error) {
+		if e.Type == EndpointNeteaseAPI {
+			return getNeteaseAPIURL(ctx, e, id, "source=kuwo", kuwoTransport)
+		}
+		return getKuwoWWWURL(ctx, e, id, quality)
+	})
+}
+
+// getKuwoWWWURL 使用酷我网页版接口获取URL，请求的音质不可用时依次尝试更低的音质
+func getKuwoWWWURL(ctx context.Context, e Endpoint, id string, quality Quality) (*SongURL, error) {
+	var err error
+	for _, tier := range quality.Fallbacks() {
+		var songURL *SongURL
+		songURL, err = requestKuwoURL(ctx, e, id, tier)
+		if err == nil {
+			return songURL, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("获取酷我%s音质URL失败: %v", tier, err)
+		if !errors.Is(err, errQualityUnavailable) {
+			break
+		}
+	}
+	return nil, err
+}
+
+// requestKuwoURL 按指定音质请求酷我音乐URL
+func requestKuwoURL(ctx context.Context, e Endpoint, id string, quality Quality) (*SongURL, error) {
+	kq := kuwoQualities[quality]
+	apiURL := e.url(fmt.Sprintf("/api/v1/www/music/playUrl?mid=%s&type=convert_url3&br=%s", id, kq.br))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("创建酷我URL请求失败: %w", err)
+		return nil, fmt.Errorf("创建酷我URL请求失败: %w", err)
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
@@ -571,94 +1277,252 @@ func GetKuwoURL(id string) (string, error) {
 	req.Header.Set("Cookie", "kw_token=JQOEP7QK8RS")
 	req.Header.Set("csrf", "JQOEP7QK8RS")
 	req.Header.Set("Accept", "application/json")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := e.client(kuwoTransport).Do(req)
 	if err != nil {
-		log.Printf("获取酷我URL失败: %v，尝试备用API", err)
-		return getKuwoURLBackup(id)
+		return nil, fmt.Errorf("酷我URL请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("读取酷我URL响应失败: %v，尝试备用API", err)
-		return getKuwoURLBackup(id)
+		return nil, fmt.Errorf("读取酷我URL响应失败: %w", err)
 	}
 
 	// 解析响应
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("解析酷我URL响应失败: %v，尝试备用API", err)
-		return getKuwoURLBackup(id)
+		return nil, fmt.Errorf("解析酷我URL响应失败: %w", err)
 	}
 
 	// 检查响应状态
 	code, ok := result["code"].(float64)
 	if !ok || code != 200 {
-		log.Printf("酷我URL API返回错误码: %v，尝试备用API", code)
-		return getKuwoURLBackup(id)
+		return nil, fmt.Errorf("酷我URL API返回错误码: %v", code)
 	}
 
 	// 提取URL
-	if data, ok := result["data"].(map[string]interface{}); ok {
-		if url, ok := data["url"].(string); ok && url != "" {
-			return url, nil
-		}
+	data, _ := result["data"].(map[string]interface{})
+	audioURL, _ := data["url"].(string)
+	if audioURL == "" {
+		return nil, errQualityUnavailable
+	}
+
+	// 无损音质不可用时接口可能返回其他格式的文件
+	format := formatFromURL(audioURL)
+	if format != "" && format != kq.format {
+		return nil, errQualityUnavailable
 	}
 
-	return getKuwoURLBackup(id)
+	return &SongURL{
+		URL:     audioURL,
+		Quality: quality,
+		Bitrate: kq.bitrate,
+		Format:  kq.format,
+	}, nil
 }
 
-// 备用酷我音乐URL获取API
-func getKuwoURLBackup(id string) (string, error) {
-	log.Printf("使用备用API获取酷我URL: %s", id)
+// GetKuwoLyrics 获取酷我音乐歌词，按配置的接口链依次尝试
+func GetKuwoLyrics(ctx context.Context, id string) (*models.Lyrics, error) {
+	log.Printf("获取酷我歌词: %s", id)
+
+	return tryEndpoints(ctx, "kuwo", OpLyrics, func(e Endpoint) (*models.Lyrics, error) {
+		return requestKuwoLyrics(ctx, e, id)
+	})
+}
 
-	apiURL := fmt.Sprintf("https://musicapi.leanapp.cn/song/url?id=%s&source=kuwo", id)
+// requestKuwoLyrics 使用酷我移动版接口获取歌词
+func requestKuwoLyrics(ctx context.Context, e Endpoint, id string) (*models.Lyrics, error) {
+	apiURL := e.url(fmt.Sprintf("/newh5/singles/songinfoandlrc?musicId=%s&httpsStatus=1", url.QueryEscape(id)))
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("创建备用酷我URL请求失败: %w", err)
+		return nil, fmt.Errorf("创建酷我歌词请求失败: %w", err)
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 13_2_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.3 Mobile/15E148 Safari/604.1")
+	req.Header.Set("Referer", "http://m.kuwo.cn/")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := e.client(kuwoTransport).Do(req)
 	if err != nil {
-		return "", fmt.Errorf("备用酷我URL请求失败: %w", err)
+		return nil, fmt.Errorf("酷我歌词请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取备用酷我URL响应失败: %w", err)
+		return nil, fmt.Errorf("读取酷我歌词响应失败: %w", err)
 	}
 
 	// 解析响应
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("解析备用酷我URL响应失败: %w", err)
+		return nil, fmt.Errorf("解析酷我歌词响应失败: %w", err)
 	}
 
 	// 检查响应状态
-	code, ok := result["code"].(float64)
-	if !ok || code != 200 {
-		return "", fmt.Errorf("备用酷我URL API返回错误码: %v", code)
+	status, ok := result["status"].(float64)
+	if !ok || status != 200 {
+		return nil, fmt.Errorf("酷我歌词API返回错误码: %v", result["status"])
 	}
 
-	// 提取URL
-	if data, ok := result["data"].([]interface{}); ok && len(data) > 0 {
-		if item, ok := data[0].(map[string]interface{}); ok {
-			if url, ok := item["url"].(string); ok && url != "" {
-				return url, nil
+	data, _ := result["data"].(map[string]interface{})
+	lrclist, _ := data["lrclist"].([]interface{})
+	if len(lrclist) == 0 {
+		return nil, ErrLyricsNotFound
+	}
+
+	// 酷我返回逐行的歌词列表，转换为LRC格式
+	// 带翻译的歌词中，翻译紧跟在原文之后且时间相同
+	var lrc, trans strings.Builder
+	lastTime := -1
+	for _, item := range lrclist {
+		line, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		text, _ := line["lineLyric"].(string)
+		seconds, err := strconv.ParseFloat(jsonString(line["time"]), 64)
+		if err != nil {
+			continue
+		}
+		ms := int(seconds * 1000)
+
+		if ms == lastTime {
+			trans.WriteString(lyrics.FormatTime(ms) + text + "\n")
+			continue
+		}
+		lrc.WriteString(lyrics.FormatTime(ms) + text + "\n")
+		lastTime = ms
+	}
+
+	return &models.Lyrics{
+		Source:      "kuwo",
+		ID:          id,
+		LRC:         lrc.String(),
+		Translation: trans.String(),
+	}, nil
+}
+
+// ImportKuwoPlaylist 分页获取酷我歌单中的全部歌曲
+func ImportKuwoPlaylist(ctx context.Context, id string) (*ImportResult, error) {
+	log.Printf("导入酷我歌单: %s", id)
+	return importKuwoList(ctx, "/api/www/playlist/playListInfo?pid="+url.QueryEscape(id), "name")
+}
+
+// ImportKuwoAlbum 分页获取酷我专辑中的全部歌曲
+func ImportKuwoAlbum(ctx context.Context, id string) (*ImportResult, error) {
+	log.Printf("导入酷我专辑: %s", id)
+	return importKuwoList(ctx, "/api/www/album/albumInfo?albumId="+url.QueryEscape(id), "album")
+}
+
+// ImportKuwoSong 获取酷我单曲的信息
+func ImportKuwoSong(ctx context.Context, id string) (*ImportResult, error) {
+	log.Printf("导入酷我歌曲: %s", id)
+
+	data, err := requestKuwoWWW(ctx, "/api/www/music/musicInfo?mid="+url.QueryEscape(id)+"&httpsStatus=1")
+	if err != nil {
+		return nil, err
+	}
+
+	song, ok := parseKuwoSong(data)
+	if !ok {
+		return nil, ErrParseError
+	}
+	return newImportResult(song.Title, []models.Song{song}, 1), nil
+}
+
+// importKuwoList 分页获取酷我歌单或专辑的歌曲，basePath为不含分页参数的接口路径，nameKey为名称在响应中的字段
+func importKuwoList(ctx context.Context, basePath, nameKey string) (*ImportResult, error) {
+	name := ""
+	songs, total, err := importPages(ctx, func(page int) ([]models.Song, int, error) {
+		data, err := requestKuwoWWW(ctx, fmt.Sprintf("%s&pn=%d&rn=%d&httpsStatus=1", basePath, page, importPageSize))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if name == "" {
+			name, _ = data[nameKey].(string)
+		}
+		// 酷我返回的总数可能是数字或字符串
+		total, _ := strconv.Atoi(jsonString(data["total"]))
+
+		var songs []models.Song
+		list, _ := data["musicList"].([]interface{})
+		for _, item := range list {
+			if info, ok := item.(map[string]interface{}); ok {
+				if song, ok := parseKuwoSong(info); ok {
+					songs = append(songs, song)
+				}
 			}
 		}
+		return songs, total, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newImportResult(name, songs, total), nil
+}
+
+// requestKuwoWWW 按导入使用的接口链请求酷我网页版接口，path为相对于接口根地址的路径，返回响应中的data
+func requestKuwoWWW(ctx context.Context, path string) (map[string]interface{}, error) {
+	return tryEndpoints(ctx, "kuwo", OpImport, func(e Endpoint) (map[string]interface{}, error) {
+		return requestKuwoWWWEndpoint(ctx, e, path)
+	})
+}
+
+// requestKuwoWWWEndpoint 请求酷我网页版接口，使用该接口的Token
+func requestKuwoWWWEndpoint(ctx context.Context, e Endpoint, path string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", e.url(path), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建酷我请求失败: %w", err)
 	}
 
-	return "", fmt.Errorf("无法获取酷我音乐URL")
+	// 设置请求头
+	req.Header.Set("User-Agent", kuwoUserAgent)
+	req.Header.Set("Referer", "http://www.kuwo.cn/")
+	token := getKuwoToken(ctx, e)
+	req.Header.Set("csrf", token)
+	req.Header.Set("Cookie", "kw_token="+token)
+	req.Header.Set("Accept", "application/json, text/plain, */*")
+	e.setHeaders(req)
+
+	// 发送请求，与获取Token的请求共用Cookie
+	client := e.client(kuwoTransport)
+	client.Jar = kuwoJar
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("酷我API请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取酷我响应失败: %w", err)
+	}
+
+	// 解析响应
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析酷我响应失败: %w", err)
+	}
+
+	// 检查响应状态
+	code, ok := result["code"].(float64)
+	if !ok || code != 200 {
+		return nil, fmt.Errorf("酷我API返回错误码: %v", code)
+	}
+
+	data, ok := result["data"].(map[string]interface{})
+	if !ok {
+		return nil, ErrParseError
+	}
+	return data, nil
 }
@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSearchNeteaseFailover(t *testing.T) {
+	tests := []struct {
+		name       string
+		primary    string
+		wantBackup bool
+		wantSongs  int
+	}{
+		{"没有结果时不切换接口", `{"code":200,"result":{"songCount":0}}`, false, 0},
+		{"返回错误码时切换接口", `{"code":-460}`, true, 1},
+		{"响应格式错误时切换接口", `<html></html>`, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.primary))
+			}))
+			defer primary.Close()
+			var backupCalls atomic.Int32
+			backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				backupCalls.Add(1)
+				w.Write([]byte(`{"code":200,"result":{"songCount":1,"songs":[{"id":1,"name":"晴天"}]}}`))
+			}))
+			defer backup.Close()
+
+			resetBreakers(t)
+			setTestEndpoints(t, EndpointChains{"netease": {OpSearch: {
+				{Type: EndpointNetease, URL: primary.URL},
+				{Type: EndpointNeteaseAPI, URL: backup.URL},
+			}}})
+
+			result, err := SearchNetease(context.Background(), SearchQuery{Keyword: "晴天"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (backupCalls.Load() > 0) != tt.wantBackup {
+				t.Errorf("备用接口请求次数 = %d, wantBackup %v", backupCalls.Load(), tt.wantBackup)
+			}
+			if len(result.Songs) != tt.wantSongs {
+				t.Errorf("歌曲数 = %d, want %d", len(result.Songs), tt.wantSongs)
+			}
+		})
+	}
+}
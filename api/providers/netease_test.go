@@ -0,0 +1,41 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetCsrfFromCookies(t *testing.T) {
+	var requests atomic.Int32
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		header = r.Header.Get("X-Test")
+		http.SetCookie(w, &http.Cookie{Name: "__csrf", Value: "csrf", Path: "/"})
+	}))
+	defer server.Close()
+
+	resetBreakers(t)
+	e := Endpoint{Type: EndpointNetease, URL: server.URL, Headers: map[string]string{"X-Test": "1"}}
+	for i := 0; i < 2; i++ {
+		if csrf := getCsrfFromCookies(context.Background(), e); csrf != "csrf" {
+			t.Errorf("getCsrfFromCookies() = %q, want csrf", csrf)
+		}
+	}
+	if requests.Load() != 1 || header != "1" {
+		t.Errorf("主页请求次数 = %d, X-Test = %q", requests.Load(), header)
+	}
+
+	// 调用者取消时不发出请求，下次读取时重试
+	other := Endpoint{Type: EndpointNetease, URL: server.URL + "/"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	initNeteaseSession(ctx, other)
+	initNeteaseSession(context.Background(), other)
+	if requests.Load() != 2 {
+		t.Errorf("主页请求次数 = %d, want 2", requests.Load())
+	}
+}
@@ -0,0 +1,193 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"web_music/models"
+)
+
+// Provider 音乐源的统一接口
+type Provider interface {
+	// Name 返回音乐源标识，如 qq、netease、kuwo
+	Name() string
+	// Search 按关键词分页搜索歌曲
+	Search(ctx context.Context, q SearchQuery) (*SearchResult, error)
+	// ResolveURL 获取歌曲的播放URL，请求的音质不可用时返回更低的音质
+	ResolveURL(ctx context.Context, id string, quality Quality) (*SongURL, error)
+}
+
+// LyricsProvider 可选接口，获取歌曲的原始歌词，包括翻译和罗马音
+type LyricsProvider interface {
+	Lyrics(ctx context.Context, id string) (*models.Lyrics, error)
+}
+
+// KaraokeProvider 可选接口，获取逐字歌词的格式和原文
+type KaraokeProvider interface {
+	KaraokeLyrics(ctx context.Context, id string) (format, text string, err error)
+}
+
+// ErrLyricsNotFound 歌曲没有歌词或上游未收录
+var ErrLyricsNotFound = errors.New("未找到歌词")
+
+// DisplayNamer 可选接口，返回音乐源的展示名称
+type DisplayNamer interface {
+	DisplayName() string
+}
+
+// StreamHeaderer 可选接口，返回请求音频文件时需要携带的请求头，如Referer、User-Agent
+type StreamHeaderer interface {
+	StreamHeaders() http.Header
+}
+
+// StreamHeaders 返回请求音乐源音频文件时需要的请求头，未实现StreamHeaderer时返回空
+func StreamHeaders(p Provider) http.Header {
+	if h, ok := p.(StreamHeaderer); ok {
+		return h.StreamHeaders()
+	}
+	return http.Header{}
+}
+
+// DefaultURLTTL 音乐源未实现URLExpirer时播放地址的默认有效期
+const DefaultURLTTL = 10 * time.Minute
+
+// URLExpirer 可选接口，返回播放地址的默认有效期，上游没有给出过期时间时使用
+type URLExpirer interface {
+	URLTTL() time.Duration
+}
+
+// URLTTL 返回音乐源播放地址的默认有效期
+func URLTTL(p Provider) time.Duration {
+	if e, ok := p.(URLExpirer); ok {
+		return e.URLTTL()
+	}
+	return DefaultURLTTL
+}
+
+// ImageHoster 可选接口，返回音乐源封面图片所在的域名，包括其子域名
+type ImageHoster interface {
+	ImageHosts() []string
+}
+
+// IsImageURL 判断地址是否为某个音乐源的封面图片地址，只允许http和https
+func IsImageURL(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	for _, p := range All() {
+		h, ok := p.(ImageHoster)
+		if !ok {
+			continue
+		}
+		for _, domain := range h.ImageHosts() {
+			if hostMatches(u, domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DisplayName 返回音乐源的展示名称，未实现DisplayNamer时返回标识
+func DisplayName(p Provider) string {
+	if d, ok := p.(DisplayNamer); ok {
+		return d.DisplayName()
+	}
+	return p.Name()
+}
+
+// 分页默认值
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ErrUnsupportedSearchType 音乐源不支持该搜索类型
+var ErrUnsupportedSearchType = errors.New("音乐源不支持该搜索类型")
+
+// SearchType 搜索类型
+type SearchType string
+
+// 支持的搜索类型
+const (
+	SearchTypeSong     SearchType = "song"
+	SearchTypeAlbum    SearchType = "album"
+	SearchTypeArtist   SearchType = "artist"
+	SearchTypePlaylist SearchType = "playlist"
+	SearchTypeLyric    SearchType = "lyric" // 按歌词搜索，结果为歌曲
+)
+
+// ParseSearchType 解析搜索类型，为空时默认搜索歌曲
+func ParseSearchType(s string) (SearchType, bool) {
+	switch t := SearchType(s); t {
+	case "":
+		return SearchTypeSong, true
+	case SearchTypeSong, SearchTypeAlbum, SearchTypeArtist, SearchTypePlaylist, SearchTypeLyric:
+		return t, true
+	default:
+		return "", false
+	}
+}
+
+// SearchQuery 定义搜索参数
+type SearchQuery struct {
+	Keyword  string
+	Type     SearchType
+	Page     int // 页码，从1开始
+	PageSize int // 每页数量
+}
+
+// Normalize 补全缺省的分页参数并限制每页数量
+func (q SearchQuery) Normalize() SearchQuery {
+	if q.Type == "" {
+		q.Type = SearchTypeSong
+	}
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+	return q
+}
+
+// Offset 返回当前页第一条结果的偏移量
+func (q SearchQuery) Offset() int {
+	return (q.Page - 1) * q.PageSize
+}
+
+// SearchResult 定义单个音乐源的搜索结果，只有与搜索类型对应的列表有值
+type SearchResult struct {
+	Songs     []models.Song
+	Albums    []models.Album
+	Artists   []models.Artist
+	Playlists []models.Playlist
+	Total     int // 上游返回的结果总数
+}
+
+// Len 返回当前页的结果数量
+func (r *SearchResult) Len() int {
+	return len(r.Songs) + len(r.Albums) + len(r.Artists) + len(r.Playlists)
+}
+
+// newSearchResult 构造歌曲搜索结果，上游未返回总数时根据当前页推算
+func newSearchResult(q SearchQuery, songs []models.Song, total int) *SearchResult {
+	if songs == nil {
+		songs = []models.Song{}
+	}
+	return fixTotal(q, &SearchResult{Songs: songs, Total: total})
+}
+
+// fixTotal 上游未返回总数时根据当前页推算
+func fixTotal(q SearchQuery, result *SearchResult) *SearchResult {
+	if n := result.Len(); n > 0 && result.Total < q.Offset()+n {
+		result.Total = q.Offset() + n
+	}
+	return result
+}
@@ -0,0 +1,32 @@
+package providers
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestIsImageURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://p1.music.126.net/abc/109951.jpg", true},
+		{"http://img1.kwcdn.kuwo.cn/star/albumcover/300/1.jpg", true},
+		{"https://y.gtimg.cn/music/photo_new/T002R300x300M000abc.jpg", true},
+		{"http://p.qpic.cn/music_cover/abc/300", true},
+		{"https://Y.GTIMG.CN/cover.jpg", true},
+		{"https://gtimg.cn.evil.com/cover.jpg", false},
+		{"https://evilgtimg.cn/cover.jpg", false},
+		{"http://127.0.0.1/cover.jpg", false},
+		{"file://y.gtimg.cn/cover.jpg", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := IsImageURL(u); got != tt.want {
+			t.Errorf("IsImageURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
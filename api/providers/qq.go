@@ -3,16 +3,23 @@ package providers
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"web_music/lyrics"
 	"web_music/models"
 )
 
@@ -20,42 +27,141 @@ import (
 var (
 	ErrFetchFailed = errors.New("获取数据失败")
 	ErrParseError  = errors.New("解析响应失败")
-)
 
-// QQ音乐API常量
-const (
-// 新版搜索API
-// qqSearchAPI = "https://u.y.qq.com/cgi-bin/musicu.fcg"
+	// errQQAlternativeFailed 备用接口的响应中没有可用的URL
+	errQQAlternativeFailed = errors.New("备用接口未返回音乐URL")
 )
 
+// qqProvider QQ音乐源
+type qqProvider struct{}
+
+func (qqProvider) Name() string        { return "qq" }
+func (qqProvider) DisplayName() string { return "腾讯音乐" }
+
+func (qqProvider) StreamHeaders() http.Header {
+	return http.Header{
+		"User-Agent": {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"},
+		"Referer":    {"https://y.qq.com/"},
+	}
+}
+
+// ImageHosts QQ音乐的封面在 y.gtimg.cn 下，歌单封面在 qpic.cn 下
+func (qqProvider) ImageHosts() []string { return []string{"gtimg.cn", "qpic.cn"} }
+
+// URLTTL QQ音乐的vkey通常一天内有效，接口的expiration字段会给出准确时间
+func (qqProvider) URLTTL() time.Duration { return 12 * time.Hour }
+
+func (qqProvider) Search(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	return SearchQQMusic(ctx, q)
+}
+
+func (qqProvider) ResolveURL(ctx context.Context, id string, quality Quality) (*SongURL, error) {
+	return GetQQMusicURL(ctx, id, quality)
+}
+
+func (qqProvider) Lyrics(ctx context.Context, id string) (*models.Lyrics, error) {
+	return GetQQMusicLyrics(ctx, id)
+}
+
+func (qqProvider) KaraokeLyrics(ctx context.Context, id string) (string, string, error) {
+	text, err := GetQQMusicQRC(ctx, id)
+	return models.KaraokeQRC, text, err
+}
+
+// ParseShareURL 识别QQ音乐的歌单、专辑和歌曲链接，如 y.qq.com/n/ryqq/playlist/{id}
+func (qqProvider) ParseShareURL(u *url.URL) (ImportTarget, bool) {
+	if !hostMatches(u, "qq.com") {
+		return ImportTarget{}, false
+	}
+
+	p, query := sharePath(u)
+	switch {
+	case pathID(p, "/playlist/") != "":
+		return ImportTarget{ImportPlaylist, pathID(p, "/playlist/")}, true
+	case pathID(p, "/playsquare/") != "":
+		return ImportTarget{ImportPlaylist, pathID(p, "/playsquare/")}, true
+	case strings.Contains(p, "taoge") && query.Get("id") != "":
+		return ImportTarget{ImportPlaylist, query.Get("id")}, true
+	case pathID(p, "/albumDetail/") != "":
+		return ImportTarget{ImportAlbum, pathID(p, "/albumDetail/")}, true
+	case pathID(p, "/album/") != "":
+		return ImportTarget{ImportAlbum, pathID(p, "/album/")}, true
+	case query.Get("albummid") != "":
+		return ImportTarget{ImportAlbum, query.Get("albummid")}, true
+	case pathID(p, "/songDetail/") != "":
+		return ImportTarget{ImportSong, pathID(p, "/songDetail/")}, true
+	case pathID(p, "/song/") != "":
+		return ImportTarget{ImportSong, pathID(p, "/song/")}, true
+	case query.Get("songmid") != "":
+		return ImportTarget{ImportSong, query.Get("songmid")}, true
+	}
+	return ImportTarget{}, false
+}
+
+func (qqProvider) Import(ctx context.Context, target ImportTarget) (*ImportResult, error) {
+	switch target.Kind {
+	case ImportPlaylist:
+		return ImportQQPlaylist(ctx, target.ID)
+	case ImportAlbum:
+		return ImportQQAlbum(ctx, target.ID)
+	default:
+		return ImportQQSong(ctx, target.ID)
+	}
+}
+
 // SearchQQMusic 搜索QQ音乐
-func SearchQQMusic(keyword string) ([]models.Song, error) {
+func SearchQQMusic(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	q = q.Normalize()
 	maxRetries := 3
 	var err error
 
 	for i := 0; i < maxRetries; i++ {
-		var songs []models.Song
-		songs, err = trySearchQQMusic(keyword)
+		var result *SearchResult
+		result, err = tryEndpoints(ctx, "qq", OpSearch, func(e Endpoint) (*SearchResult, error) {
+			return trySearchQQMusic(ctx, e, q)
+		})
 		if err == nil {
-			return songs, nil
+			return result, nil
+		}
+
+		// 请求已取消，不再重试
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// 接口已熔断，重试也会立即失败
+		if errors.Is(err, ErrCircuitOpen) {
+			return nil, err
 		}
 
 		if i < maxRetries-1 {
 			log.Printf("QQ音乐搜索失败，正在重试(%d/%d): %v", i+1, maxRetries, err)
-			time.Sleep(time.Duration(i+1) * 2 * time.Second) // 指数退避
+			select {
+			case <-time.After(time.Duration(i+1) * 2 * time.Second): // 指数退避
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 	}
 
-	return []models.Song{}, fmt.Errorf("多次尝试后搜索QQ音乐失败: %w", err)
+	return nil, fmt.Errorf("多次尝试后搜索QQ音乐失败: %w", err)
+}
+
+// QQ音乐搜索类型
+var qqSearchTypes = map[SearchType]int{
+	SearchTypeSong:     0,
+	SearchTypeAlbum:    8,
+	SearchTypeArtist:   1,
+	SearchTypePlaylist: 7,
+	SearchTypeLyric:    9,
 }
 
-// 将原来的SearchQQMusic函数代码移动到这个新函数中
-func trySearchQQMusic(keyword string) ([]models.Song, error) {
-	log.Printf("搜索QQ音乐: %s", keyword)
+// trySearchQQMusic 使用musicu.fcg接口搜索一次，由SearchQQMusic负责重试
+func trySearchQQMusic(ctx context.Context, e Endpoint, q SearchQuery) (*SearchResult, error) {
+	log.Printf("搜索QQ音乐: %s (第%d页)", q.Keyword, q.Page)
 
 	// 构建请求URL
 	// 接口文档：https://github.com/jsososo/QQMusicApi
-	apiURL := "https://u.y.qq.com/cgi-bin/musicu.fcg"
+	apiURL := e.url("/cgi-bin/musicu.fcg")
 
 	// 构建请求体
 	requestBody := map[string]interface{}{
@@ -63,10 +169,10 @@ func trySearchQQMusic(keyword string) ([]models.Song, error) {
 			"module": "music.search.SearchCgiService",
 			"method": "DoSearchForQQMusicDesktop",
 			"param": map[string]interface{}{
-				"query":        keyword,
-				"num_per_page": 20,
-				"page_num":     1,
-				"search_type":  0, // 0-歌曲，8-专辑，9-歌词，7-歌单，1-歌手，2-mv
+				"query":        q.Keyword,
+				"num_per_page": q.PageSize,
+				"page_num":     q.Page,
+				"search_type":  qqSearchTypes[q.Type], // 0-歌曲，8-专辑，9-歌词，7-歌单，1-歌手，2-mv
 			},
 		},
 	}
@@ -77,7 +183,7 @@ func trySearchQQMusic(keyword string) ([]models.Song, error) {
 	}
 
 	// 创建请求
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -88,10 +194,10 @@ func trySearchQQMusic(keyword string) ([]models.Song, error) {
 	req.Header.Set("Referer", "https://y.qq.com/")
 	req.Header.Set("Origin", "https://y.qq.com")
 	req.Header.Set("Accept", "application/json")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := e.client(qqTransport).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("请求失败: %w", err)
 	}
@@ -117,21 +223,38 @@ func trySearchQQMusic(keyword string) ([]models.Song, error) {
 		return nil, fmt.Errorf("解析JSON失败: %w", err)
 	}
 
-	// 检查是否有返回结果
+	// 上游返回错误码时交给接口链尝试下一个接口
 	req0, ok := result["req_0"].(map[string]interface{})
-	if !ok || req0["code"].(float64) != 0 {
-		code := 0.0
-		if ok {
-			code = req0["code"].(float64)
-		}
-		log.Printf("QQ音乐API响应错误: %v", code)
-		return []models.Song{}, nil
+	if !ok {
+		return nil, fmt.Errorf("响应中缺少搜索结果")
+	}
+	if code, _ := req0["code"].(float64); code != 0 {
+		return nil, fmt.Errorf("QQ音乐API返回错误码 %v", code)
 	}
 
 	// 提取歌曲列表
 	var songs []models.Song
+	total := 0
 	if data, ok := req0["data"].(map[string]interface{}); ok {
+		// 提取结果总数
+		if meta, ok := data["meta"].(map[string]interface{}); ok {
+			if sum, ok := meta["sum"].(float64); ok {
+				total = int(sum)
+			}
+		}
+
 		if body, ok := data["body"].(map[string]interface{}); ok {
+			// 专辑、歌手、歌单结果
+			switch q.Type {
+			case SearchTypeAlbum:
+				return fixTotal(q, &SearchResult{Albums: parseQQAlbums(body), Total: total}), nil
+			case SearchTypeArtist:
+				return fixTotal(q, &SearchResult{Artists: parseQQArtists(body), Total: total}), nil
+			case SearchTypePlaylist:
+				return fixTotal(q, &SearchResult{Playlists: parseQQPlaylists(body), Total: total}), nil
+			}
+
+			// 歌曲和歌词搜索都返回歌曲列表
 			if song, ok := body["song"].(map[string]interface{}); ok {
 				if list, ok := song["list"].([]interface{}); ok {
 					for _, item := range list {
@@ -140,75 +263,323 @@ func trySearchQQMusic(keyword string) ([]models.Song, error) {
 							continue
 						}
 
-						// 提取歌手信息
-						var artists []string
-						if singer, ok := songInfo["singer"].([]interface{}); ok {
-							for _, s := range singer {
-								if singerInfo, ok := s.(map[string]interface{}); ok {
-									if name, ok := singerInfo["name"].(string); ok {
-										artists = append(artists, name)
-									}
-								}
-							}
+						if song, ok := parseQQSong(songInfo); ok {
+							songs = append(songs, song)
 						}
+					}
+				}
+			}
+		}
+	}
 
-						// 提取专辑信息
-						albumName := ""
-						if album, ok := songInfo["album"].(map[string]interface{}); ok {
-							if name, ok := album["name"].(string); ok {
-								albumName = name
-							}
-						}
+	if len(songs) == 0 {
+		log.Printf("QQ音乐搜索无结果: %s", q.Keyword)
+	}
 
-						// 提取歌曲封面
-						coverURL := ""
-						if album, ok := songInfo["album"].(map[string]interface{}); ok {
-							if mid, ok := album["mid"].(string); ok {
-								coverURL = fmt.Sprintf("https://y.gtimg.cn/music/photo_new/T002R300x300M000%s.jpg", mid)
-							}
-						}
+	return newSearchResult(q, songs, total), nil
+}
 
-						// 创建歌曲对象
-						songMid := ""
-						if mid, ok := songInfo["mid"].(string); ok {
-							songMid = mid
-						}
+// 解析QQ音乐的歌曲信息
+func parseQQSong(songInfo map[string]interface{}) (models.Song, bool) {
+	songMid, _ := songInfo["mid"].(string)
+	songTitle, _ := songInfo["title"].(string)
+	if songTitle == "" {
+		songTitle, _ = songInfo["name"].(string)
+	}
+	if songMid == "" || songTitle == "" {
+		return models.Song{}, false
+	}
 
-						songTitle := ""
-						if title, ok := songInfo["title"].(string); ok {
-							songTitle = title
-						}
+	song := models.Song{
+		ID:     songMid,
+		Title:  songTitle,
+		Source: "qq",
+	}
 
-						if songMid != "" && songTitle != "" {
-							song := models.Song{
-								ID:     songMid,
-								Title:  songTitle,
-								Artist: strings.Join(artists, ", "),
-								Album:  albumName,
-								Cover:  coverURL,
-								Source: "qq",
-							}
-							songs = append(songs, song)
-						}
-					}
+	// 提取歌手信息
+	var artists []string
+	if singer, ok := songInfo["singer"].([]interface{}); ok {
+		for _, s := range singer {
+			if singerInfo, ok := s.(map[string]interface{}); ok {
+				if name, ok := singerInfo["name"].(string); ok {
+					artists = append(artists, name)
+				}
+				if mid, ok := singerInfo["mid"].(string); ok && mid != "" {
+					song.ArtistIDs = append(song.ArtistIDs, mid)
 				}
 			}
 		}
 	}
+	song.Artist = strings.Join(artists, ", ")
 
-	if len(songs) == 0 {
-		log.Printf("QQ音乐搜索无结果: %s", keyword)
+	// 提取专辑信息和封面
+	if album, ok := songInfo["album"].(map[string]interface{}); ok {
+		if name, ok := album["name"].(string); ok {
+			song.Album = name
+		}
+		if mid, ok := album["mid"].(string); ok && mid != "" {
+			song.AlbumID = mid
+			song.Cover = fmt.Sprintf("https://y.gtimg.cn/music/photo_new/T002R300x300M000%s.jpg", mid)
+		}
 	}
 
-	return songs, nil
+	// 提取时长
+	if interval, ok := songInfo["interval"].(float64); ok {
+		song.Duration = int(interval)
+	}
+
+	// 根据各音质的文件大小判断可用音质
+	if file, ok := songInfo["file"].(map[string]interface{}); ok {
+		for _, item := range []struct {
+			key     string
+			quality string
+		}{
+			{"size_128mp3", models.Quality128k},
+			{"size_320mp3", models.Quality320k},
+			{"size_flac", models.QualityFLAC},
+		} {
+			if size, ok := file[item.key].(float64); ok && size > 0 {
+				song.Qualities = append(song.Qualities, item.quality)
+			}
+		}
+		// 有文件信息但所有音质都不可用，说明歌曲已下架
+		song.Unavailable = len(song.Qualities) == 0
+	}
+
+	// 提取付费信息
+	if pay, ok := songInfo["pay"].(map[string]interface{}); ok {
+		if payPlay, ok := pay["pay_play"].(float64); ok && payPlay == 1 {
+			song.VIP = true
+		}
+	}
+
+	return song, true
 }
 
-// GetQQMusicURL 获取QQ音乐播放URL
-func GetQQMusicURL(mid string) (string, error) {
-	log.Printf("获取QQ音乐URL: %s", mid)
+// 解析QQ音乐专辑搜索结果
+func parseQQAlbums(body map[string]interface{}) []models.Album {
+	albums := []models.Album{}
+	albumObj, ok := body["album"].(map[string]interface{})
+	if !ok {
+		return albums
+	}
+	list, ok := albumObj["list"].([]interface{})
+	if !ok {
+		return albums
+	}
+
+	for _, item := range list {
+		info, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		mid, _ := info["albumMID"].(string)
+		name, _ := info["albumName"].(string)
+		if mid == "" || name == "" {
+			continue
+		}
+
+		singer, _ := info["singerName"].(string)
+		publishTime, _ := info["publicTime"].(string)
+		songCount, _ := info["song_count"].(float64)
+
+		albums = append(albums, models.Album{
+			ID:          mid,
+			Name:        name,
+			Artist:      singer,
+			Cover:       fmt.Sprintf("https://y.gtimg.cn/music/photo_new/T002R300x300M000%s.jpg", mid),
+			SongCount:   int(songCount),
+			PublishTime: publishTime,
+			Source:      "qq",
+		})
+	}
+
+	return albums
+}
+
+// 解析QQ音乐歌手搜索结果
+func parseQQArtists(body map[string]interface{}) []models.Artist {
+	artists := []models.Artist{}
+	singerObj, ok := body["singer"].(map[string]interface{})
+	if !ok {
+		return artists
+	}
+	list, ok := singerObj["list"].([]interface{})
+	if !ok {
+		return artists
+	}
+
+	for _, item := range list {
+		info, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		mid, _ := info["singerMID"].(string)
+		name, _ := info["singerName"].(string)
+		if mid == "" || name == "" {
+			continue
+		}
+
+		avatar, _ := info["singerPic"].(string)
+		songCount, _ := info["songNum"].(float64)
+		albumCount, _ := info["albumNum"].(float64)
+
+		artists = append(artists, models.Artist{
+			ID:         mid,
+			Name:       name,
+			Avatar:     avatar,
+			SongCount:  int(songCount),
+			AlbumCount: int(albumCount),
+			Source:     "qq",
+		})
+	}
+
+	return artists
+}
+
+// 解析QQ音乐歌单搜索结果
+func parseQQPlaylists(body map[string]interface{}) []models.Playlist {
+	playlists := []models.Playlist{}
+	songlistObj, ok := body["songlist"].(map[string]interface{})
+	if !ok {
+		return playlists
+	}
+	list, ok := songlistObj["list"].([]interface{})
+	if !ok {
+		return playlists
+	}
+
+	for _, item := range list {
+		info, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		id, _ := info["dissid"].(string)
+		name, _ := info["dissname"].(string)
+		if id == "" || name == "" {
+			continue
+		}
+
+		creator := ""
+		if creatorObj, ok := info["creator"].(map[string]interface{}); ok {
+			creator, _ = creatorObj["name"].(string)
+		}
+		cover, _ := info["imgurl"].(string)
+		songCount, _ := info["song_count"].(float64)
+		playCount, _ := info["listennum"].(float64)
+
+		playlists = append(playlists, models.Playlist{
+			ID:        id,
+			Name:      name,
+			Creator:   creator,
+			Cover:     cover,
+			SongCount: int(songCount),
+			PlayCount: int(playCount),
+			Source:    "qq",
+		})
+	}
+
+	return playlists
+}
+
+// qqQualityFile QQ音乐音频文件的命名规则
+type qqQualityFile struct {
+	quality Quality
+	prefix  string // 文件名前缀
+	ext     string // 文件扩展名
+	bitrate int    // 码率(kbps)，无损音质码率不固定时为0
+}
+
+// qqQualityFiles QQ音乐各音质对应的文件，文件名为 前缀+songmid+mediamid+扩展名
+var qqQualityFiles = map[Quality]qqQualityFile{
+	QualityLossless: {QualityLossless, "F000", ".flac", 0},
+	QualityHigh:     {QualityHigh, "M800", ".mp3", 320},
+	QualityStandard: {QualityStandard, "M500", ".mp3", 128},
+}
+
+// qqDefaultFile 不指定文件名时返回的默认文件
+var qqDefaultFile = qqQualityFile{QualityStandard, "C400", ".m4a", 96}
+
+// GetQQMusicURL 获取QQ音乐播放URL，按配置的接口链依次尝试
+// 指定音质的文件均不可用时，后面的接口返回默认文件
+func GetQQMusicURL(ctx context.Context, mid string, quality Quality) (*SongURL, error) {
+	log.Printf("获取QQ音乐URL: %s (%s)", mid, quality)
+
+	return tryEndpoints(ctx, "qq", OpURL, func(e Endpoint) (*SongURL, error) {
+		var audioURL string
+		var err error
+		switch e.Type {
+		case EndpointQQMusics:
+			audioURL, err = getQQMusicURLAlternative(ctx, e, mid)
+		case EndpointZhuolin:
+			audioURL, err = getQQMusicURLThirdOption(ctx, e, mid)
+		default:
+			return getQQVkeyURL(ctx, e, mid, quality)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return qqSongURL(audioURL), nil
+	})
+}
+
+// getQQVkeyURL 通过vkey接口获取指定音质的URL，请求的音质不可用时依次尝试更低的音质
+func getQQVkeyURL(ctx context.Context, e Endpoint, mid string, quality Quality) (*SongURL, error) {
+	var err error
+	for _, tier := range quality.Fallbacks() {
+		file := qqQualityFiles[tier]
+		var audioURL string
+		var expires time.Time
+		audioURL, expires, err = requestQQVkey(ctx, e, mid, file.prefix+mid+mid+file.ext)
+		if err == nil {
+			songURL := qqSongURL(audioURL)
+			songURL.Expires = expires
+			return songURL, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("获取QQ音乐%s音质URL失败: %v", tier, err)
+		if !errors.Is(err, errQualityUnavailable) {
+			break
+		}
+	}
+	return nil, err
+}
+
+// qqSongURL 根据文件名前缀判断QQ音乐URL的实际音质
+func qqSongURL(audioURL string) *SongURL {
+	name := audioURL
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	name = path.Base(name)
+
+	files := []qqQualityFile{qqDefaultFile}
+	for _, file := range qqQualityFiles {
+		files = append(files, file)
+	}
+	for _, file := range files {
+		if strings.HasPrefix(name, file.prefix) {
+			return &SongURL{
+				URL:     audioURL,
+				Quality: file.quality,
+				Bitrate: file.bitrate,
+				Format:  strings.TrimPrefix(file.ext, "."),
+			}
+		}
+	}
+	return &SongURL{URL: audioURL, Quality: QualityStandard, Format: formatFromURL(audioURL)}
+}
+
+// requestQQVkey 通过vkey接口获取指定音频文件的URL及其过期时间
+func requestQQVkey(ctx context.Context, e Endpoint, mid, filename string) (string, time.Time, error) {
 
 	// 构建请求URL
-	apiURL := "https://u.y.qq.com/cgi-bin/musicu.fcg"
+	apiURL := e.url("/cgi-bin/musicu.fcg")
 
 	// 构建请求体
 	requestBody := map[string]interface{}{
@@ -219,6 +590,7 @@ func GetQQMusicURL(mid string) (string, error) {
 				"guid":       "10000",
 				"songmid":    []string{mid},
 				"songtype":   []int{0},
+				"filename":   []string{filename},
 				"uin":        "0",
 				"loginflag":  1,
 				"platform":   "20",
@@ -235,13 +607,13 @@ func GetQQMusicURL(mid string) (string, error) {
 
 	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("构建请求体失败: %w", err)
+		return "", time.Time{}, fmt.Errorf("构建请求体失败: %w", err)
 	}
 
 	// 创建请求
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return "", time.Time{}, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	// 设置请求头
@@ -253,12 +625,12 @@ func GetQQMusicURL(mid string) (string, error) {
 	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
 	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
 	req.Header.Set("Connection", "keep-alive")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := e.client(qqTransport).Do(req)
 	if err != nil {
-		return "", fmt.Errorf("请求失败: %w", err)
+		return "", time.Time{}, fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -269,7 +641,7 @@ func GetQQMusicURL(mid string) (string, error) {
 	if resp.Header.Get("Content-Encoding") == "gzip" {
 		gzReader, err := gzip.NewReader(resp.Body)
 		if err != nil {
-			return "", fmt.Errorf("创建gzip reader失败: %w", err)
+			return "", time.Time{}, fmt.Errorf("创建gzip reader失败: %w", err)
 		}
 		defer gzReader.Close()
 		reader = gzReader
@@ -277,7 +649,7 @@ func GetQQMusicURL(mid string) (string, error) {
 
 	body, err := io.ReadAll(reader)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
+		return "", time.Time{}, fmt.Errorf("读取响应失败: %w", err)
 	}
 
 	// 调试输出
@@ -294,49 +666,53 @@ func GetQQMusicURL(mid string) (string, error) {
 	// 解析响应
 	var result map[string]interface{}
 	if err := json.Unmarshal(cleanBody, &result); err != nil {
-		// 如果解析失败，尝试使用备用方法
-		return getQQMusicURLAlternative(mid)
+		return "", time.Time{}, fmt.Errorf("解析响应失败: %w", err)
 	}
 
 	// 提取URL
 	req0, ok := result["req_0"].(map[string]interface{})
 	if !ok {
-		return "", fmt.Errorf("响应格式错误")
+		return "", time.Time{}, fmt.Errorf("响应格式错误")
 	}
 
-	if req0["code"].(float64) != 0 {
-		return "", fmt.Errorf("API返回错误代码: %v", req0["code"])
+	if code, ok := req0["code"].(float64); !ok || code != 0 {
+		return "", time.Time{}, fmt.Errorf("API返回错误代码: %v", req0["code"])
 	}
 
 	data, ok := req0["data"].(map[string]interface{})
 	if !ok {
-		return "", fmt.Errorf("响应数据格式错误")
+		return "", time.Time{}, fmt.Errorf("响应数据格式错误")
 	}
 
 	// 获取URL基础部分
 	sip, ok := data["sip"].([]interface{})
 	if !ok || len(sip) == 0 {
-		return "", fmt.Errorf("无法获取URL基础部分")
+		return "", time.Time{}, fmt.Errorf("无法获取URL基础部分")
+	}
+	urlBase, ok := sip[0].(string)
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("无法获取URL基础部分")
 	}
-	urlBase := sip[0].(string)
 
 	// 获取歌曲文件信息
 	midurlinfo, ok := data["midurlinfo"].([]interface{})
 	if !ok || len(midurlinfo) == 0 {
-		return "", fmt.Errorf("无法获取歌曲文件信息")
+		return "", time.Time{}, fmt.Errorf("无法获取歌曲文件信息")
 	}
 
-	info := midurlinfo[0].(map[string]interface{})
+	info, _ := midurlinfo[0].(map[string]interface{})
 	purl, ok := info["purl"].(string)
 	if !ok || purl == "" {
-		// 尝试使用备用方法获取URL
-		return getQQMusicURLAlternative(mid)
+		// 没有权限或该音质的文件不存在
+		return "", time.Time{}, fmt.Errorf("无法获取文件 %s: %w", filename, errQualityUnavailable)
 	}
 
 	// 组合完整URL
 	fullURL := urlBase + purl
 
-	return fullURL, nil
+	// expiration为vkey的有效秒数
+	expiration, _ := data["expiration"].(float64)
+	return fullURL, expiresIn(expiration), nil
 }
 
 // 清理ANSI转义序列
@@ -347,14 +723,14 @@ func cleanANSI(data []byte) []byte {
 }
 
 // 备用的获取音乐URL方法
-func getQQMusicURLAlternative(songMid string) (string, error) {
+func getQQMusicURLAlternative(ctx context.Context, e Endpoint, songMid string) (string, error) {
 	log.Printf("使用备用方法获取QQ音乐URL: %s", songMid)
 
 	// 构建请求URL - 使用不同的API端点
-	apiURL := fmt.Sprintf("https://u.y.qq.com/cgi-bin/musics.fcg?format=json&data={%%22req_0%%22:{%%22module%%22:%%22vkey.GetVkeyServer%%22,%%22method%%22:%%22CgiGetVkey%%22,%%22param%%22:{%%22guid%%22:%%2210000%%22,%%22songmid%%22:[%%22%s%%22],%%22songtype%%22:[0],%%22uin%%22:%%220%%22,%%22loginflag%%22:1,%%22platform%%22:%%2220%%22}}}", songMid)
+	apiURL := e.url(fmt.Sprintf("/cgi-bin/musics.fcg?format=json&data={%%22req_0%%22:{%%22module%%22:%%22vkey.GetVkeyServer%%22,%%22method%%22:%%22CgiGetVkey%%22,%%22param%%22:{%%22guid%%22:%%2210000%%22,%%22songmid%%22:[%%22%s%%22],%%22songtype%%22:[0],%%22uin%%22:%%220%%22,%%22loginflag%%22:1,%%22platform%%22:%%2220%%22}}}", songMid))
 
 	// 创建请求
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("创建备用请求失败: %w", err)
 	}
@@ -365,16 +741,10 @@ func getQQMusicURLAlternative(songMid string) (string, error) {
 	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
 	req.Header.Set("Connection", "keep-alive")
 	req.Header.Set("Accept-Encoding", "identity") // 禁用压缩
+	e.setHeaders(req)
 
-	// 发送请求
-	client := &http.Client{
-		Timeout: 15 * time.Second,
-		Transport: &http.Transport{
-			DisableCompression: true, // 禁用传输层压缩
-		},
-	}
-
-	resp, err := client.Do(req)
+	// 发送请求，禁用传输层压缩
+	resp, err := e.client(qqUncompressedTransport).Do(req)
 	if err != nil {
 		return "", fmt.Errorf("备用请求失败: %w", err)
 	}
@@ -403,61 +773,66 @@ func getQQMusicURLAlternative(songMid string) (string, error) {
 	// 尝试解析JSON
 	var result map[string]interface{}
 	if err := json.Unmarshal(cleanedBody, &result); err != nil {
-		log.Printf("备用响应解析失败，尝试第三方API: %v", err)
-		return getQQMusicURLThirdOption(songMid)
+		return "", fmt.Errorf("解析备用响应失败: %w", err)
 	}
 
 	// 提取URL
 	req0, ok := result["req_0"].(map[string]interface{})
 	if !ok {
-		return getQQMusicURLThirdOption(songMid)
+		return "", errQQAlternativeFailed
 	}
 
 	if code, ok := req0["code"].(float64); !ok || code != 0 {
-		return getQQMusicURLThirdOption(songMid)
+		return "", errQQAlternativeFailed
 	}
 
 	data, ok := req0["data"].(map[string]interface{})
 	if !ok {
-		return getQQMusicURLThirdOption(songMid)
+		return "", errQQAlternativeFailed
 	}
 
 	// 获取URL基础部分
 	sip, ok := data["sip"].([]interface{})
 	if !ok || len(sip) == 0 {
-		return getQQMusicURLThirdOption(songMid)
+		return "", errQQAlternativeFailed
+	}
+	urlBase, ok := sip[0].(string)
+	if !ok {
+		return "", errQQAlternativeFailed
 	}
-	urlBase := sip[0].(string)
 
 	// 获取歌曲文件信息
 	midurlinfo, ok := data["midurlinfo"].([]interface{})
 	if !ok || len(midurlinfo) == 0 {
-		return getQQMusicURLThirdOption(songMid)
+		return "", errQQAlternativeFailed
 	}
 
-	info := midurlinfo[0].(map[string]interface{})
+	info, ok := midurlinfo[0].(map[string]interface{})
+	if !ok {
+		return "", errQQAlternativeFailed
+	}
 	purl, ok := info["purl"].(string)
 	if !ok || purl == "" {
-		return getQQMusicURLThirdOption(songMid)
+		return "", errQQAlternativeFailed
 	}
 
 	// 组合完整URL
 	fullURL := urlBase + purl
 	if fullURL == "" || !strings.HasPrefix(fullURL, "http") {
-		return getQQMusicURLThirdOption(songMid)
+		return "", errQQAlternativeFailed
 	}
 
 	return fullURL, nil
 }
 
-// 修改第三个备用方法
-func getQQMusicURLThirdOption(songMid string) (string, error) {
+// 第三方接口获取QQ音乐URL
+func getQQMusicURLThirdOption(ctx context.Context, e Endpoint, songMid string) (string, error) {
 	log.Printf("使用第三备用方法获取QQ音乐URL: %s", songMid)
 
 	// 使用新的第三方API
-	apiURL := fmt.Sprintf("https://api.zhuolin.wang/api.php?callback=jQuery&types=url&id=%s", songMid)
+	apiURL := e.url(fmt.Sprintf("/api.php?callback=jQuery&types=url&id=%s", songMid))
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("创建第三备用请求失败: %w", err)
 	}
@@ -468,16 +843,10 @@ func getQQMusicURLThirdOption(songMid string) (string, error) {
 	req.Header.Set("Referer", "https://y.qq.com/")
 	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
 	req.Header.Set("Accept-Encoding", "identity")
+	e.setHeaders(req)
 
 	// 发送请求
-	client := &http.Client{
-		Timeout: 15 * time.Second,
-		Transport: &http.Transport{
-			DisableCompression: true,
-		},
-	}
-
-	resp, err := client.Do(req)
+	resp, err := e.client(qqUncompressedTransport).Do(req)
 	if err != nil {
 		return "", fmt.Errorf("第三备用请求失败: %w", err)
 	}
@@ -507,10 +876,383 @@ func getQQMusicURLThirdOption(songMid string) (string, error) {
 	return "", fmt.Errorf("无法从第三备用方法获取音乐URL")
 }
 
-// 辅助函数：截断字符串
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+// GetQQMusicLyrics 获取QQ音乐歌词，包括翻译和罗马音，按配置的接口链依次尝试
+func GetQQMusicLyrics(ctx context.Context, mid string) (*models.Lyrics, error) {
+	log.Printf("获取QQ音乐歌词: %s", mid)
+
+	return tryEndpoints(ctx, "qq", OpLyrics, func(e Endpoint) (*models.Lyrics, error) {
+		if e.Type == EndpointQQLyric {
+			return getQQMusicLyricsBackup(ctx, e, mid)
+		}
+		return requestQQLyrics(ctx, e, mid)
+	})
+}
+
+// requestQQLyrics 使用musicu.fcg统一接口获取歌词，没有歌词时返回ErrLyricsNotFound以尝试下一个接口
+func requestQQLyrics(ctx context.Context, e Endpoint, mid string) (*models.Lyrics, error) {
+	data, err := requestQQLyricInfo(ctx, e, map[string]interface{}{
+		"songMID": mid,
+		"songID":  0,
+		"trans":   1,
+		"roma":    1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 歌词内容为base64编码
+	songLyrics := &models.Lyrics{
+		Source:       "qq",
+		ID:           mid,
+		LRC:          decodeQQLyric(data["lyric"]),
+		Translation:  decodeQQLyric(data["trans"]),
+		Romanization: decodeQQLyric(data["roma"]),
+	}
+	if songLyrics.LRC == "" {
+		return nil, ErrLyricsNotFound
+	}
+
+	return songLyrics, nil
+}
+
+// GetQQMusicQRC 获取QQ音乐的QRC逐字歌词，返回解密后的原文
+func GetQQMusicQRC(ctx context.Context, mid string) (string, error) {
+	log.Printf("获取QQ音乐逐字歌词: %s", mid)
+
+	return tryEndpoints(ctx, "qq", OpLyrics, func(e Endpoint) (string, error) {
+		return requestQQMusicQRC(ctx, e, mid)
+	})
+}
+
+// requestQQMusicQRC 使用musicu.fcg统一接口获取并解密逐字歌词，旧版歌词接口不提供逐字歌词
+func requestQQMusicQRC(ctx context.Context, e Endpoint, mid string) (string, error) {
+	if e.Type != EndpointQQ {
+		return "", fmt.Errorf("%s 接口不支持逐字歌词", e.Type)
+	}
+
+	// qrc=1时返回逐字歌词，内容为十六进制编码的加密数据
+	data, err := requestQQLyricInfo(ctx, e, map[string]interface{}{
+		"songMID": mid,
+		"songID":  0,
+		"qrc":     1,
+		"crypt":   1,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encrypted, _ := data["lyric"].(string)
+	if encrypted == "" {
+		return "", ErrLyricsNotFound
+	}
+	if qrc, _ := data["qrc"].(float64); qrc != 1 {
+		// 没有逐字歌词时返回的是普通歌词
+		return "", ErrLyricsNotFound
+	}
+
+	text, err := lyrics.DecryptQRC(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("解密QRC歌词失败: %w", err)
+	}
+	return text, nil
+}
+
+// requestQQLyricInfo 请求QQ音乐的歌词接口，返回响应中的data部分
+func requestQQLyricInfo(ctx context.Context, e Endpoint, param map[string]interface{}) (map[string]interface{}, error) {
+	requestBody := map[string]interface{}{
+		"req_0": map[string]interface{}{
+			"module": "music.musichallSong.PlayLyricInfo",
+			"method": "GetPlayLyricInfo",
+			"param":  param,
+		},
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("构建歌词请求体失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url("/cgi-bin/musicu.fcg"), bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建歌词请求失败: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Referer", "https://y.qq.com/")
+	req.Header.Set("Origin", "https://y.qq.com")
+	e.setHeaders(req)
+
+	resp, err := e.client(qqTransport).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("歌词请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取歌词响应失败: %w", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(cleanANSI(body), &result); err != nil {
+		return nil, fmt.Errorf("解析歌词响应失败: %w", err)
+	}
+
+	req0, _ := result["req_0"].(map[string]interface{})
+	if code, ok := req0["code"].(float64); !ok || code != 0 {
+		return nil, fmt.Errorf("歌词API返回错误代码: %v", req0["code"])
+	}
+
+	data, ok := req0["data"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("歌词响应数据格式错误")
+	}
+	return data, nil
+}
+
+// getQQMusicLyricsBackup 使用旧版歌词接口获取QQ音乐歌词
+func getQQMusicLyricsBackup(ctx context.Context, e Endpoint, mid string) (*models.Lyrics, error) {
+	log.Printf("使用备用API获取QQ音乐歌词: %s", mid)
+
+	apiURL := e.url(fmt.Sprintf("/lyric/fcgi-bin/fcg_query_lyric_new.fcg?songmid=%s&format=json&nobase64=1&g_tk=5381", url.QueryEscape(mid)))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建备用歌词请求失败: %w", err)
+	}
+
+	// 旧版接口会校验Referer
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Referer", "https://y.qq.com/portal/player.html")
+	e.setHeaders(req)
+
+	resp, err := e.client(qqTransport).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("备用歌词请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取备用歌词响应失败: %w", err)
+	}
+
+	// 响应可能是JSONP格式
+	jsonStr := strings.TrimSpace(string(body))
+	if i := strings.Index(jsonStr, "("); i >= 0 && strings.HasSuffix(jsonStr, ")") {
+		jsonStr = jsonStr[i+1 : len(jsonStr)-1]
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+		return nil, fmt.Errorf("解析备用歌词响应失败: %w", err)
+	}
+
+	if code, ok := result["retcode"].(float64); !ok || code != 0 {
+		return nil, ErrLyricsNotFound
+	}
+
+	// nobase64模式下歌词中的部分字符为HTML实体
+	lrc, _ := result["lyric"].(string)
+	trans, _ := result["trans"].(string)
+	if lrc == "" {
+		return nil, ErrLyricsNotFound
+	}
+
+	return &models.Lyrics{
+		Source:      "qq",
+		ID:          mid,
+		LRC:         html.UnescapeString(lrc),
+		Translation: html.UnescapeString(trans),
+	}, nil
+}
+
+// decodeQQLyric 解码base64编码的歌词
+func decodeQQLyric(v interface{}) string {
+	encoded, _ := v.(string)
+	if encoded == "" {
+		return ""
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ""
+	}
+	return string(decoded)
+}
+
+// ImportQQPlaylist 分页获取QQ音乐歌单中的全部歌曲
+func ImportQQPlaylist(ctx context.Context, id string) (*ImportResult, error) {
+	log.Printf("导入QQ音乐歌单: %s", id)
+
+	disstid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的QQ音乐歌单ID: %s", id)
+	}
+
+	name := ""
+	songs, total, err := importPages(ctx, func(page int) ([]models.Song, int, error) {
+		data, err := requestQQMusicu(ctx, "music.srfDissInfo.aiDissInfo", "uniform_get_Dissinfo", map[string]interface{}{
+			"disstid":      disstid,
+			"userinfo":     1,
+			"tag":          1,
+			"song_begin":   (page - 1) * importPageSize,
+			"song_num":     importPageSize,
+			"onlysonglist": 0,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if dirinfo, ok := data["dirinfo"].(map[string]interface{}); ok && name == "" {
+			name, _ = dirinfo["title"].(string)
+		}
+		total, _ := data["total_song_num"].(float64)
+
+		var songs []models.Song
+		list, _ := data["songlist"].([]interface{})
+		for _, item := range list {
+			if info, ok := item.(map[string]interface{}); ok {
+				if song, ok := parseQQSong(info); ok {
+					songs = append(songs, song)
+				}
+			}
+		}
+		return songs, int(total), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newImportResult(name, songs, total), nil
+}
+
+// ImportQQAlbum 分页获取QQ音乐专辑中的全部歌曲
+func ImportQQAlbum(ctx context.Context, mid string) (*ImportResult, error) {
+	log.Printf("导入QQ音乐专辑: %s", mid)
+
+	songs, total, err := importPages(ctx, func(page int) ([]models.Song, int, error) {
+		data, err := requestQQMusicu(ctx, "music.musichallAlbum.AlbumSongList", "GetAlbumSongList", map[string]interface{}{
+			"albumMid": mid,
+			"begin":    (page - 1) * importPageSize,
+			"num":      importPageSize,
+			"order":    2,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+
+		total, _ := data["totalNum"].(float64)
+
+		var songs []models.Song
+		list, _ := data["songList"].([]interface{})
+		for _, item := range list {
+			wrapper, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if info, ok := wrapper["songInfo"].(map[string]interface{}); ok {
+				if song, ok := parseQQSong(info); ok {
+					songs = append(songs, song)
+				}
+			}
+		}
+		return songs, int(total), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 专辑歌曲列表接口不返回专辑信息，使用歌曲中的专辑名称
+	name := ""
+	if len(songs) > 0 {
+		name = songs[0].Album
+	}
+	return newImportResult(name, songs, total), nil
+}
+
+// ImportQQSong 获取QQ音乐单曲的信息
+func ImportQQSong(ctx context.Context, mid string) (*ImportResult, error) {
+	log.Printf("导入QQ音乐歌曲: %s", mid)
+
+	data, err := requestQQMusicu(ctx, "music.pf_song_detail_svr", "get_song_detail_yqq", map[string]interface{}{
+		"song_mid": mid,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info, ok := data["track_info"].(map[string]interface{})
+	if !ok {
+		return nil, ErrParseError
+	}
+	song, ok := parseQQSong(info)
+	if !ok {
+		return nil, ErrParseError
+	}
+	return newImportResult(song.Title, []models.Song{song}, 1), nil
+}
+
+// requestQQMusicu 按导入使用的接口链调用QQ音乐的musicu.fcg统一接口，返回req_0中的数据
+func requestQQMusicu(ctx context.Context, module, method string, param map[string]interface{}) (map[string]interface{}, error) {
+	return tryEndpoints(ctx, "qq", OpImport, func(e Endpoint) (map[string]interface{}, error) {
+		return requestQQMusicuEndpoint(ctx, e, module, method, param)
+	})
+}
+
+// requestQQMusicuEndpoint 向指定接口发送musicu.fcg请求
+func requestQQMusicuEndpoint(ctx context.Context, e Endpoint, module, method string, param map[string]interface{}) (map[string]interface{}, error) {
+	requestBody := map[string]interface{}{
+		"comm": map[string]interface{}{
+			"ct": 24,
+			"cv": 0,
+		},
+		"req_0": map[string]interface{}{
+			"module": module,
+			"method": method,
+			"param":  param,
+		},
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("构建请求体失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url("/cgi-bin/musicu.fcg"), bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Referer", "https://y.qq.com/")
+	req.Header.Set("Origin", "https://y.qq.com")
+	e.setHeaders(req)
+
+	resp, err := e.client(qqTransport).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("QQ音乐请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取QQ音乐响应失败: %w", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(cleanANSI(body), &result); err != nil {
+		return nil, fmt.Errorf("解析QQ音乐响应失败: %w", err)
+	}
+
+	req0, _ := result["req_0"].(map[string]interface{})
+	if code, ok := req0["code"].(float64); !ok || code != 0 {
+		return nil, fmt.Errorf("QQ音乐API返回错误代码: %v", req0["code"])
+	}
+
+	data, ok := req0["data"].(map[string]interface{})
+	if !ok {
+		return nil, ErrParseError
 	}
-	return s[:maxLen] + "..."
+	return data, nil
 }
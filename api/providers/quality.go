@@ -0,0 +1,113 @@
+package providers
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"time"
+)
+
+// errQualityUnavailable 请求的音质不可用，可以尝试更低的音质
+var errQualityUnavailable = errors.New("该音质不可用")
+
+// Quality 音质等级
+type Quality string
+
+// 支持的音质等级，从低到高
+const (
+	QualityStandard Quality = "standard" // 标准音质，128kbps
+	QualityHigh     Quality = "high"     // 高音质，320kbps
+	QualityLossless Quality = "lossless" // 无损音质，FLAC
+)
+
+// 音质等级从高到低的顺序，请求的音质不可用时依次尝试更低的音质
+var qualityTiers = []Quality{QualityLossless, QualityHigh, QualityStandard}
+
+// ParseQuality 解析音质参数，为空时默认使用高音质
+func ParseQuality(s string) (Quality, bool) {
+	switch q := Quality(s); q {
+	case "":
+		return QualityHigh, true
+	case QualityStandard, QualityHigh, QualityLossless:
+		return q, true
+	default:
+		return "", false
+	}
+}
+
+// Fallbacks 返回该音质及所有更低的音质，按从高到低排列
+func (q Quality) Fallbacks() []Quality {
+	for i, tier := range qualityTiers {
+		if tier == q {
+			return qualityTiers[i:]
+		}
+	}
+	return []Quality{QualityStandard}
+}
+
+// SongURL 定义歌曲的播放地址及实际音质
+type SongURL struct {
+	URL     string
+	Quality Quality   // 实际获取到的音质等级
+	Bitrate int       // 码率(kbps)，未知时为0
+	Format  string    // 文件格式，如 mp3、flac、m4a
+	Expires time.Time // 播放地址的过期时间，上游没有给出时为零值
+}
+
+// expiresIn 根据上游返回的有效秒数计算过期时间，无效时返回零值
+func expiresIn(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(seconds) * time.Second)
+}
+
+// qualityFromBitrate 根据码率(kbps)判断音质等级
+func qualityFromBitrate(kbps int) Quality {
+	switch {
+	case kbps > 320:
+		return QualityLossless
+	case kbps >= 320:
+		return QualityHigh
+	default:
+		return QualityStandard
+	}
+}
+
+// formatFromURL 根据音频URL的扩展名推断文件格式
+func formatFromURL(audioURL string) string {
+	name := audioURL
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")
+}
+
+// parseSongURLItem 解析播放地址接口返回的单个结果，br为码率(bps)，type为文件格式
+func parseSongURLItem(item map[string]interface{}) (*SongURL, bool) {
+	audioURL, _ := item["url"].(string)
+	if audioURL == "" {
+		return nil, false
+	}
+
+	songURL := &SongURL{URL: audioURL}
+	if br, ok := item["br"].(float64); ok {
+		songURL.Bitrate = int(br) / 1000
+	}
+	// expi为播放地址的有效秒数
+	if expi, ok := item["expi"].(float64); ok {
+		songURL.Expires = expiresIn(expi)
+	}
+	if format, ok := item["type"].(string); ok && format != "" {
+		songURL.Format = strings.ToLower(format)
+	} else {
+		songURL.Format = formatFromURL(audioURL)
+	}
+
+	if songURL.Format == "flac" {
+		songURL.Quality = QualityLossless
+	} else {
+		songURL.Quality = qualityFromBitrate(songURL.Bitrate)
+	}
+	return songURL, true
+}
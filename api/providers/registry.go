@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrUnknownProvider 未注册的音乐源
+var ErrUnknownProvider = errors.New("未注册的音乐源")
+
+// 音乐源注册表
+var registry = struct {
+	sync.RWMutex
+	order     []string
+	providers map[string]Provider
+	disabled  map[string]bool
+}{
+	providers: make(map[string]Provider),
+	disabled:  make(map[string]bool),
+}
+
+func init() {
+	// 注册内置音乐源，注册顺序即默认的搜索结果顺序
+	Register(qqProvider{})
+	Register(neteaseProvider{})
+	Register(kuwoProvider{})
+}
+
+// Register 注册一个音乐源，重复注册同名音乐源会覆盖原有实现
+func Register(p Provider) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	name := p.Name()
+	if _, exists := registry.providers[name]; !exists {
+		registry.order = append(registry.order, name)
+	}
+	registry.providers[name] = p
+}
+
+// Get 按名称获取音乐源，未注册或已禁用时返回false
+func Get(name string) (Provider, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	p, ok := registry.providers[name]
+	if !ok || registry.disabled[name] {
+		return nil, false
+	}
+	return p, true
+}
+
+// All 按注册顺序返回所有音乐源(包括已禁用的)
+func All() []Provider {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	list := make([]Provider, 0, len(registry.order))
+	for _, name := range registry.order {
+		list = append(list, registry.providers[name])
+	}
+	return list
+}
+
+// Enabled 按注册顺序返回所有启用的音乐源
+func Enabled() []Provider {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	var list []Provider
+	for _, name := range registry.order {
+		if !registry.disabled[name] {
+			list = append(list, registry.providers[name])
+		}
+	}
+	return list
+}
+
+// IsEnabled 判断音乐源是否启用
+func IsEnabled(name string) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	_, ok := registry.providers[name]
+	return ok && !registry.disabled[name]
+}
+
+// SetEnabled 启用或禁用音乐源
+func SetEnabled(name string, enabled bool) error {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.providers[name]; !ok {
+		return ErrUnknownProvider
+	}
+	if enabled {
+		delete(registry.disabled, name)
+	} else {
+		registry.disabled[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"web_music/api/providers"
+	"web_music/models"
+	"web_music/searchcache"
+)
+
+// 搜索超时设置
+const (
+	searchTimeout       = 20 * time.Second // 整体搜索超时
+	sourceSearchTimeout = 15 * time.Second // 单个音乐源的搜索超时
+)
+
+// 音乐源搜索状态
+const (
+	StatusOK      = "ok"
+	StatusTimeout = "timeout"
+	StatusError   = "error"
+	// 音乐源不支持请求的搜索类型
+	StatusUnsupported = "unsupported"
+)
+
+// 响应头X-Cache的取值
+const (
+	CacheHit     = "HIT"     // 所有音乐源的结果都来自缓存
+	CacheMiss    = "MISS"    // 所有音乐源都请求了上游
+	CachePartial = "PARTIAL" // 部分音乐源的结果来自缓存
+	CacheBypass  = "BYPASS"  // 请求中指定了nocache=1
+)
+
+// searchCache 搜索结果缓存，为空时不缓存
+var searchCache *searchcache.Cache
+
+// SetSearchCache 设置搜索使用的结果缓存
+func SetSearchCache(c *searchcache.Cache) {
+	searchCache = c
+}
+
+// SourceStatus 定义单个音乐源的搜索状态
+type SourceStatus struct {
+	Source  string `json:"source"`
+	Status  string `json:"status"`
+	Count   int    `json:"count"`
+	Total   int    `json:"total"`   // 上游返回的结果总数
+	Latency int64  `json:"latency"` // 毫秒
+	Cached  bool   `json:"cached,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// sourceResult 单个音乐源的搜索结果
+type sourceResult struct {
+	index  int
+	result *providers.SearchResult
+	status SourceStatus
+}
+
+// fanOutSearch 并发地从多个音乐源搜索歌曲，每个音乐源完成后立即发送结果，全部完成后关闭通道
+// noCache为true时不读取缓存，但仍会用新的结果更新缓存
+func fanOutSearch(ctx context.Context, q providers.SearchQuery, sources []string, noCache bool) <-chan sourceResult {
+	results := make(chan sourceResult, len(sources))
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source string) {
+			defer wg.Done()
+			result := searchProvider(ctx, source, q, noCache)
+			result.index = i
+			results <- result
+		}(i, source)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// searchAllProviders 并发地从多个音乐源搜索歌曲，返回在截止时间前完成的结果
+func searchAllProviders(ctx context.Context, q providers.SearchQuery, sources []string, noCache bool) (*providers.SearchResult, []SourceStatus) {
+	ctx, cancel := context.WithTimeout(ctx, searchTimeout)
+	defer cancel()
+
+	results := make([]sourceResult, len(sources))
+	for result := range fanOutSearch(ctx, q, sources, noCache) {
+		results[result.index] = result
+	}
+
+	// 按请求的音乐源顺序合并结果
+	merged := &providers.SearchResult{
+		Songs:     []models.Song{},
+		Albums:    []models.Album{},
+		Artists:   []models.Artist{},
+		Playlists: []models.Playlist{},
+	}
+	statuses := make([]SourceStatus, 0, len(results))
+	for _, result := range results {
+		merged.Songs = append(merged.Songs, result.result.Songs...)
+		merged.Albums = append(merged.Albums, result.result.Albums...)
+		merged.Artists = append(merged.Artists, result.result.Artists...)
+		merged.Playlists = append(merged.Playlists, result.result.Playlists...)
+		merged.Total += result.result.Total
+		statuses = append(statuses, result.status)
+	}
+
+	return merged, statuses
+}
+
+// searchProvider 在单个音乐源的截止时间内执行搜索，优先使用缓存的结果
+func searchProvider(ctx context.Context, source string, q providers.SearchQuery, noCache bool) sourceResult {
+	start := time.Now()
+	status := SourceStatus{Source: source}
+	empty := &providers.SearchResult{}
+
+	provider, ok := providers.Get(source)
+	if !ok {
+		log.Printf("不支持的音乐源: %s", source)
+		status.Status = StatusError
+		status.Error = ErrUnsupportedProvider.Error()
+		return sourceResult{result: empty, status: status}
+	}
+
+	cacheKey := searchcache.NewKey(source, q)
+	if searchCache != nil && !noCache {
+		if result, ok := searchCache.Get(cacheKey); ok {
+			status.Status = StatusOK
+			status.Count = result.Len()
+			status.Total = result.Total
+			status.Cached = true
+			status.Latency = time.Since(start).Milliseconds()
+			return sourceResult{result: result, status: status}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sourceSearchTimeout)
+	defer cancel()
+
+	result, err := provider.Search(ctx, q)
+	status.Latency = time.Since(start).Milliseconds()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Printf("从 %s 搜索超时", source)
+			status.Status = StatusTimeout
+			return sourceResult{result: empty, status: status}
+		}
+		if errors.Is(err, providers.ErrUnsupportedSearchType) {
+			status.Status = StatusUnsupported
+			return sourceResult{result: empty, status: status}
+		}
+		log.Printf("从 %s 搜索失败: %v", source, err)
+		status.Status = StatusError
+		status.Error = err.Error()
+		return sourceResult{result: empty, status: status}
+	}
+
+	// 只缓存成功且有结果的搜索，上游故障时可能返回空结果
+	if searchCache != nil && result.Len() > 0 {
+		searchCache.Set(cacheKey, result)
+	}
+
+	status.Status = StatusOK
+	status.Count = result.Len()
+	status.Total = result.Total
+	return sourceResult{result: result, status: status}
+}
+
+// cacheStatus 根据各音乐源的结果是否来自缓存返回X-Cache响应头的值
+func cacheStatus(statuses []SourceStatus, noCache bool) string {
+	if noCache {
+		return CacheBypass
+	}
+	hits := 0
+	for _, status := range statuses {
+		if status.Cached {
+			hits++
+		}
+	}
+	switch {
+	case hits == 0:
+		return CacheMiss
+	case hits == len(statuses):
+		return CacheHit
+	default:
+		return CachePartial
+	}
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"web_music/api/providers"
+	"web_music/audiocache"
+)
+
+// streamClient 用于代理音频流的HTTP客户端，不设置整体超时以支持长时间播放
+var streamClient = &http.Client{Transport: providers.StreamTransport()}
+
+// 需要从上游响应转发给客户端的响应头
+var forwardedStreamHeaders = []string{
+	"Content-Length",
+	"Content-Range",
+	"Accept-Ranges",
+	"Last-Modified",
+	"ETag",
+}
+
+// StreamHandler 解析歌曲URL并代理音频数据，支持Range请求
+func StreamHandler(w http.ResponseWriter, r *http.Request) {
+	// 设置跨域头
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Range")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges")
+
+	// 处理预检请求
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 仅支持GET和HEAD请求
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "仅支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 获取查询参数
+	id := r.URL.Query().Get("id")
+	source := r.URL.Query().Get("source")
+	title := r.URL.Query().Get("title")
+	artist := r.URL.Query().Get("artist")
+	duration, _ := strconv.Atoi(r.URL.Query().Get("duration"))
+
+	if id == "" || source == "" {
+		http.Error(w, "缺少必要参数", http.StatusBadRequest)
+		return
+	}
+
+	quality, ok := providers.ParseQuality(r.URL.Query().Get("quality"))
+	if !ok {
+		http.Error(w, ErrInvalidQuality.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 优先使用本地缓存，不需要再请求上游
+	if f, ext, ok := openCachedAudio(audioCacheKey(source, id, quality)); ok {
+		serveCachedAudio(w, r, f, ext)
+		return
+	}
+
+	// 解析歌曲URL
+	resolved, err := resolveWithFallback(r.Context(), id, source, title, artist, duration, quality)
+	if err != nil {
+		log.Printf("获取歌曲URL失败: %v", err)
+		http.Error(w, "获取歌曲URL失败", http.StatusBadGateway)
+		return
+	}
+
+	provider, ok := providers.Get(resolved.Source)
+	if !ok {
+		http.Error(w, ErrUnsupportedProvider.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 降低音质或回退到其他音乐源时，实际获取到的音频可能已经缓存
+	cacheKey := resolvedCacheKey(resolved)
+	if cacheKey != nil {
+		if f, ext, ok := openCachedAudio(*cacheKey); ok {
+			serveCachedAudio(w, r, f, ext)
+			return
+		}
+	}
+
+	if !proxyAudio(w, r, resolved.URL, providers.StreamHeaders(provider), cacheKey) {
+		// 缓存的播放地址可能已经失效
+		invalidateSongURL(resolved.ID, resolved.Source, quality)
+	}
+}
+
+// proxyAudio 请求上游音频并转发给客户端，上游不支持Range时在本地跳过不需要的数据
+// cacheKey不为空且上游返回了完整的音频时，同时写入本地缓存
+// 请求上游失败或上游返回错误状态码时返回false，客户端断开导致的失败不算
+func proxyAudio(w http.ResponseWriter, r *http.Request, audioURL string, headers http.Header, cacheKey *audiocache.Key) bool {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, audioURL, nil)
+	if err != nil {
+		http.Error(w, "创建音频请求失败", http.StatusInternalServerError)
+		return true
+	}
+
+	// 设置音乐源需要的请求头，并转发客户端的Range请求
+	for key, values := range headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" {
+		req.Header.Set("If-Range", ifRange)
+	}
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		if r.Context().Err() != nil {
+			// 客户端已断开，播放地址不一定失效
+			return true
+		}
+		log.Printf("请求音频失败: %v", err)
+		http.Error(w, "请求音频失败", http.StatusBadGateway)
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		if contentRange := resp.Header.Get("Content-Range"); contentRange != "" {
+			w.Header().Set("Content-Range", contentRange)
+		}
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return true
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		log.Printf("上游音频返回错误状态码: %d", resp.StatusCode)
+		http.Error(w, "请求音频失败", http.StatusBadGateway)
+		return false
+	}
+
+	body := resp.Body
+	if cacheKey != nil && r.Method == "GET" && isCompleteAudio(resp, rangeHeader) {
+		body = newCachingBody(*cacheKey, resp, audioURL)
+		defer body.Close()
+	}
+
+	w.Header().Set("Content-Type", audioContentType(resp.Header.Get("Content-Type"), audioURL))
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+
+	// 客户端请求了Range但上游返回了完整内容，在本地截取
+	if rangeHeader != "" && resp.StatusCode == http.StatusOK && resp.ContentLength > 0 {
+		start, end, ok := parseByteRange(rangeHeader, resp.ContentLength)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", resp.ContentLength))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return true
+		}
+
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, resp.ContentLength))
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method == "HEAD" {
+			return true
+		}
+
+		if _, err := io.CopyN(io.Discard, resp.Body, start); err != nil {
+			log.Printf("跳过音频数据失败: %v", err)
+			return true
+		}
+		if _, err := io.CopyN(w, resp.Body, end-start+1); err != nil {
+			log.Printf("转发音频数据中断: %v", err)
+		}
+		return true
+	}
+
+	for _, key := range forwardedStreamHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			w.Header().Set(key, value)
+		}
+	}
+	if w.Header().Get("Accept-Ranges") == "" {
+		w.Header().Set("Accept-Ranges", "bytes")
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == "HEAD" {
+		return true
+	}
+
+	if _, err := io.Copy(w, body); err != nil {
+		// 客户端拖动进度条或切歌时会主动断开连接
+		log.Printf("转发音频数据中断: %v", err)
+	}
+	return true
+}
+
+// parseByteRange 解析单个字节范围，如 bytes=100-、bytes=100-199、bytes=-500
+func parseByteRange(header string, size int64) (start, end int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	if startStr == "" {
+		// 后缀范围：最后N个字节
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+
+	end = size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return start, end, true
+}
+
+// audioContentType 返回音频的Content-Type，上游未给出有效类型时根据文件扩展名推断
+func audioContentType(upstream, audioURL string) string {
+	if strings.HasPrefix(upstream, "audio/") {
+		return upstream
+	}
+
+	name := audioURL
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".mp3":
+		return "audio/mpeg"
+	case ".flac":
+		return "audio/flac"
+	case ".m4a", ".mp4":
+		return "audio/mp4"
+	case ".ogg":
+		return "audio/ogg"
+	case ".aac":
+		return "audio/aac"
+	case ".wav":
+		return "audio/wav"
+	}
+
+	if upstream != "" {
+		return upstream
+	}
+	return "application/octet-stream"
+}
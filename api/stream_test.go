@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header     string
+		size       int64
+		start, end int64
+		ok         bool
+	}{
+		{"bytes=0-", 1000, 0, 999, true},
+		{"bytes=100-", 1000, 100, 999, true},
+		{"bytes=100-199", 1000, 100, 199, true},
+		{"bytes=999-999", 1000, 999, 999, true},
+		{"bytes=500-5000", 1000, 500, 999, true},
+		{"bytes= 100-199 ", 1000, 100, 199, true},
+		{"bytes=-500", 1000, 500, 999, true},
+		{"bytes=-5000", 1000, 0, 999, true},
+		{"bytes=1000-", 1000, 0, 0, false},
+		{"bytes=200-100", 1000, 0, 0, false},
+		{"bytes=-0", 1000, 0, 0, false},
+		{"bytes=-", 1000, 0, 0, false},
+		{"bytes=0-99,200-299", 1000, 0, 0, false},
+		{"bytes=abc-", 1000, 0, 0, false},
+		{"bytes=-1-5", 1000, 0, 0, false},
+		{"bytes=100", 1000, 0, 0, false},
+		{"items=0-99", 1000, 0, 0, false},
+		{"", 1000, 0, 0, false},
+		{"bytes=0-", 0, 0, 0, false},
+		{"bytes=-500", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, ok := parseByteRange(tt.header, tt.size)
+		if ok != tt.ok || (ok && (start != tt.start || end != tt.end)) {
+			t.Errorf("parseByteRange(%q, %d) = %d, %d, %v, want %d, %d, %v",
+				tt.header, tt.size, start, end, ok, tt.start, tt.end, tt.ok)
+		}
+	}
+}
+
+func TestProxyAudioFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		audioURL string
+		cancel   bool
+		want     bool
+	}{
+		{"上游无法连接", "http://127.0.0.1:1/1.mp3", false, false},
+		{"客户端断开", server.URL + "/1.mp3", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			r := httptest.NewRequest("GET", "/api/stream", nil).WithContext(ctx)
+			if tt.cancel {
+				go cancel()
+			}
+
+			if got := proxyAudio(httptest.NewRecorder(), r, tt.audioURL, nil, nil); got != tt.want {
+				t.Errorf("proxyAudio() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package audiocache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 写入中的临时文件前缀，启动时清理
+const tempPrefix = ".tmp-"
+
+// Key 缓存键，同一首歌的不同音质分别缓存
+type Key struct {
+	Source  string
+	ID      string
+	Quality string
+}
+
+// hash 返回缓存键对应的文件名(不含扩展名)，避免歌曲ID中的特殊字符
+func (k Key) hash() string {
+	sum := sha256.Sum256([]byte(k.Source + "\x00" + k.ID + "\x00" + k.Quality))
+	return hex.EncodeToString(sum[:16])
+}
+
+// entry 缓存中的一个文件
+type entry struct {
+	hash string
+	name string // 文件名，扩展名为音频格式
+	size int64
+}
+
+// Cache 基于本地目录的音频缓存，总大小超过上限时淘汰最久未使用的文件
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // 最近使用的在前面
+	entries map[string]*list.Element
+}
+
+// Open 打开缓存目录，按文件修改时间恢复使用顺序
+func Open(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取缓存目录失败: %w", err)
+	}
+
+	type cachedFile struct {
+		entry
+		modTime time.Time
+	}
+	var existing []cachedFile
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() {
+			continue
+		}
+		// 上次退出时未写完的文件
+		if strings.HasPrefix(name, tempPrefix) {
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		hash, _, _ := strings.Cut(name, ".")
+		existing = append(existing, cachedFile{entry{hash, name, info.Size()}, info.ModTime()})
+	}
+	sort.Slice(existing, func(i, j int) bool {
+		return existing[i].modTime.After(existing[j].modTime)
+	})
+
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	for _, file := range existing {
+		if _, ok := c.entries[file.hash]; ok {
+			os.Remove(filepath.Join(dir, file.name))
+			continue
+		}
+		c.entries[file.hash] = c.lru.PushBack(&file.entry)
+		c.size += file.size
+	}
+
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// Open 打开缓存的音频文件并标记为最近使用，ext为文件的扩展名(不含点)
+func (c *Cache) Open(key Key) (f *os.File, ext string, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key.hash()]
+	if !ok {
+		return nil, "", false
+	}
+	e := elem.Value.(*entry)
+	path := filepath.Join(c.dir, e.name)
+
+	f, err := os.Open(path)
+	if err != nil {
+		// 文件被外部删除
+		c.remove(elem)
+		return nil, "", false
+	}
+	c.lru.MoveToFront(elem)
+
+	// 更新修改时间，重启后仍能保持使用顺序
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	_, ext, _ = strings.Cut(e.name, ".")
+	return f, ext, true
+}
+
+// Create 开始写入一个缓存文件，写完后调用Commit，写入失败或不完整时调用Abort
+func (c *Cache) Create(key Key, ext string) (*Writer, error) {
+	f, err := os.CreateTemp(c.dir, tempPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("创建缓存文件失败: %w", err)
+	}
+	return &Writer{c: c, hash: key.hash(), ext: ext, f: f}, nil
+}
+
+// Size 返回缓存文件的总大小
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// add 将写好的文件加入缓存，替换同一缓存键的旧文件
+func (c *Cache) add(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[e.hash]; ok {
+		old := elem.Value.(*entry)
+		c.lru.Remove(elem)
+		c.size -= old.size
+		if old.name != e.name {
+			os.Remove(filepath.Join(c.dir, old.name))
+		}
+	}
+	c.entries[e.hash] = c.lru.PushFront(e)
+	c.size += e.size
+	c.evict()
+}
+
+// evict 淘汰最久未使用的文件直到总大小不超过上限，调用时需持有锁
+// 正在读取的文件删除后仍可以读完
+func (c *Cache) evict() {
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		elem := c.lru.Back()
+		e := elem.Value.(*entry)
+		if err := os.Remove(filepath.Join(c.dir, e.name)); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除缓存文件 %s 失败: %v", e.name, err)
+		}
+		c.remove(elem)
+	}
+}
+
+// remove 从索引中移除文件，调用时需持有锁
+func (c *Cache) remove(elem *list.Element) {
+	e := elem.Value.(*entry)
+	c.lru.Remove(elem)
+	delete(c.entries, e.hash)
+	c.size -= e.size
+}
+
+// Writer 写入一个缓存文件，写入错误不会返回给调用者，以免影响正在播放的音频
+type Writer struct {
+	c    *Cache
+	hash string
+	ext  string
+	f    *os.File
+	n    int64
+	err  error
+}
+
+// Write 写入音频数据，出错后忽略后续数据并在Commit时返回错误
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err == nil {
+		n, err := w.f.Write(p)
+		w.n += int64(n)
+		w.err = err
+	}
+	return len(p), nil
+}
+
+// Written 返回已写入的字节数
+func (w *Writer) Written() int64 {
+	return w.n
+}
+
+// Commit 完成写入并加入缓存
+func (w *Writer) Commit() error {
+	if err := w.f.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if w.err != nil {
+		os.Remove(w.f.Name())
+		return fmt.Errorf("写入缓存文件失败: %w", w.err)
+	}
+	// 单个文件超过上限时不缓存
+	if w.n > w.c.maxSize {
+		os.Remove(w.f.Name())
+		return nil
+	}
+
+	name := w.hash + "." + w.ext
+	if err := os.Rename(w.f.Name(), filepath.Join(w.c.dir, name)); err != nil {
+		os.Remove(w.f.Name())
+		return fmt.Errorf("保存缓存文件失败: %w", err)
+	}
+	w.c.add(&entry{hash: w.hash, name: name, size: w.n})
+	return nil
+}
+
+// Abort 放弃写入并删除临时文件
+func (w *Writer) Abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
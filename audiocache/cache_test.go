@@ -0,0 +1,156 @@
+package audiocache
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// put 写入一个缓存文件
+func put(t *testing.T, c *Cache, key Key, data string) {
+	t.Helper()
+	w, err := c.Create(key, "mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(data))
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cached 判断缓存键是否命中，命中时检查文件内容
+func cached(t *testing.T, c *Cache, key Key, want string) bool {
+	t.Helper()
+	f, ext, ok := c.Open(key)
+	if !ok {
+		return false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want || ext != "mp3" {
+		t.Errorf("Open(%v) = %q, %q, want %q, mp3", key, data, ext, want)
+	}
+	return true
+}
+
+func TestEviction(t *testing.T) {
+	c, err := Open(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, d := Key{"qq", "a", "320k"}, Key{"qq", "b", "320k"}, Key{"qq", "d", "320k"}
+
+	put(t, c, a, "aaaa")
+	put(t, c, b, "bbbb")
+	if !cached(t, c, a, "aaaa") { // a变为最近使用
+		t.Fatal("a 未命中")
+	}
+	put(t, c, d, "dddd")
+
+	tests := []struct {
+		key  Key
+		want bool
+	}{
+		{a, true},
+		{b, false},
+		{d, true},
+	}
+	for _, tt := range tests {
+		if got := cached(t, c, tt.key, strings.Repeat(tt.key.ID, 4)); got != tt.want {
+			t.Errorf("Open(%s) ok = %v, want %v", tt.key.ID, got, tt.want)
+		}
+	}
+	if c.Size() != 8 {
+		t.Errorf("Size() = %d, want 8", c.Size())
+	}
+}
+
+func TestKeys(t *testing.T) {
+	c, err := Open(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, c, Key{"qq", "1", "320k"}, "high")
+	put(t, c, Key{"qq", "1", "128k"}, "low")
+	put(t, c, Key{"qq", "1", "128k"}, "lower") // 替换同一个键
+
+	if !cached(t, c, Key{"qq", "1", "320k"}, "high") || !cached(t, c, Key{"qq", "1", "128k"}, "lower") {
+		t.Error("不同音质应分别缓存")
+	}
+	if cached(t, c, Key{"kuwo", "1", "320k"}, "") {
+		t.Error("不同音乐源的同一ID不应命中")
+	}
+	if c.Size() != int64(len("high")+len("lower")) {
+		t.Errorf("Size() = %d, want %d", c.Size(), len("high")+len("lower"))
+	}
+}
+
+func TestWriter(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 放弃写入
+	w, err := c.Create(Key{"qq", "abort", ""}, "mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("abc"))
+	w.Abort()
+
+	// 超过上限的文件不缓存
+	put(t, c, Key{"qq", "large", ""}, "12345")
+
+	for _, id := range []string{"abort", "large"} {
+		if cached(t, c, Key{"qq", id, ""}, "") {
+			t.Errorf("%s 不应被缓存", id)
+		}
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("缓存目录中残留 %d 个文件", len(files))
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []Key{{"qq", "old", ""}, {"qq", "new", ""}}
+	put(t, c, keys[0], "old")
+	put(t, c, keys[1], "new")
+
+	// 按修改时间恢复使用顺序
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, keys[0].hash()+".mp3"), past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, tempPrefix+"unfinished"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 上限只能容纳一个文件，重新打开时淘汰较旧的
+	c, err = Open(dir, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached(t, c, keys[0], "old") {
+		t.Error("较旧的文件应被淘汰")
+	}
+	if !cached(t, c, keys[1], "new") {
+		t.Error("较新的文件应保留")
+	}
+	if _, err := os.Stat(filepath.Join(dir, tempPrefix+"unfinished")); !os.IsNotExist(err) {
+		t.Errorf("未写完的临时文件没有被清理: %v", err)
+	}
+}
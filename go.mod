@@ -1,3 +1,12 @@
 module web_music
 
 go 1.24.0
+
+require (
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/crypto v0.40.0
+	golang.org/x/sync v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.34.0 // indirect
@@ -0,0 +1,88 @@
+package lyrics
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"web_music/models"
+)
+
+// 匹配逐字歌词的行时间，如 [1234,5678]
+var karaokeLineTag = regexp.MustCompile(`^\[(\d+),(\d+)\](.*)$`)
+
+// 匹配QRC的字时间，写在字的后面，如 词(1234,200)
+var qrcWordTag = regexp.MustCompile(`\((\d+),(\d+)\)`)
+
+// 匹配YRC的字时间，写在字的前面，如 (1234,200,0)词
+var yrcWordTag = regexp.MustCompile(`\((\d+),(\d+),\d+\)`)
+
+// 匹配QRC的XML中的歌词内容
+var qrcContentAttr = regexp.MustCompile(`(?s)LyricContent="(.*?)"\s*/>`)
+
+// ParseQRC 解析QRC逐字歌词，支持XML格式和纯文本格式，QQ音乐接口返回的加密歌词先用DecryptQRC解密
+func ParseQRC(text string) []models.KaraokeLine {
+	// 解密后的QRC为XML，歌词内容在LyricContent属性中
+	if m := qrcContentAttr.FindStringSubmatch(text); m != nil {
+		text = html.UnescapeString(m[1])
+	}
+	return parseKaraoke(text, qrcWordTag, false)
+}
+
+// ParseYRC 解析网易云YRC逐字歌词
+func ParseYRC(text string) []models.KaraokeLine {
+	return parseKaraoke(text, yrcWordTag, true)
+}
+
+// parseKaraoke 解析逐字歌词，tagBeforeWord表示字时间写在字的前面(YRC)还是后面(QRC)
+func parseKaraoke(text string, wordTag *regexp.Regexp, tagBeforeWord bool) []models.KaraokeLine {
+	var lines []models.KaraokeLine
+
+	for _, raw := range strings.Split(text, "\n") {
+		// 跳过元信息和YRC中JSON格式的制作信息
+		m := karaokeLineTag.FindStringSubmatch(strings.TrimSpace(raw))
+		if m == nil {
+			continue
+		}
+
+		line := models.KaraokeLine{Words: []models.KaraokeWord{}}
+		line.Time, _ = strconv.Atoi(m[1])
+		line.Duration, _ = strconv.Atoi(m[2])
+		content := m[3]
+
+		// 字的文本位于相邻两个时间标签之间
+		tags := wordTag.FindAllStringSubmatchIndex(content, -1)
+		for i, tag := range tags {
+			var start, end int
+			if tagBeforeWord {
+				start = tag[1]
+				end = len(content)
+				if i+1 < len(tags) {
+					end = tags[i+1][0]
+				}
+			} else {
+				start = 0
+				if i > 0 {
+					start = tags[i-1][1]
+				}
+				end = tag[0]
+			}
+
+			word := models.KaraokeWord{Text: content[start:end]}
+			word.Time, _ = strconv.Atoi(content[tag[2]:tag[3]])
+			word.Duration, _ = strconv.Atoi(content[tag[4]:tag[5]])
+			line.Words = append(line.Words, word)
+			line.Text += word.Text
+		}
+
+		// 没有字时间的行按整行处理
+		if len(tags) == 0 {
+			line.Text = content
+		}
+		line.Text = strings.TrimSpace(line.Text)
+		lines = append(lines, line)
+	}
+
+	return lines
+}
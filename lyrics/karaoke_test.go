@@ -0,0 +1,107 @@
+package lyrics
+
+import (
+	"reflect"
+	"testing"
+
+	"web_music/models"
+)
+
+func TestParseQRC(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []models.KaraokeLine
+	}{
+		{
+			name: "纯文本",
+			text: "[ti:标题]\n[1000,800]你(1000,300)好(1300,500)\n[2000,400]",
+			want: []models.KaraokeLine{
+				{Time: 1000, Duration: 800, Text: "你好", Words: []models.KaraokeWord{
+					{Text: "你", Time: 1000, Duration: 300},
+					{Text: "好", Time: 1300, Duration: 500},
+				}},
+				{Time: 2000, Duration: 400, Text: "", Words: []models.KaraokeWord{}},
+			},
+		},
+		{
+			name: "XML",
+			text: `<?xml version="1.0" encoding="utf-8"?>
+<QrcInfos>
+<LyricInfo LyricCount="1">
+<Lyric_1 LyricType="1" LyricContent="[ti:&quot;标题&quot;]
+[0,600]Hello (0,300)world(300,300)
+"/>
+</LyricInfo>
+</QrcInfos>`,
+			want: []models.KaraokeLine{
+				{Time: 0, Duration: 600, Text: "Hello world", Words: []models.KaraokeWord{
+					{Text: "Hello ", Time: 0, Duration: 300},
+					{Text: "world", Time: 300, Duration: 300},
+				}},
+			},
+		},
+		{
+			name: "没有字时间",
+			text: "[500,1000]整行歌词",
+			want: []models.KaraokeLine{
+				{Time: 500, Duration: 1000, Text: "整行歌词", Words: []models.KaraokeWord{}},
+			},
+		},
+		{
+			name: "空文本",
+			text: "",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseQRC(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQRC() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYRC(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []models.KaraokeLine
+	}{
+		{
+			name: "逐字",
+			text: "[1000,800](1000,300,0)你(1300,500,0)好",
+			want: []models.KaraokeLine{
+				{Time: 1000, Duration: 800, Text: "你好", Words: []models.KaraokeWord{
+					{Text: "你", Time: 1000, Duration: 300},
+					{Text: "好", Time: 1300, Duration: 500},
+				}},
+			},
+		},
+		{
+			name: "跳过JSON制作信息",
+			text: `{"t":0,"c":[{"tx":"作词: "},{"tx":"某人"}]}` + "\n[0,600](0,300,0)Hello (300,300,0)world",
+			want: []models.KaraokeLine{
+				{Time: 0, Duration: 600, Text: "Hello world", Words: []models.KaraokeWord{
+					{Text: "Hello ", Time: 0, Duration: 300},
+					{Text: "world", Time: 300, Duration: 300},
+				}},
+			},
+		},
+		{
+			name: "QRC格式的字时间不会被识别",
+			text: "[0,600]你(0,300)",
+			want: []models.KaraokeLine{
+				{Time: 0, Duration: 600, Text: "你(0,300)", Words: []models.KaraokeWord{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseYRC(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseYRC() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package lyrics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"web_music/models"
+)
+
+// 匹配LRC时间标签，如 [01:23.45]、[01:23.456]、[01:23]
+var lrcTimeTag = regexp.MustCompile(`\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+
+// 匹配LRC元信息标签，如 [ti:标题]、[offset:500]
+var lrcMetaTag = regexp.MustCompile(`^\[([a-zA-Z#]+):(.*)\]$`)
+
+// ParseLRC 解析LRC歌词，返回按时间排序的歌词行
+// 一行有多个时间标签时会展开为多行，[offset:]标签会应用到所有时间上
+func ParseLRC(raw string) []models.LyricLine {
+	var lines []models.LyricLine
+	offset := 0
+
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// 元信息标签
+		if m := lrcMetaTag.FindStringSubmatch(line); m != nil {
+			if strings.EqualFold(m[1], "offset") {
+				offset, _ = strconv.Atoi(strings.TrimSpace(m[2]))
+			}
+			continue
+		}
+
+		// 行首可能有多个连续的时间标签
+		var times []int
+		rest := line
+		for {
+			loc := lrcTimeTag.FindStringSubmatchIndex(rest)
+			if loc == nil || loc[0] != 0 {
+				break
+			}
+			times = append(times, parseTimeTag(rest, loc))
+			rest = rest[loc[1]:]
+		}
+		if len(times) == 0 {
+			continue
+		}
+
+		text := strings.TrimSpace(rest)
+		for _, t := range times {
+			lines = append(lines, models.LyricLine{Time: t, Text: text})
+		}
+	}
+
+	// offset为正表示歌词提前显示
+	if offset != 0 {
+		for i := range lines {
+			lines[i].Time -= offset
+			if lines[i].Time < 0 {
+				lines[i].Time = 0
+			}
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Time < lines[j].Time
+	})
+	return lines
+}
+
+// parseTimeTag 将时间标签转换为毫秒
+func parseTimeTag(s string, loc []int) int {
+	minutes, _ := strconv.Atoi(s[loc[2]:loc[3]])
+	seconds, _ := strconv.Atoi(s[loc[4]:loc[5]])
+	ms := minutes*60000 + seconds*1000
+
+	if loc[6] >= 0 {
+		frac := s[loc[6]:loc[7]]
+		n, _ := strconv.Atoi(frac)
+		// 小数部分可能是1到3位，统一换算成毫秒
+		switch len(frac) {
+		case 1:
+			n *= 100
+		case 2:
+			n *= 10
+		}
+		ms += n
+	}
+	return ms
+}
+
+// FormatTime 将毫秒转换为LRC时间标签，如 [01:23.45]
+func FormatTime(ms int) string {
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("[%02d:%02d.%02d]", ms/60000, ms/1000%60, ms%1000/10)
+}
+
+// Parse 解析歌词中的原文、翻译、罗马音和逐字歌词，填充对应的歌词行
+func Parse(l *models.Lyrics) {
+	l.Lines = ParseLRC(l.LRC)
+	if l.Lines == nil {
+		l.Lines = []models.LyricLine{}
+	}
+	l.TranslationLines = ParseLRC(l.Translation)
+	l.RomanizationLines = ParseLRC(l.Romanization)
+
+	switch l.KaraokeFormat {
+	case models.KaraokeQRC:
+		l.Karaoke = ParseQRC(l.KaraokeText)
+	case models.KaraokeYRC:
+		l.Karaoke = ParseYRC(l.KaraokeText)
+	}
+}
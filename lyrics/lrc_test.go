@@ -0,0 +1,119 @@
+package lyrics
+
+import (
+	"reflect"
+	"testing"
+
+	"web_music/models"
+)
+
+func TestParseLRC(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []models.LyricLine
+	}{
+		{
+			name: "空歌词",
+			raw:  "",
+			want: nil,
+		},
+		{
+			name: "小数位数",
+			raw:  "[00:01.5]一\n[00:02.25]二\n[00:03.125]三\n[00:04]四",
+			want: []models.LyricLine{
+				{Time: 1500, Text: "一"},
+				{Time: 2250, Text: "二"},
+				{Time: 3125, Text: "三"},
+				{Time: 4000, Text: "四"},
+			},
+		},
+		{
+			name: "冒号分隔毫秒",
+			raw:  "[01:02:50]歌词",
+			want: []models.LyricLine{{Time: 62500, Text: "歌词"}},
+		},
+		{
+			name: "多个时间标签按时间排序",
+			raw:  "[00:10.00][00:01.00]副歌\n[00:05.00]主歌",
+			want: []models.LyricLine{
+				{Time: 1000, Text: "副歌"},
+				{Time: 5000, Text: "主歌"},
+				{Time: 10000, Text: "副歌"},
+			},
+		},
+		{
+			name: "跳过元信息和无效行",
+			raw:  "[ti:标题]\n[ar:歌手]\n没有时间标签\n\n  [00:01.00]  歌词  \r\n",
+			want: []models.LyricLine{{Time: 1000, Text: "歌词"}},
+		},
+		{
+			name: "空行保留为间奏",
+			raw:  "[00:01.00]歌词\n[00:03.00]",
+			want: []models.LyricLine{{Time: 1000, Text: "歌词"}, {Time: 3000, Text: ""}},
+		},
+		{
+			name: "offset提前显示且不小于0",
+			raw:  "[offset:500]\n[00:00.20]一\n[00:02.00]二",
+			want: []models.LyricLine{{Time: 0, Text: "一"}, {Time: 1500, Text: "二"}},
+		},
+		{
+			name: "负offset延后显示",
+			raw:  "[offset:-500]\n[00:01.00]一",
+			want: []models.LyricLine{{Time: 1500, Text: "一"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseLRC(tt.raw); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLRC() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	tests := []struct {
+		ms   int
+		want string
+	}{
+		{0, "[00:00.00]"},
+		{-100, "[00:00.00]"},
+		{1234, "[00:01.23]"},
+		{62500, "[01:02.50]"},
+		{3600000, "[60:00.00]"},
+	}
+	for _, tt := range tests {
+		if got := FormatTime(tt.ms); got != tt.want {
+			t.Errorf("FormatTime(%d) = %q, want %q", tt.ms, got, tt.want)
+		}
+	}
+}
+
+func TestFormatTimeRoundTrip(t *testing.T) {
+	for _, ms := range []int{0, 10, 1230, 59990, 754320} {
+		lines := ParseLRC(FormatTime(ms) + "歌词")
+		if len(lines) != 1 || lines[0].Time != ms {
+			t.Errorf("ParseLRC(FormatTime(%d)) = %+v", ms, lines)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	l := &models.Lyrics{
+		Translation:   "[00:01.00]translation",
+		KaraokeFormat: models.KaraokeYRC,
+		KaraokeText:   "[1000,500](1000,500,0)词",
+	}
+	Parse(l)
+
+	if l.Lines == nil || len(l.Lines) != 0 {
+		t.Errorf("Lines = %#v, want empty slice", l.Lines)
+	}
+	if len(l.TranslationLines) != 1 || l.TranslationLines[0].Text != "translation" {
+		t.Errorf("TranslationLines = %+v", l.TranslationLines)
+	}
+	if len(l.Karaoke) != 1 || l.Karaoke[0].Text != "词" {
+		t.Errorf("Karaoke = %+v", l.Karaoke)
+	}
+}
@@ -0,0 +1,208 @@
+package lyrics
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// qrcKey QQ音乐逐字歌词的3DES密钥，依次为三个DES密钥
+var qrcKey = []byte("!@#)(*$%123ZXC!@!@#)(NHL")
+
+// ErrInvalidQRC QRC歌词格式错误
+var ErrInvalidQRC = errors.New("QRC歌词格式错误")
+
+// DecryptQRC 解密QQ音乐的QRC歌词：十六进制解码后用QQ音乐的3DES解密，再用zlib解压
+func DecryptQRC(encrypted string) (string, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(encrypted))
+	if err != nil {
+		return "", fmt.Errorf("解码QRC歌词失败: %w", err)
+	}
+	if len(data) == 0 || len(data)%qqDESBlockSize != 0 {
+		return "", ErrInvalidQRC
+	}
+
+	// ECB模式逐块解密，按3DES的顺序用第三、第二、第一个密钥解密、加密、解密
+	ciphers := [3]*qqDES{newQQDES(qrcKey[16:24]), newQQDES(qrcKey[8:16]), newQQDES(qrcKey[0:8])}
+	for i := 0; i < len(data); i += qqDESBlockSize {
+		block := data[i : i+qqDESBlockSize]
+		ciphers[0].crypt(block, true)
+		ciphers[1].crypt(block, false)
+		ciphers[2].crypt(block, true)
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("解压QRC歌词失败: %w", err)
+	}
+	defer reader.Close()
+
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("解压QRC歌词失败: %w", err)
+	}
+	return string(text), nil
+}
+
+const qqDESBlockSize = 8
+
+// qqDES QQ音乐使用的DES变体，不能用crypto/des代替：
+// 数据块和密钥按两个小端序的32位整数读写，S盒中有两个数与标准DES不同
+type qqDES struct {
+	subkeys [16]uint64 // 每轮的48位子密钥
+}
+
+// 标准DES的置换表，表中的位置从1开始，1为最高位
+var (
+	desInitialPermutation = []byte{
+		58, 50, 42, 34, 26, 18, 10, 2, 60, 52, 44, 36, 28, 20, 12, 4,
+		62, 54, 46, 38, 30, 22, 14, 6, 64, 56, 48, 40, 32, 24, 16, 8,
+		57, 49, 41, 33, 25, 17, 9, 1, 59, 51, 43, 35, 27, 19, 11, 3,
+		61, 53, 45, 37, 29, 21, 13, 5, 63, 55, 47, 39, 31, 23, 15, 7,
+	}
+	desFinalPermutation = []byte{
+		40, 8, 48, 16, 56, 24, 64, 32, 39, 7, 47, 15, 55, 23, 63, 31,
+		38, 6, 46, 14, 54, 22, 62, 30, 37, 5, 45, 13, 53, 21, 61, 29,
+		36, 4, 44, 12, 52, 20, 60, 28, 35, 3, 43, 11, 51, 19, 59, 27,
+		34, 2, 42, 10, 50, 18, 58, 26, 33, 1, 41, 9, 49, 17, 57, 25,
+	}
+	desExpansion = []byte{
+		32, 1, 2, 3, 4, 5, 4, 5, 6, 7, 8, 9, 8, 9, 10, 11, 12, 13, 12, 13, 14, 15, 16, 17,
+		16, 17, 18, 19, 20, 21, 20, 21, 22, 23, 24, 25, 24, 25, 26, 27, 28, 29, 28, 29, 30, 31, 32, 1,
+	}
+	desPermutation = []byte{
+		16, 7, 20, 21, 29, 12, 28, 17, 1, 15, 23, 26, 5, 18, 31, 10,
+		2, 8, 24, 14, 32, 27, 3, 9, 19, 13, 30, 6, 22, 11, 4, 25,
+	}
+	desPermutedChoice1 = []byte{
+		57, 49, 41, 33, 25, 17, 9, 1, 58, 50, 42, 34, 26, 18,
+		10, 2, 59, 51, 43, 35, 27, 19, 11, 3, 60, 52, 44, 36,
+		63, 55, 47, 39, 31, 23, 15, 7, 62, 54, 46, 38, 30, 22,
+		14, 6, 61, 53, 45, 37, 29, 21, 13, 5, 28, 20, 12, 4,
+	}
+	desPermutedChoice2 = []byte{
+		14, 17, 11, 24, 1, 5, 3, 28, 15, 6, 21, 10, 23, 19, 12, 4,
+		26, 8, 16, 7, 27, 20, 13, 2, 41, 52, 31, 37, 47, 55, 30, 40,
+		51, 45, 33, 48, 44, 49, 39, 56, 34, 53, 46, 42, 50, 36, 29, 32,
+	}
+	desKeyShifts = [16]uint{1, 1, 2, 2, 2, 2, 2, 2, 1, 2, 2, 2, 2, 2, 2, 1}
+)
+
+// qqDESSBoxes QQ音乐的S盒，每个S盒按行排列，行号为6位输入的首尾两位
+// 与标准DES相比，S2第2行第8列为15(标准为14)，S4第4行第6列为10(标准为1)
+var qqDESSBoxes = [8][64]byte{
+	{
+		14, 4, 13, 1, 2, 15, 11, 8, 3, 10, 6, 12, 5, 9, 0, 7,
+		0, 15, 7, 4, 14, 2, 13, 1, 10, 6, 12, 11, 9, 5, 3, 8,
+		4, 1, 14, 8, 13, 6, 2, 11, 15, 12, 9, 7, 3, 10, 5, 0,
+		15, 12, 8, 2, 4, 9, 1, 7, 5, 11, 3, 14, 10, 0, 6, 13,
+	},
+	{
+		15, 1, 8, 14, 6, 11, 3, 4, 9, 7, 2, 13, 12, 0, 5, 10,
+		3, 13, 4, 7, 15, 2, 8, 15, 12, 0, 1, 10, 6, 9, 11, 5,
+		0, 14, 7, 11, 10, 4, 13, 1, 5, 8, 12, 6, 9, 3, 2, 15,
+		13, 8, 10, 1, 3, 15, 4, 2, 11, 6, 7, 12, 0, 5, 14, 9,
+	},
+	{
+		10, 0, 9, 14, 6, 3, 15, 5, 1, 13, 12, 7, 11, 4, 2, 8,
+		13, 7, 0, 9, 3, 4, 6, 10, 2, 8, 5, 14, 12, 11, 15, 1,
+		13, 6, 4, 9, 8, 15, 3, 0, 11, 1, 2, 12, 5, 10, 14, 7,
+		1, 10, 13, 0, 6, 9, 8, 7, 4, 15, 14, 3, 11, 5, 2, 12,
+	},
+	{
+		7, 13, 14, 3, 0, 6, 9, 10, 1, 2, 8, 5, 11, 12, 4, 15,
+		13, 8, 11, 5, 6, 15, 0, 3, 4, 7, 2, 12, 1, 10, 14, 9,
+		10, 6, 9, 0, 12, 11, 7, 13, 15, 1, 3, 14, 5, 2, 8, 4,
+		3, 15, 0, 6, 10, 10, 13, 8, 9, 4, 5, 11, 12, 7, 2, 14,
+	},
+	{
+		2, 12, 4, 1, 7, 10, 11, 6, 8, 5, 3, 15, 13, 0, 14, 9,
+		14, 11, 2, 12, 4, 7, 13, 1, 5, 0, 15, 10, 3, 9, 8, 6,
+		4, 2, 1, 11, 10, 13, 7, 8, 15, 9, 12, 5, 6, 3, 0, 14,
+		11, 8, 12, 7, 1, 14, 2, 13, 6, 15, 0, 9, 10, 4, 5, 3,
+	},
+	{
+		12, 1, 10, 15, 9, 2, 6, 8, 0, 13, 3, 4, 14, 7, 5, 11,
+		10, 15, 4, 2, 7, 12, 9, 5, 6, 1, 13, 14, 0, 11, 3, 8,
+		9, 14, 15, 5, 2, 8, 12, 3, 7, 0, 4, 10, 1, 13, 11, 6,
+		4, 3, 2, 12, 9, 5, 15, 10, 11, 14, 1, 7, 6, 0, 8, 13,
+	},
+	{
+		4, 11, 2, 14, 15, 0, 8, 13, 3, 12, 9, 7, 5, 10, 6, 1,
+		13, 0, 11, 7, 4, 9, 1, 10, 14, 3, 5, 12, 2, 15, 8, 6,
+		1, 4, 11, 13, 12, 3, 7, 14, 10, 15, 6, 8, 0, 5, 9, 2,
+		6, 11, 13, 8, 1, 4, 10, 7, 9, 5, 0, 15, 14, 2, 3, 12,
+	},
+	{
+		13, 2, 8, 4, 6, 15, 11, 1, 10, 9, 3, 14, 5, 0, 12, 7,
+		1, 15, 13, 8, 10, 3, 7, 4, 12, 5, 6, 11, 0, 14, 9, 2,
+		7, 11, 4, 1, 9, 12, 14, 2, 0, 6, 10, 13, 15, 3, 5, 8,
+		2, 1, 14, 7, 4, 10, 8, 13, 15, 12, 9, 0, 3, 5, 6, 11,
+	},
+}
+
+// newQQDES 根据8字节密钥生成16轮子密钥
+func newQQDES(key []byte) *qqDES {
+	k := permute(readQQBlock(key), 64, desPermutedChoice1)
+	c, d := uint32(k>>28), uint32(k&0x0fffffff)
+
+	var cipher qqDES
+	for i, shift := range desKeyShifts {
+		c = (c<<shift | c>>(28-shift)) & 0x0fffffff
+		d = (d<<shift | d>>(28-shift)) & 0x0fffffff
+		cipher.subkeys[i] = permute(uint64(c)<<28|uint64(d), 56, desPermutedChoice2)
+	}
+	return &cipher
+}
+
+// crypt 原地加密或解密一个8字节的数据块
+func (c *qqDES) crypt(block []byte, decrypt bool) {
+	b := permute(readQQBlock(block), 64, desInitialPermutation)
+	l, r := uint32(b>>32), uint32(b)
+	for i := 0; i < 16; i++ {
+		key := c.subkeys[i]
+		if decrypt {
+			key = c.subkeys[15-i]
+		}
+		l, r = r, l^qqDESFeistel(r, key)
+	}
+	writeQQBlock(block, permute(uint64(r)<<32|uint64(l), 64, desFinalPermutation))
+}
+
+// qqDESFeistel DES的轮函数：扩展、与子密钥异或、经过S盒后置换
+func qqDESFeistel(r uint32, key uint64) uint32 {
+	x := permute(uint64(r), 32, desExpansion) ^ key
+	var out uint32
+	for i, sbox := range qqDESSBoxes {
+		six := byte(x>>(42-6*i)) & 0x3f
+		row := six>>4&2 | six&1
+		col := six >> 1 & 0x0f
+		out = out<<4 | uint32(sbox[row*16+col])
+	}
+	return uint32(permute(uint64(out), 32, desPermutation))
+}
+
+// permute 按置换表重新排列in的低width位，表中的位置从1开始，1为最高位
+func permute(in uint64, width int, table []byte) uint64 {
+	var out uint64
+	for _, pos := range table {
+		out = out<<1 | in>>(width-int(pos))&1
+	}
+	return out
+}
+
+// readQQBlock 按QQ音乐的字节序读取数据块，前后4字节分别为小端序的高32位和低32位
+func readQQBlock(b []byte) uint64 {
+	return uint64(binary.LittleEndian.Uint32(b[0:4]))<<32 | uint64(binary.LittleEndian.Uint32(b[4:8]))
+}
+
+// writeQQBlock 按QQ音乐的字节序写入数据块
+func writeQQBlock(b []byte, v uint64) {
+	binary.LittleEndian.PutUint32(b[0:4], uint32(v>>32))
+	binary.LittleEndian.PutUint32(b[4:8], uint32(v))
+}
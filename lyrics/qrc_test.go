@@ -0,0 +1,114 @@
+package lyrics
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/des"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// swapWords 反转每4个字节的顺序，在QQ音乐和标准DES的字节序之间转换
+func swapWords(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := 0; i+4 <= len(b); i += 4 {
+		out[i], out[i+1], out[i+2], out[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	return out
+}
+
+// TestQQDESMatchesStandard 换回标准S盒后，除字节序外应与crypto/des的结果相同
+func TestQQDESMatchesStandard(t *testing.T) {
+	saved := qqDESSBoxes
+	qqDESSBoxes[1][16+7] = 14
+	qqDESSBoxes[3][48+5] = 1
+	defer func() { qqDESSBoxes = saved }()
+
+	keys := [][]byte{[]byte("!@#)(*$%"), []byte("123ZXC!@"), {0x13, 0x34, 0x57, 0x79, 0x9B, 0xBC, 0xDF, 0xF1}}
+	blocks := [][]byte{make([]byte, 8), []byte("QRCblock"), {0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF}}
+	for _, key := range keys {
+		std, err := des.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qq := newQQDES(swapWords(key))
+		for _, block := range blocks {
+			want := make([]byte, 8)
+			std.Encrypt(want, block)
+
+			got := swapWords(block)
+			qq.crypt(got, false)
+			if got = swapWords(got); !bytes.Equal(got, want) {
+				t.Errorf("加密 %x (密钥 %x) = %x, want %x", block, key, got, want)
+			}
+
+			back := swapWords(want)
+			qq.crypt(back, true)
+			if back = swapWords(back); !bytes.Equal(back, block) {
+				t.Errorf("解密 %x (密钥 %x) = %x, want %x", want, key, back, block)
+			}
+		}
+	}
+}
+
+// encryptQRC 按DecryptQRC的逆过程加密QRC歌词
+func encryptQRC(t *testing.T, text string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(text))
+	w.Close()
+
+	data := buf.Bytes()
+	if pad := len(data) % qqDESBlockSize; pad != 0 {
+		data = append(data, make([]byte, qqDESBlockSize-pad)...)
+	}
+	ciphers := [3]*qqDES{newQQDES(qrcKey[0:8]), newQQDES(qrcKey[8:16]), newQQDES(qrcKey[16:24])}
+	for i := 0; i < len(data); i += qqDESBlockSize {
+		block := data[i : i+qqDESBlockSize]
+		ciphers[0].crypt(block, false)
+		ciphers[1].crypt(block, true)
+		ciphers[2].crypt(block, false)
+	}
+	return hex.EncodeToString(data)
+}
+
+func TestDecryptQRC(t *testing.T) {
+	qrc := `<?xml version="1.0" encoding="utf-8"?><QrcInfos><LyricInfo LyricCount="1"><Lyric_1 LyricType="1" LyricContent="[0,1000]晴(0,500)天(500,500)&#10;"/></LyricInfo></QrcInfos>`
+
+	text, err := DecryptQRC(encryptQRC(t, qrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != qrc {
+		t.Fatalf("DecryptQRC() = %q, want %q", text, qrc)
+	}
+	if lines := ParseQRC(text); len(lines) != 1 || len(lines[0].Words) != 2 {
+		t.Errorf("ParseQRC() = %+v", lines)
+	}
+}
+
+func TestDecryptQRCInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		encrypted string
+		wantErr   error
+	}{
+		{"空内容", "", ErrInvalidQRC},
+		{"长度不是8的倍数", "00112233", ErrInvalidQRC},
+		{"不是十六进制", "zz", nil},
+		{"不是zlib数据", "0011223344556677", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecryptQRC(tt.encrypted)
+			if err == nil {
+				t.Fatal("DecryptQRC() 应返回错误")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("DecryptQRC() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,12 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"web_music/api"
+	"web_music/api/providers"
+	"web_music/audiocache"
+	"web_music/searchcache"
+	"web_music/store"
+)
+
+const (
+	defaultAudioCacheSize = 2048             // 音频缓存的默认上限，单位MB
+	shutdownTimeout       = 30 * time.Second // 停止服务器时等待处理中请求的时间
 )
 
 func main() {
@@ -14,21 +31,135 @@ func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("云音乐服务启动中...")
 
+	// 请求上游使用的代理和重试次数，UPSTREAM_LOG为1时记录每次上游请求
+	clientOpts, err := upstreamClientOptions()
+	if err == nil {
+		err = providers.SetClientOptions(clientOpts)
+	}
+	if err != nil {
+		log.Fatalf("上游请求设置无效: %v", err)
+	}
+	if os.Getenv("UPSTREAM_LOG") == "1" {
+		providers.AddRequestHook(providers.LogRequest)
+	}
+
+	// 上游接口配置文件，收到SIGHUP信号时重新读取，无需重启
+	if path := os.Getenv("ENDPOINTS_CONFIG"); path != "" {
+		if err := providers.LoadEndpoints(path); err != nil {
+			log.Fatalf("加载接口配置失败: %v", err)
+		}
+		log.Printf("已加载接口配置 %s", path)
+		go reloadEndpointsOnSignal(path)
+	}
+
+	// 设置AUDIO_CACHE_DIR后缓存播放和下载过的音频，AUDIO_CACHE_SIZE为缓存上限(MB)
+	if cacheDir := os.Getenv("AUDIO_CACHE_DIR"); cacheDir != "" {
+		cacheSize := int64(defaultAudioCacheSize)
+		if size := os.Getenv("AUDIO_CACHE_SIZE"); size != "" {
+			n, err := strconv.ParseInt(size, 10, 64)
+			if err != nil || n <= 0 {
+				log.Fatalf("AUDIO_CACHE_SIZE 无效: %s", size)
+			}
+			cacheSize = n
+		}
+		cache, err := audiocache.Open(cacheDir, cacheSize<<20)
+		if err != nil {
+			log.Printf("打开音频缓存失败，将不缓存音频: %v", err)
+		} else {
+			api.SetAudioCache(cache)
+			log.Printf("音频缓存目录 %s，上限 %d MB，已使用 %d MB", cacheDir, cacheSize, cache.Size()>>20)
+		}
+	}
+
+	// 搜索结果缓存，SEARCH_CACHE_TTL为0时不缓存，在打开数据库之前检查设置，设置有误时直接退出
+	searchCache, err := openSearchCache()
+	if err != nil {
+		log.Fatalf("搜索缓存设置无效: %v", err)
+	}
+	if searchCache != nil {
+		api.SetSearchCache(searchCache)
+	}
+
+	// 打开本地数据库，用于保存用户、歌单和收藏
+	dbPath := os.Getenv("DB_PATH")
+	if dbPath == "" {
+		dbPath = filepath.Join("data", "web_music.db")
+	}
+	db, err := store.Open(dbPath)
+	if err != nil {
+		log.Printf("打开数据库失败，用户登录、歌单和收藏将不可用: %v", err)
+	} else {
+		api.SetStore(db)
+
+		// 恢复管理员禁用的音乐源
+		disabled, err := db.DisabledSources()
+		if err != nil {
+			log.Printf("读取音乐源设置失败: %v", err)
+		}
+		for _, name := range disabled {
+			if err := providers.SetEnabled(name, false); err != nil {
+				log.Printf("禁用音乐源 %s 失败: %v", name, err)
+			}
+		}
+	}
+
+	// 禁用环境变量中指定的音乐源，多个源用逗号分隔，不会保存到数据库
+	if disabled := os.Getenv("DISABLED_SOURCES"); disabled != "" {
+		for _, name := range strings.Split(disabled, ",") {
+			name = strings.TrimSpace(name)
+			if err := providers.SetEnabled(name, false); err != nil {
+				log.Printf("禁用音乐源 %s 失败: %v", name, err)
+			}
+		}
+	}
+
+	// 设置为1时所有接口都需要登录，ALLOW_REGISTRATION为1时允许自行注册
+	api.SetAuthOptions(api.AuthOptions{
+		RequireLogin:      os.Getenv("REQUIRE_LOGIN") == "1",
+		AllowRegistration: os.Getenv("ALLOW_REGISTRATION") == "1",
+	})
+
 	// 设置路由
 	setupRoutes()
 
-	// 获取端口，如果环境变量没有设置则使用默认端口8081
+	// 获取端口，如果环境变量没有设置则使用默认端口8082
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8082"
 	}
 
-	// 启动服务器
+	// 启动服务器，收到SIGINT或SIGTERM时等待处理中的请求完成后退出
+	srv := &http.Server{Addr: ":" + port, Handler: api.AuthGate(http.DefaultServeMux)}
+	stopped := make(chan struct{})
+	go func() {
+		shutdownOnSignal(srv)
+		close(stopped)
+	}()
+
 	log.Printf("服务器启动在 http://localhost:%s\n", port)
-	err := http.ListenAndServe(":"+port, nil)
+	err = srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		<-stopped
+		err = nil
+	} else {
+		log.Printf("服务器启动失败: %v", err)
+	}
+
+	// 关闭搜索缓存和数据库，写入尚未保存的数据
+	if searchCache != nil {
+		if err := searchCache.Close(); err != nil {
+			log.Printf("关闭搜索缓存失败: %v", err)
+		}
+	}
+	if db != nil {
+		if err := db.Close(); err != nil {
+			log.Printf("关闭数据库失败: %v", err)
+		}
+	}
 	if err != nil {
-		log.Fatalf("服务器启动失败: %v", err)
+		os.Exit(1)
 	}
+	log.Println("服务器已停止")
 }
 
 // 设置路由
@@ -39,7 +170,29 @@ func setupRoutes() {
 
 	// API路由
 	http.HandleFunc("/api/search", api.SearchHandler)
+	http.HandleFunc("/api/search/stream", api.SearchStreamHandler)
 	http.HandleFunc("/api/song", api.SongHandler)
+	http.HandleFunc("/api/sources", api.SourcesHandler)
+	http.HandleFunc("/api/health/providers", api.ProvidersHealthHandler)
+	http.HandleFunc("/api/stream", api.StreamHandler)
+	http.HandleFunc("/api/lyrics", api.LyricsHandler)
+	http.HandleFunc("/api/download", api.DownloadHandler)
+	http.HandleFunc("/api/playlists", api.PlaylistsHandler)
+	http.HandleFunc("/api/playlists/{id}", api.PlaylistHandler)
+	http.HandleFunc("/api/playlists/{id}/songs", api.PlaylistSongsHandler)
+	http.HandleFunc("/api/playlists/{id}/export", api.PlaylistExportHandler)
+	http.HandleFunc("/api/playlists/{id}/download", api.PlaylistDownloadHandler)
+	http.HandleFunc("/api/favorites", api.FavoritesHandler)
+	http.HandleFunc("/api/history", api.HistoryHandler)
+	http.HandleFunc("/api/import", api.ImportHandler)
+	http.HandleFunc("/api/auth/register", api.RegisterHandler)
+	http.HandleFunc("/api/auth/login", api.LoginHandler)
+	http.HandleFunc("/api/auth/logout", api.LogoutHandler)
+	http.HandleFunc("/api/auth/me", api.MeHandler)
+	http.HandleFunc("/api/tokens", api.TokensHandler)
+	http.HandleFunc("/api/admin/users", api.AdminUsersHandler)
+	http.HandleFunc("/api/admin/users/{id}", api.AdminUserHandler)
+	http.HandleFunc("/api/admin/sources/{name}", api.AdminSourceHandler)
 
 	// 主页
 	http.HandleFunc("/", indexHandler)
@@ -67,3 +220,89 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Write(content)
 }
+
+// reloadEndpointsOnSignal 收到SIGHUP信号时重新读取接口配置，配置有误时继续使用原有设置
+func reloadEndpointsOnSignal(path string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if err := providers.LoadEndpoints(path); err != nil {
+			log.Printf("重新加载接口配置失败，继续使用原有设置: %v", err)
+			continue
+		}
+		log.Printf("已重新加载接口配置 %s", path)
+	}
+}
+
+// shutdownOnSignal 收到SIGINT或SIGTERM信号时关闭服务器，处理中的请求超过shutdownTimeout后强制断开
+func shutdownOnSignal(srv *http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	signal.Stop(signals)
+	log.Printf("收到信号 %s，正在停止服务器...", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("等待请求完成超时，强制关闭连接: %v", err)
+		srv.Close()
+	}
+}
+
+// upstreamClientOptions 根据环境变量生成上游请求设置
+// UPSTREAM_PROXY为出站代理(如socks5://127.0.0.1:1080)，UPSTREAM_RETRIES为GET请求失败后的重试次数
+func upstreamClientOptions() (providers.ClientOptions, error) {
+	opts := providers.ClientOptions{
+		Proxy:      os.Getenv("UPSTREAM_PROXY"),
+		MaxRetries: providers.DefaultMaxRetries,
+		RetryDelay: providers.DefaultRetryDelay,
+	}
+	if value := os.Getenv("UPSTREAM_RETRIES"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("UPSTREAM_RETRIES: 无效的重试次数 %q", value)
+		}
+		opts.MaxRetries = n
+	}
+	return opts, nil
+}
+
+// openSearchCache 根据环境变量创建搜索缓存
+// SEARCH_CACHE_TTL为默认有效期(如10m)，SEARCH_CACHE_TTLS按搜索类型设置(如album=1h,artist=1h)
+// SEARCH_CACHE_PATH不为空时同时缓存到磁盘，重启后仍然有效
+func openSearchCache() (*searchcache.Cache, error) {
+	opts := searchcache.Options{
+		TTL:     searchcache.DefaultTTL,
+		TypeTTL: make(map[providers.SearchType]time.Duration),
+		Path:    os.Getenv("SEARCH_CACHE_PATH"),
+	}
+
+	if value := os.Getenv("SEARCH_CACHE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("SEARCH_CACHE_TTL: %w", err)
+		}
+		if ttl <= 0 {
+			return nil, nil
+		}
+		opts.TTL = ttl
+	}
+
+	if value := os.Getenv("SEARCH_CACHE_TTLS"); value != "" {
+		for _, item := range strings.Split(value, ",") {
+			name, ttlStr, ok := strings.Cut(strings.TrimSpace(item), "=")
+			searchType, valid := providers.ParseSearchType(name)
+			if !ok || !valid || name == "" {
+				return nil, fmt.Errorf("SEARCH_CACHE_TTLS: 无效的设置 %q", item)
+			}
+			ttl, err := time.ParseDuration(ttlStr)
+			if err != nil {
+				return nil, fmt.Errorf("SEARCH_CACHE_TTLS: %w", err)
+			}
+			opts.TypeTTL[searchType] = ttl
+		}
+	}
+
+	return searchcache.New(opts)
+}
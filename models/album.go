@@ -0,0 +1,12 @@
+package models
+
+// Album 表示一张专辑的信息
+type Album struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Artist      string `json:"artist"`
+	Cover       string `json:"cover,omitempty"`
+	SongCount   int    `json:"songCount,omitempty"`
+	PublishTime string `json:"publishTime,omitempty"`
+	Source      string `json:"source"`
+}
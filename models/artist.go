@@ -0,0 +1,11 @@
+package models
+
+// Artist 表示一位歌手的信息
+type Artist struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Avatar     string `json:"avatar,omitempty"`
+	SongCount  int    `json:"songCount,omitempty"`
+	AlbumCount int    `json:"albumCount,omitempty"`
+	Source     string `json:"source"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// LyricLine 表示一行带时间戳的歌词
+type LyricLine struct {
+	Time int    `json:"time"` // 开始时间，单位毫秒
+	Text string `json:"text"`
+}
+
+// Lyrics 表示一首歌曲的歌词，原始歌词为LRC格式
+type Lyrics struct {
+	Source string `json:"source"`
+	ID     string `json:"id"`
+
+	LRC   string      `json:"lrc"`
+	Lines []LyricLine `json:"lines"`
+
+	// 翻译歌词，上游没有时为空
+	Translation      string      `json:"translation,omitempty"`
+	TranslationLines []LyricLine `json:"translationLines,omitempty"`
+
+	// 罗马音歌词，上游没有时为空
+	Romanization      string      `json:"romanization,omitempty"`
+	RomanizationLines []LyricLine `json:"romanizationLines,omitempty"`
+
+	// 逐字歌词，格式为 qrc 或 yrc，上游没有时为空
+	KaraokeFormat string        `json:"karaokeFormat,omitempty"`
+	KaraokeText   string        `json:"-"` // 逐字歌词原文，QRC为解密后的XML
+	Karaoke       []KaraokeLine `json:"karaoke,omitempty"`
+}
+
+// 逐字歌词格式
+const (
+	KaraokeQRC = "qrc" // QQ音乐逐字歌词
+	KaraokeYRC = "yrc" // 网易云逐字歌词
+)
+
+// KaraokeWord 表示逐字歌词中的一个字或词
+type KaraokeWord struct {
+	Time     int    `json:"time"`     // 开始时间，单位毫秒
+	Duration int    `json:"duration"` // 持续时间，单位毫秒
+	Text     string `json:"text"`
+}
+
+// KaraokeLine 表示一行逐字歌词
+type KaraokeLine struct {
+	Time     int           `json:"time"`     // 开始时间，单位毫秒
+	Duration int           `json:"duration"` // 持续时间，单位毫秒
+	Text     string        `json:"text"`     // 整行文本
+	Words    []KaraokeWord `json:"words"`
+}
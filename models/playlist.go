@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Playlist 表示一个歌单的信息
+type Playlist struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Creator   string `json:"creator,omitempty"`
+	Cover     string `json:"cover,omitempty"`
+	SongCount int    `json:"songCount,omitempty"`
+	PlayCount int    `json:"playCount,omitempty"`
+	Source    string `json:"source"`
+}
+
+// UserPlaylist 表示用户在本地保存的歌单
+type UserPlaylist struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Songs     []Song    `json:"songs,omitempty"`
+	SongCount int       `json:"songCount"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -1,12 +1,37 @@
 package models
 
+// 音质标识
+const (
+	Quality128k = "128k"
+	Quality320k = "320k"
+	QualityFLAC = "flac"
+)
+
 // Song 表示一首歌曲的信息
 type Song struct {
-	ID     string `json:"id"`
-	Title  string `json:"title"`
-	Artist string `json:"artist"`
-	Album  string `json:"album,omitempty"`
-	Cover  string `json:"cover,omitempty"`
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	Artist    string   `json:"artist"`
+	ArtistIDs []string `json:"artistIds,omitempty"`
+	Album     string   `json:"album,omitempty"`
+	AlbumID   string   `json:"albumId,omitempty"`
+	Cover     string   `json:"cover,omitempty"`
+	Source    string   `json:"source"`
+	URL       string   `json:"url,omitempty"`
+	Duration  int      `json:"duration,omitempty"`  // 时长，单位秒
+	Qualities []string `json:"qualities,omitempty"` // 可用音质，如 128k、320k、flac
+	VIP       bool     `json:"vip,omitempty"`       // 是否需要会员或付费才能播放完整版
+	// 上游标记为下架或无版权，通常无法播放
+	Unavailable bool `json:"unavailable,omitempty"`
+}
+
+// SongRef 表示歌曲在某个音乐源中的标识，音乐源和ID共同唯一确定一首歌
+type SongRef struct {
 	Source string `json:"source"`
-	URL    string `json:"url,omitempty"`
+	ID     string `json:"id"`
+}
+
+// Ref 返回歌曲的标识
+func (s Song) Ref() SongRef {
+	return SongRef{Source: s.Source, ID: s.ID}
 }
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// 用户角色
+const (
+	RoleAdmin = "admin" // 管理员，可以管理用户和音乐源
+	RoleUser  = "user"  // 普通用户
+)
+
+// User 表示一个本地用户
+type User struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsAdmin 判断用户是否为管理员
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// APIToken 表示用户创建的API令牌，令牌本身只在创建时返回一次
+type APIToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// HistoryEntry 表示一条播放记录
+type HistoryEntry struct {
+	Song     Song      `json:"song"`
+	PlayedAt time.Time `json:"playedAt"`
+}
@@ -0,0 +1,152 @@
+package playlist
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"web_music/models"
+)
+
+// JSONVersion 当前导出的JSON歌单格式版本，格式不兼容时递增
+const JSONVersion = 1
+
+// now 返回导出时间，测试时替换为固定时间
+var now = time.Now
+
+// Entry 表示导出歌单中的一首歌曲，URL为播放器使用的播放地址
+type Entry struct {
+	Song models.Song
+	URL  string
+}
+
+// JSONPlaylist 定义导出的JSON歌单，可以直接提交到歌单接口重新导入
+type JSONPlaylist struct {
+	Version    int           `json:"version"`
+	Name       string        `json:"name"`
+	ExportedAt time.Time     `json:"exportedAt"`
+	Songs      []models.Song `json:"songs"`
+}
+
+// Format 定义一种导出格式
+type Format struct {
+	Ext         string // 文件扩展名
+	ContentType string
+	Write       func(w io.Writer, name string, entries []Entry) error
+}
+
+// Formats 支持的导出格式
+var Formats = map[string]Format{
+	"m3u8": {".m3u8", "audio/x-mpegurl; charset=utf-8", WriteM3U8},
+	"xspf": {".xspf", "application/xspf+xml; charset=utf-8", WriteXSPF},
+	"json": {".json", "application/json; charset=utf-8", WriteJSON},
+}
+
+// WriteM3U8 以扩展M3U格式(UTF-8)写出歌单
+func WriteM3U8(w io.Writer, name string, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#EXTM3U\n")
+	if name != "" {
+		fmt.Fprintf(bw, "#PLAYLIST:%s\n", m3uText(name))
+	}
+
+	for _, e := range entries {
+		// 时长未知时按规范写-1
+		duration := e.Song.Duration
+		if duration <= 0 {
+			duration = -1
+		}
+		fmt.Fprintf(bw, "#EXTINF:%d,%s\n", duration, m3uText(DisplayTitle(e.Song)))
+		bw.WriteString(e.URL + "\n")
+	}
+	return bw.Flush()
+}
+
+// xspfPlaylist XSPF格式的歌单，参考 https://xspf.org/spec
+type xspfPlaylist struct {
+	XMLName   xml.Name    `xml:"playlist"`
+	Version   string      `xml:"version,attr"`
+	Namespace string      `xml:"xmlns,attr"`
+	Title     string      `xml:"title,omitempty"`
+	Date      string      `xml:"date,omitempty"`
+	Tracks    []xspfTrack `xml:"trackList>track"`
+}
+
+// xspfTrack XSPF格式的曲目
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+	Creator  string `xml:"creator,omitempty"`
+	Album    string `xml:"album,omitempty"`
+	Duration int    `xml:"duration,omitempty"` // 毫秒
+	Image    string `xml:"image,omitempty"`
+}
+
+// WriteXSPF 以XSPF格式写出歌单
+func WriteXSPF(w io.Writer, name string, entries []Entry) error {
+	p := xspfPlaylist{
+		Version:   "1",
+		Namespace: "http://xspf.org/ns/0/",
+		Title:     name,
+		Date:      now().Format(time.RFC3339),
+		Tracks:    make([]xspfTrack, 0, len(entries)),
+	}
+	for _, e := range entries {
+		p.Tracks = append(p.Tracks, xspfTrack{
+			Location: e.URL,
+			Title:    e.Song.Title,
+			Creator:  e.Song.Artist,
+			Album:    e.Song.Album,
+			Duration: e.Song.Duration * 1000,
+			Image:    e.Song.Cover,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		return fmt.Errorf("编码XSPF歌单失败: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteJSON 以带版本号的JSON格式写出歌单，歌曲的url为导出时的播放地址
+func WriteJSON(w io.Writer, name string, entries []Entry) error {
+	p := JSONPlaylist{
+		Version:    JSONVersion,
+		Name:       name,
+		ExportedAt: now(),
+		Songs:      make([]models.Song, 0, len(entries)),
+	}
+	for _, e := range entries {
+		song := e.Song
+		song.URL = e.URL
+		p.Songs = append(p.Songs, song)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// DisplayTitle 返回 "歌手 - 标题" 形式的歌曲名称，没有歌手时只返回标题
+func DisplayTitle(song models.Song) string {
+	if song.Artist == "" {
+		return song.Title
+	}
+	return song.Artist + " - " + song.Title
+}
+
+// m3uText 去掉会破坏M3U行结构的换行符
+func m3uText(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,143 @@
+package playlist
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"web_music/models"
+)
+
+// testEntries 导出测试使用的歌曲，包含需要转义的字符
+func testEntries() []Entry {
+	return []Entry{
+		{
+			Song: models.Song{ID: "1", Source: "qq", Title: "晴天", Artist: "周杰伦", Album: "叶惠美", Duration: 269, Cover: "https://y.gtimg.cn/1.jpg"},
+			URL:  "http://localhost:8082/api/stream?id=1&source=qq",
+		},
+		{
+			Song: models.Song{ID: "2", Source: "netease", Title: "Rock & Roll <Live>\n\"Encore\""},
+			URL:  "http://localhost:8082/api/stream?id=2&source=netease",
+		},
+	}
+}
+
+// setNow 将导出时间固定为t，测试结束后恢复
+func setNow(t *testing.T, at time.Time) {
+	t.Helper()
+	old := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = old })
+}
+
+func TestWriteM3U8(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteM3U8(&buf, "我的\n歌单", testEntries()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "#EXTM3U\n" +
+		"#PLAYLIST:我的 歌单\n" +
+		"#EXTINF:269,周杰伦 - 晴天\n" +
+		"http://localhost:8082/api/stream?id=1&source=qq\n" +
+		"#EXTINF:-1,Rock & Roll <Live> \"Encore\"\n" +
+		"http://localhost:8082/api/stream?id=2&source=netease\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteM3U8() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteXSPF(t *testing.T) {
+	setNow(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	var buf bytes.Buffer
+	if err := WriteXSPF(&buf, "Tom & Jerry's <最爱>", testEntries()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <title>Tom &amp; Jerry&#39;s &lt;最爱&gt;</title>
+  <date>2024-05-01T12:00:00Z</date>
+  <trackList>
+    <track>
+      <location>http://localhost:8082/api/stream?id=1&amp;source=qq</location>
+      <title>晴天</title>
+      <creator>周杰伦</creator>
+      <album>叶惠美</album>
+      <duration>269000</duration>
+      <image>https://y.gtimg.cn/1.jpg</image>
+    </track>
+    <track>
+      <location>http://localhost:8082/api/stream?id=2&amp;source=netease</location>
+      <title>Rock &amp; Roll &lt;Live&gt;&#xA;&#34;Encore&#34;</title>
+    </track>
+  </trackList>
+</playlist>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteXSPF() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	setNow(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, "Tom & Jerry's <最爱>", testEntries()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+  "version": 1,
+  "name": "Tom & Jerry's <最爱>",
+  "exportedAt": "2024-05-01T12:00:00Z",
+  "songs": [
+    {
+      "id": "1",
+      "title": "晴天",
+      "artist": "周杰伦",
+      "album": "叶惠美",
+      "cover": "https://y.gtimg.cn/1.jpg",
+      "source": "qq",
+      "url": "http://localhost:8082/api/stream?id=1&source=qq",
+      "duration": 269
+    },
+    {
+      "id": "2",
+      "title": "Rock & Roll <Live>\n\"Encore\"",
+      "artist": "",
+      "source": "netease",
+      "url": "http://localhost:8082/api/stream?id=2&source=netease"
+    }
+  ]
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteJSON() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	setNow(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"m3u8", "#EXTM3U\n"},
+		{"xspf", `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<playlist version="1" xmlns="http://xspf.org/ns/0/">` + "\n  <date>2024-05-01T12:00:00Z</date>\n  <trackList></trackList>\n</playlist>\n"},
+		{"json", "{\n  \"version\": 1,\n  \"name\": \"\",\n  \"exportedAt\": \"2024-05-01T12:00:00Z\",\n  \"songs\": []\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Formats[tt.format].Write(&buf, "", nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("导出空歌单 =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
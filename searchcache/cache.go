@@ -0,0 +1,276 @@
+package searchcache
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"web_music/api/providers"
+)
+
+// 默认设置
+const (
+	DefaultTTL        = 10 * time.Minute
+	DefaultMaxEntries = 10000
+)
+
+// 清理磁盘中过期结果的间隔
+const pruneInterval = time.Hour
+
+// 磁盘缓存使用的bucket
+var searchBucket = []byte("search")
+
+// Key 搜索缓存的键，关键词忽略大小写和多余的空格
+type Key struct {
+	Source   string
+	Keyword  string
+	Type     providers.SearchType
+	Page     int
+	PageSize int
+}
+
+// NewKey 根据音乐源和搜索参数生成缓存键
+func NewKey(source string, q providers.SearchQuery) Key {
+	return Key{
+		Source:   source,
+		Keyword:  strings.ToLower(strings.Join(strings.Fields(q.Keyword), " ")),
+		Type:     q.Type,
+		Page:     q.Page,
+		PageSize: q.PageSize,
+	}
+}
+
+// bytes 返回键在磁盘缓存中的编码
+func (k Key) bytes() []byte {
+	return []byte(strings.Join([]string{
+		k.Source, string(k.Type), strconv.Itoa(k.Page), strconv.Itoa(k.PageSize), k.Keyword,
+	}, "\x00"))
+}
+
+// Options 定义缓存设置
+type Options struct {
+	TTL        time.Duration                          // 默认的有效期
+	TypeTTL    map[providers.SearchType]time.Duration // 按搜索类型设置的有效期，覆盖TTL
+	MaxEntries int                                    // 内存中最多保存的结果数量
+	Path       string                                 // 磁盘缓存文件，为空时只缓存在内存中
+}
+
+// item 缓存的搜索结果
+type item struct {
+	Expires time.Time               `json:"expires"`
+	Result  *providers.SearchResult `json:"result"`
+
+	key Key
+}
+
+// Cache 搜索结果缓存，内存中超过数量上限时淘汰最久未使用的结果
+type Cache struct {
+	opts Options
+	db   *bolt.DB
+
+	mu      sync.Mutex
+	lru     *list.List // 最近使用的在前面
+	entries map[Key]*list.Element
+
+	stop chan struct{}
+}
+
+// New 创建搜索缓存，设置了Path时同时打开磁盘缓存
+func New(opts Options) (*Cache, error) {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultMaxEntries
+	}
+
+	c := &Cache{
+		opts:    opts,
+		lru:     list.New(),
+		entries: make(map[Key]*list.Element),
+		stop:    make(chan struct{}),
+	}
+	if opts.Path == "" {
+		return c, nil
+	}
+
+	if dir := filepath.Dir(opts.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+		}
+	}
+	db, err := bolt.Open(opts.Path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开搜索缓存失败: %w", err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(searchBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化搜索缓存失败: %w", err)
+	}
+	c.db = db
+
+	c.prune()
+	go func() {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.prune()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+// Close 关闭磁盘缓存
+func (c *Cache) Close() error {
+	close(c.stop)
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// TTL 返回搜索类型对应的有效期
+func (c *Cache) TTL(t providers.SearchType) time.Duration {
+	if ttl, ok := c.opts.TypeTTL[t]; ok {
+		return ttl
+	}
+	return c.opts.TTL
+}
+
+// Get 返回未过期的搜索结果，返回的结果不能修改
+func (c *Cache) Get(key Key) (*providers.SearchResult, bool) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		it := elem.Value.(*item)
+		if now.Before(it.Expires) {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			return it.Result, true
+		}
+		c.remove(elem)
+	}
+	c.mu.Unlock()
+
+	if c.db == nil {
+		return nil, false
+	}
+
+	var it item
+	err := c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(searchBucket).Get(key.bytes())
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &it)
+	})
+	if err != nil {
+		log.Printf("读取搜索缓存失败: %v", err)
+		return nil, false
+	}
+	if it.Result == nil || !now.Before(it.Expires) {
+		return nil, false
+	}
+
+	// 放入内存，下次不再读取磁盘
+	it.key = key
+	c.mu.Lock()
+	c.add(&it)
+	c.mu.Unlock()
+	return it.Result, true
+}
+
+// Set 保存搜索结果，有效期为0的搜索类型不缓存
+func (c *Cache) Set(key Key, result *providers.SearchResult) {
+	ttl := c.TTL(key.Type)
+	if ttl <= 0 {
+		return
+	}
+	it := &item{Expires: time.Now().Add(ttl), Result: result, key: key}
+
+	c.mu.Lock()
+	c.add(it)
+	c.mu.Unlock()
+
+	if c.db == nil {
+		return
+	}
+	data, err := json.Marshal(it)
+	if err != nil {
+		log.Printf("编码搜索缓存失败: %v", err)
+		return
+	}
+	if err := c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(searchBucket).Put(key.bytes(), data)
+	}); err != nil {
+		log.Printf("写入搜索缓存失败: %v", err)
+	}
+}
+
+// add 将结果放入内存，替换同一个键的旧结果，调用时需持有锁
+func (c *Cache) add(it *item) {
+	if elem, ok := c.entries[it.key]; ok {
+		c.remove(elem)
+	}
+	c.entries[it.key] = c.lru.PushFront(it)
+	for c.lru.Len() > c.opts.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove 从内存中移除结果，调用时需持有锁
+func (c *Cache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*item).key)
+}
+
+// prune 删除磁盘中过期的结果
+func (c *Cache) prune() {
+	now := time.Now()
+	removed := 0
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(searchBucket)
+
+		// 遍历时删除会跳过部分键，先收集再删除
+		var expired [][]byte
+		bucket.ForEach(func(k, v []byte) error {
+			var it item
+			if err := json.Unmarshal(v, &it); err != nil || !now.Before(it.Expires) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(expired)
+		return nil
+	})
+	if err != nil {
+		log.Printf("清理搜索缓存失败: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("清理了 %d 条过期的搜索缓存", removed)
+	}
+}
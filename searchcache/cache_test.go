@@ -0,0 +1,146 @@
+package searchcache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"web_music/api/providers"
+	"web_music/models"
+)
+
+func newResult(name string) *providers.SearchResult {
+	return &providers.SearchResult{Songs: []models.Song{{Title: name}}, Total: 1}
+}
+
+func TestNewKey(t *testing.T) {
+	q := providers.SearchQuery{Keyword: "Jay", Type: providers.SearchTypeSong, Page: 1, PageSize: 20}
+	tests := []struct {
+		name    string
+		keyword string
+		same    bool
+	}{
+		{"大小写", "jay", true},
+		{"多余空格", "  JAY ", true},
+		{"不同关键词", "jay chou", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := q
+			other.Keyword = tt.keyword
+			if got := NewKey("qq", q) == NewKey("qq", other); got != tt.same {
+				t.Errorf("NewKey(%q) == NewKey(%q) = %v, want %v", q.Keyword, tt.keyword, got, tt.same)
+			}
+		})
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c, err := New(Options{
+		TTL:     time.Minute,
+		TypeTTL: map[providers.SearchType]time.Duration{providers.SearchTypeAlbum: time.Hour, providers.SearchTypeLyric: 0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		searchType providers.SearchType
+		want       time.Duration
+	}{
+		{providers.SearchTypeSong, time.Minute},
+		{providers.SearchTypeAlbum, time.Hour},
+		{providers.SearchTypeLyric, 0},
+	}
+	for _, tt := range tests {
+		if got := c.TTL(tt.searchType); got != tt.want {
+			t.Errorf("TTL(%s) = %s, want %s", tt.searchType, got, tt.want)
+		}
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	tests := []struct {
+		name       string
+		searchType providers.SearchType
+		wait       time.Duration
+		want       bool
+	}{
+		{"未过期", providers.SearchTypeSong, 0, true},
+		{"已过期", providers.SearchTypeAlbum, 30 * time.Millisecond, false},
+		{"不缓存的类型", providers.SearchTypeLyric, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(Options{
+				TTL: time.Minute,
+				TypeTTL: map[providers.SearchType]time.Duration{
+					providers.SearchTypeAlbum: 10 * time.Millisecond,
+					providers.SearchTypeLyric: 0,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			key := NewKey("netease", providers.SearchQuery{Keyword: "test", Type: tt.searchType})
+			c.Set(key, newResult("a"))
+			time.Sleep(tt.wait)
+			if _, ok := c.Get(key); ok != tt.want {
+				t.Errorf("Get() ok = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestEviction(t *testing.T) {
+	c, err := New(Options{MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	keys := make([]Key, 3)
+	for i, keyword := range []string{"a", "b", "c"} {
+		keys[i] = NewKey("kuwo", providers.SearchQuery{Keyword: keyword, Type: providers.SearchTypeSong})
+	}
+	c.Set(keys[0], newResult("a"))
+	c.Set(keys[1], newResult("b"))
+	c.Get(keys[0]) // a变为最近使用
+	c.Set(keys[2], newResult("c"))
+
+	for i, want := range []bool{true, false, true} {
+		if _, ok := c.Get(keys[i]); ok != want {
+			t.Errorf("Get(%q) ok = %v, want %v", keys[i].Keyword, ok, want)
+		}
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.db")
+	key := NewKey("qq", providers.SearchQuery{Keyword: "test", Type: providers.SearchTypeSong})
+
+	c, err := New(Options{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set(key, newResult("a"))
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = New(Options{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	result, ok := c.Get(key)
+	if !ok {
+		t.Fatal("重新打开后没有读取到缓存")
+	}
+	if len(result.Songs) != 1 || result.Songs[0].Title != "a" {
+		t.Errorf("Get() = %+v", result)
+	}
+}
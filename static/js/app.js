@@ -8,6 +8,14 @@ const state = {
     currentTime: 0,
     duration: 0,
     volume: 0.7,
+    lyrics: [], // 当前歌曲的歌词行
+    karaoke: [], // 当前歌曲的逐字歌词行
+    karaokeLine: null, // 正在显示的逐字歌词行
+    playlistId: null, // 服务器上对应播放列表的歌单ID
+    serverSync: false, // 是否已与服务器同步
+    user: null, // 当前登录的用户
+    auth: {}, // 服务器的登录设置
+    authMode: 'login', // 登录框的模式: login 或 register
 };
 
 // DOM 元素
@@ -35,6 +43,20 @@ const elements = {
     volumeIcon: document.getElementById('volume-icon'),
     volumeSlider: document.querySelector('.volume-slider'),
     volumeProgress: document.querySelector('.volume-progress'),
+    qualitySelect: document.getElementById('quality-select'),
+    currentLyric: document.getElementById('current-lyric'),
+    userName: document.getElementById('user-name'),
+    loginButton: document.getElementById('login-button'),
+    logoutButton: document.getElementById('logout-button'),
+    authModal: document.getElementById('auth-modal'),
+    authForm: document.getElementById('auth-form'),
+    authTitle: document.getElementById('auth-title'),
+    authUsername: document.getElementById('auth-username'),
+    authPassword: document.getElementById('auth-password'),
+    authError: document.getElementById('auth-error'),
+    authSubmit: document.getElementById('auth-submit'),
+    authSwitch: document.getElementById('auth-switch'),
+    authClose: document.getElementById('auth-close'),
 };
 
 // 初始化
@@ -52,6 +74,9 @@ function init() {
     
     // 添加事件监听器
     setupEventListeners();
+    
+    // 获取登录状态，登录后从服务器加载播放列表和收藏列表
+    loadCurrentUser();
 }
 
 // 从本地存储加载数据
@@ -71,6 +96,160 @@ function loadFromLocalStorage() {
     }
 }
 
+// 保存到本地存储，作为服务器不可用时的备份
+function saveToLocalStorage() {
+    localStorage.setItem('playlist', JSON.stringify(state.playlist));
+    localStorage.setItem('favorites', JSON.stringify(state.favorites));
+}
+
+// 请求服务器的接口
+async function apiRequest(method, url, body) {
+    const options = { method, headers: {} };
+    if (body !== undefined) {
+        options.headers['Content-Type'] = 'application/json';
+        options.body = JSON.stringify(body);
+    }
+    
+    const response = await fetch(url, options);
+    if (!response.ok) {
+        throw new Error(`${method} ${url} 失败: ${response.status}`);
+    }
+    return response.status === 204 ? null : response.json();
+}
+
+// 获取当前登录的用户，需要登录时显示登录框
+async function loadCurrentUser() {
+    try {
+        state.auth = await apiRequest('GET', '/api/auth/me');
+    } catch (error) {
+        console.warn('无法获取登录状态，使用本地存储:', error);
+        return;
+    }
+    
+    state.user = state.auth.user;
+    updateUserArea();
+    if (state.user) {
+        syncWithServer();
+    } else if (state.auth.setupRequired) {
+        // 还没有用户时，第一个注册的用户成为管理员
+        showAuthModal('register');
+    } else if (state.auth.requireLogin) {
+        showAuthModal('login');
+    }
+}
+
+// 更新头部的用户信息
+function updateUserArea() {
+    elements.userName.textContent = state.user ? state.user.username : '';
+    elements.loginButton.hidden = !!state.user;
+    elements.logoutButton.hidden = !state.user;
+}
+
+// 显示登录或注册框
+function showAuthModal(mode) {
+    state.authMode = mode;
+    const register = mode === 'register';
+    elements.authTitle.textContent = state.auth.setupRequired ? '创建管理员账号' : (register ? '注册' : '登录');
+    elements.authSubmit.textContent = register ? '注册' : '登录';
+    elements.authSwitch.textContent = register ? '已有账号？登录' : '没有账号？注册';
+    elements.authSwitch.hidden = state.auth.setupRequired || !state.auth.allowRegistration;
+    elements.authClose.hidden = !!state.auth.requireLogin;
+    elements.authPassword.autocomplete = register ? 'new-password' : 'current-password';
+    elements.authError.textContent = '';
+    elements.authModal.classList.add('active');
+    elements.authUsername.focus();
+}
+
+// 提交登录或注册
+async function handleAuthSubmit(e) {
+    e.preventDefault();
+    
+    const url = state.authMode === 'register' ? '/api/auth/register' : '/api/auth/login';
+    const response = await fetch(url, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+            username: elements.authUsername.value.trim(),
+            password: elements.authPassword.value,
+        }),
+    });
+    if (!response.ok) {
+        elements.authError.textContent = (await response.text()).trim() || '登录失败';
+        return;
+    }
+    
+    state.user = await response.json();
+    state.auth.setupRequired = false;
+    elements.authPassword.value = '';
+    elements.authModal.classList.remove('active');
+    updateUserArea();
+    syncWithServer();
+}
+
+// 退出登录，之后只使用本地存储
+async function handleLogout() {
+    try {
+        await apiRequest('POST', '/api/auth/logout');
+    } catch (error) {
+        console.warn('退出登录失败:', error);
+    }
+    
+    state.user = null;
+    state.playlistId = null;
+    state.serverSync = false;
+    updateUserArea();
+    if (state.auth.requireLogin) {
+        showAuthModal('login');
+    }
+}
+
+// 与服务器同步播放列表和收藏，服务器不可用时继续使用本地存储
+async function syncWithServer() {
+    // 每个用户在服务器上有各自的播放列表
+    const playlistKey = `playlistId_${state.user.id}`;
+    state.playlistId = localStorage.getItem(playlistKey);
+    
+    try {
+        // 服务器上没有收藏时，把本地的收藏上传到服务器
+        let favorites = await apiRequest('GET', '/api/favorites');
+        if (favorites.songs.length === 0 && state.favorites.length > 0) {
+            favorites = await apiRequest('POST', '/api/favorites', { songs: state.favorites });
+        }
+        
+        // 播放列表对应服务器上的一个歌单，不存在时用本地的播放列表创建
+        let playlist = null;
+        if (state.playlistId) {
+            playlist = await apiRequest('GET', `/api/playlists/${state.playlistId}`).catch(() => null);
+        }
+        if (!playlist) {
+            playlist = await apiRequest('POST', '/api/playlists', { name: '播放列表', songs: state.playlist });
+        }
+        
+        state.favorites = favorites.songs;
+        state.playlist = playlist.songs || [];
+        state.playlistId = playlist.id;
+        state.serverSync = true;
+        localStorage.setItem(playlistKey, playlist.id);
+        saveToLocalStorage();
+        
+        renderPlaylist();
+        renderFavorites();
+    } catch (error) {
+        console.warn('无法与服务器同步，使用本地存储:', error);
+    }
+}
+
+// 将歌曲的添加或移除同步到服务器
+function syncSong(listURL, method, song) {
+    if (!state.serverSync) return;
+    
+    const url = method === 'DELETE'
+        ? `${listURL}?source=${encodeURIComponent(song.source)}&id=${encodeURIComponent(song.id)}`
+        : listURL;
+    apiRequest(method, url, method === 'DELETE' ? undefined : song)
+        .catch(error => console.warn('同步到服务器失败:', error));
+}
+
 // 设置事件监听器
 function setupEventListeners() {
     // 搜索按钮点击
@@ -110,6 +289,36 @@ function setupEventListeners() {
         state.duration = elements.audioPlayer.duration;
         elements.totalTime.textContent = formatTime(state.duration);
     });
+    
+    // 导出播放列表，需要登录并已同步到服务器
+    document.getElementById('export-playlist').addEventListener('change', (e) => {
+        const format = e.target.value;
+        e.target.value = '';
+        if (!format) return;
+        if (!state.serverSync || !state.playlistId) {
+            alert('登录后才能导出播放列表');
+            return;
+        }
+        const quality = elements.qualitySelect ? elements.qualitySelect.value : 'high';
+        if (format === 'zip') {
+            window.location.href = `/api/playlists/${state.playlistId}/download?quality=${quality}`;
+            return;
+        }
+        window.location.href = `/api/playlists/${state.playlistId}/export?format=${format}&quality=${quality}`;
+    });
+    
+    // 登录和退出
+    elements.loginButton.addEventListener('click', () => showAuthModal('login'));
+    elements.logoutButton.addEventListener('click', handleLogout);
+    elements.authForm.addEventListener('submit', handleAuthSubmit);
+    elements.authSwitch.addEventListener('click', (e) => {
+        e.preventDefault();
+        showAuthModal(state.authMode === 'register' ? 'login' : 'register');
+    });
+    elements.authClose.addEventListener('click', (e) => {
+        e.preventDefault();
+        elements.authModal.classList.remove('active');
+    });
 }
 
 // 处理搜索
@@ -117,6 +326,12 @@ async function handleSearch() {
     const searchTerm = elements.searchInput.value.trim();
     if (!searchTerm) return;
     
+    // 粘贴的是歌单、专辑或歌曲的分享链接时直接导入
+    if (/https?:\/\//.test(searchTerm)) {
+        importFromURL(searchTerm);
+        return;
+    }
+    
     // 获取选中的音乐源
     const selectedSources = [];
     elements.sourceCheckboxes.forEach(checkbox => {
@@ -137,6 +352,12 @@ async function handleSearch() {
         </div>
     `;
     
+    // 浏览器支持EventSource时使用流式搜索，先返回的音乐源先显示
+    if (window.EventSource) {
+        streamSearch(searchTerm, selectedSources);
+        return;
+    }
+    
     try {
         // 发送搜索请求到后端API
         const response = await fetch(`/api/search?keyword=${encodeURIComponent(searchTerm)}&sources=${selectedSources.join(',')}`);
@@ -162,6 +383,81 @@ async function handleSearch() {
     }
 }
 
+// 导入分享链接中的歌曲，显示在搜索结果中，登录后可以保存为歌单
+async function importFromURL(shareURL) {
+    elements.searchResultsList.innerHTML = `
+        <div class="loading">
+            <div class="loading-spinner"></div>
+        </div>
+    `;
+    switchTab('search-results', document.querySelector('.menu-item[data-tab="search-results"]'));
+    
+    try {
+        const response = await fetch(`/api/import?url=${encodeURIComponent(shareURL)}`);
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || '导入失败');
+        }
+        
+        const data = await response.json();
+        state.searchResults = data.songs || [];
+        elements.resultsTotal.textContent = state.searchResults.length;
+        renderSearchResults();
+        
+        if (state.user && data.kind !== 'song' && state.searchResults.length > 0 &&
+            confirm(`已获取「${data.name}」中的${state.searchResults.length}首歌曲，是否保存为歌单？`)) {
+            await apiRequest('POST', '/api/playlists', { name: data.name || '导入的歌单', songs: data.songs });
+        }
+    } catch (error) {
+        console.error('导入失败:', error);
+        const message = document.createElement('div');
+        message.className = 'empty-message';
+        message.textContent = error.message;
+        elements.searchResultsList.replaceChildren(message);
+    }
+}
+
+// 流式搜索，每个音乐源返回结果后立即渲染
+function streamSearch(searchTerm, selectedSources) {
+    // 关闭上一次未完成的搜索
+    if (state.searchStream) {
+        state.searchStream.close();
+    }
+    
+    state.searchResults = [];
+    switchTab('search-results', document.querySelector('.menu-item[data-tab="search-results"]'));
+    
+    const source = new EventSource(`/api/search/stream?keyword=${encodeURIComponent(searchTerm)}&sources=${selectedSources.join(',')}`);
+    state.searchStream = source;
+    
+    // 单个音乐源的搜索结果
+    source.addEventListener('result', (e) => {
+        const data = JSON.parse(e.data);
+        if (!data.songs || data.songs.length === 0) return;
+        
+        state.searchResults = state.searchResults.concat(data.songs);
+        elements.resultsTotal.textContent = state.searchResults.length;
+        renderSearchResults();
+    });
+    
+    // 所有音乐源搜索完成
+    source.addEventListener('done', () => {
+        source.close();
+        state.searchStream = null;
+        elements.resultsTotal.textContent = state.searchResults.length;
+        renderSearchResults();
+    });
+    
+    source.onerror = () => {
+        source.close();
+        state.searchStream = null;
+        if (state.searchResults.length === 0) {
+            elements.searchResultsList.innerHTML = `
+                <div class="empty-message">搜索失败，请稍后重试</div>
+            `;
+        }
+    };
+}
+
 // 渲染搜索结果
 function renderSearchResults() {
     if (state.searchResults.length === 0) {
@@ -218,15 +514,20 @@ function createSongElement(song, number, listType) {
     const sourceName = getSourceName(song.source);
     const defaultCover = '/static/images/default-cover.jpg';
     
+    // 上游标记为下架的歌曲置灰显示
+    const itemClass = song.unavailable ? 'song-item unavailable' : 'song-item';
+    const duration = song.duration ? formatTime(song.duration) : '';
+    
     return `
-        <div class="song-item" data-id="${song.id}" data-source="${song.source}">
+        <div class="${itemClass}" data-id="${song.id}" data-source="${song.source}" title="${song.unavailable ? '该歌曲可能无法播放' : ''}">
             <div class="song-number">${number}</div>
             <img class="song-cover" src="${song.cover || defaultCover}" onerror="this.src='${defaultCover}'" alt="${song.title}">
             <div class="song-info">
                 <div class="song-title">${song.title}</div>
                 <div class="song-artist">${song.artist}</div>
             </div>
-            <div class="song-source">${sourceName}</div>
+            <div class="song-duration">${duration}</div>
+            <div class="song-source">${sourceName}${song.vip ? ' · VIP' : ''}</div>
             <div class="song-actions">
                 <button class="song-action-btn play-song">
                     <i class="fas fa-play"></i>
@@ -240,6 +541,9 @@ function createSongElement(song, number, listType) {
                 <button class="song-action-btn toggle-favorite">
                     <i class="fas ${isInFavorites ? 'fa-heart' : 'fa-heart-o'}"></i>
                 </button>
+                <button class="song-action-btn download-song" title="下载">
+                    <i class="fas fa-download"></i>
+                </button>
             </div>
         </div>
     `;
@@ -315,6 +619,41 @@ function addSongItemEvents(listType) {
             }
         });
     });
+    
+    // 下载歌曲
+    container.querySelectorAll('.download-song').forEach(button => {
+        button.addEventListener('click', (e) => {
+            const songItem = e.target.closest('.song-item');
+            const songId = songItem.getAttribute('data-id');
+            const songSource = songItem.getAttribute('data-source');
+            
+            const songList = listType === 'search' ? state.searchResults :
+                           listType === 'playlist' ? state.playlist :
+                           state.favorites;
+            
+            const song = songList.find(item => item.id === songId && item.source === songSource);
+            
+            if (song) {
+                downloadSong(song);
+            }
+        });
+    });
+}
+
+// 下载歌曲，服务端会写入标题、歌手、封面和歌词等标签
+function downloadSong(song) {
+    const quality = elements.qualitySelect ? elements.qualitySelect.value : 'high';
+    const params = new URLSearchParams({
+        id: song.id,
+        source: song.source,
+        quality: quality,
+        title: song.title || '',
+        artist: song.artist || '',
+        album: song.album || '',
+        cover: song.cover || '',
+        duration: song.duration || 0
+    });
+    window.location.href = `/api/download?${params.toString()}`;
 }
 
 // 切换标签页
@@ -336,7 +675,9 @@ function switchTab(tabId, item) {
 async function playSong(song) {
     try {
         // 获取音乐URL
-        const response = await fetch(`/api/song?id=${song.id}&source=${song.source}`);
+        // 附带标题和歌手，原音乐源无法播放时服务端会回退到其他音乐源
+        const quality = elements.qualitySelect ? elements.qualitySelect.value : 'high';
+        const response = await fetch(`/api/song?id=${encodeURIComponent(song.id)}&source=${song.source}&title=${encodeURIComponent(song.title)}&artist=${encodeURIComponent(song.artist || '')}&duration=${song.duration || 0}&quality=${quality}`);
         
         if (!response.ok) {
             throw new Error('获取音乐URL失败');
@@ -347,6 +688,13 @@ async function playSong(song) {
             throw new Error('无法获取音乐URL');
         }
         
+        if (data.fallback) {
+            console.info(`原音乐源无法播放，已切换到${getSourceName(data.source)}`);
+        }
+        if (data.quality && data.quality !== quality) {
+            console.info(`请求的音质不可用，实际音质: ${data.format || ''} ${data.bitrate ? data.bitrate + 'kbps' : ''}`);
+        }
+        
         // 更新当前播放歌曲信息
         state.currentSong = song;
         
@@ -355,12 +703,19 @@ async function playSong(song) {
         elements.currentSongArtist.textContent = song.artist;
         elements.currentSongCover.src = song.cover || '/static/images/default-cover.jpg';
         
-        // 设置音频源并播放
-        elements.audioPlayer.src = data.url;
+        // 通过服务端代理播放，避免上游的防盗链和混合内容限制
+        loadLyrics(data.source || song.source, data.id || song.id);
+        elements.audioPlayer.src = `/api/stream?id=${encodeURIComponent(data.id || song.id)}&source=${data.source || song.source}&quality=${data.quality || quality}`;
         elements.audioPlayer.play()
             .then(() => {
                 state.isPlaying = true;
                 elements.playButton.innerHTML = '<i class="fas fa-pause"></i>';
+                
+                // 登录后记录播放历史
+                if (state.user) {
+                    apiRequest('POST', '/api/history', song)
+                        .catch(error => console.warn('记录播放历史失败:', error));
+                }
             })
             .catch(error => {
                 console.error('播放失败:', error);
@@ -439,6 +794,89 @@ function updateProgress() {
     
     // 更新时间显示
     elements.currentTime.textContent = formatTime(state.currentTime);
+    
+    updateLyric();
+}
+
+// 加载当前歌曲的歌词
+async function loadLyrics(source, id) {
+    state.lyrics = [];
+    state.karaoke = [];
+    state.karaokeLine = null;
+    if (elements.currentLyric) {
+        elements.currentLyric.textContent = '';
+    }
+    
+    try {
+        const response = await fetch(`/api/lyrics?id=${encodeURIComponent(id)}&source=${source}&karaoke=1`);
+        if (!response.ok) {
+            return;
+        }
+        
+        const data = await response.json();
+        // 有翻译时将翻译附在原文后面
+        const translations = new Map((data.translationLines || []).map(line => [line.time, line.text]));
+        state.lyrics = (data.lines || []).map(line => {
+            const translation = translations.get(line.time);
+            return {
+                time: line.time,
+                text: translation ? `${line.text} / ${translation}` : line.text,
+            };
+        });
+        state.karaoke = data.karaoke || [];
+    } catch (error) {
+        console.error('加载歌词失败:', error);
+    }
+}
+
+// 根据播放进度显示当前歌词，有逐字歌词时逐字高亮
+function updateLyric() {
+    if (!elements.currentLyric) return;
+    
+    const now = state.currentTime * 1000;
+    if (state.karaoke.length > 0) {
+        updateKaraoke(now);
+        return;
+    }
+    if (state.lyrics.length === 0) return;
+    
+    let text = '';
+    for (const line of state.lyrics) {
+        if (line.time > now) break;
+        text = line.text;
+    }
+    if (elements.currentLyric.textContent !== text) {
+        elements.currentLyric.textContent = text;
+    }
+}
+
+// 显示当前逐字歌词行并高亮已唱过的字
+function updateKaraoke(now) {
+    let current = null;
+    for (const line of state.karaoke) {
+        if (line.time > now) break;
+        current = line;
+    }
+    
+    // 切换到新的一行时重新生成每个字的元素
+    if (current !== state.karaokeLine) {
+        state.karaokeLine = current;
+        elements.currentLyric.textContent = '';
+        if (current) {
+            const words = current.words.length > 0 ? current.words : [{ time: current.time, text: current.text }];
+            for (const word of words) {
+                const span = document.createElement('span');
+                span.className = 'lyric-word';
+                span.textContent = word.text;
+                span.dataset.time = word.time;
+                elements.currentLyric.appendChild(span);
+            }
+        }
+    }
+    
+    for (const span of elements.currentLyric.children) {
+        span.classList.toggle('sung', Number(span.dataset.time) <= now);
+    }
 }
 
 // 跳转到指定位置
@@ -514,8 +952,9 @@ function addToPlaylist(song) {
     if (!exists) {
         state.playlist.push(song);
         
-        // 保存到本地存储
+        // 保存到本地存储和服务器
         localStorage.setItem('playlist', JSON.stringify(state.playlist));
+        syncSong(`/api/playlists/${state.playlistId}/songs`, 'POST', song);
         
         // 更新播放列表UI
         renderPlaylist();
@@ -539,10 +978,11 @@ function removeFromPlaylist(songId, songSource) {
                             state.currentSong.source === songSource;
         
         // 从播放列表中移除
-        state.playlist.splice(index, 1);
+        const [removed] = state.playlist.splice(index, 1);
         
-        // 保存到本地存储
+        // 保存到本地存储和服务器
         localStorage.setItem('playlist', JSON.stringify(state.playlist));
+        syncSong(`/api/playlists/${state.playlistId}/songs`, 'DELETE', removed);
         
         // 更新播放列表UI
         renderPlaylist();
@@ -577,10 +1017,12 @@ function toggleFavorite(song, button) {
         // 添加到收藏
         state.favorites.push(song);
         iconElement.className = 'fas fa-heart';
+        syncSong('/api/favorites', 'POST', song);
     } else {
         // 从收藏中移除
         state.favorites.splice(existingIndex, 1);
         iconElement.className = 'fas fa-heart-o';
+        syncSong('/api/favorites', 'DELETE', song);
         
         // 如果当前在收藏列表页面，需要更新UI
         if (document.getElementById('favorites').classList.contains('active')) {
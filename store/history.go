@@ -0,0 +1,102 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"web_music/models"
+)
+
+// 每个用户最多保留的播放记录数量
+const maxHistoryEntries = 500
+
+// 存放播放记录的bucket，每个用户一个子bucket，键为递增序号
+var historyBucket = []byte("history")
+
+// AddHistory 添加一条播放记录，超过上限时删除最早的记录
+func (s *Store) AddHistory(userID string, song models.Song) error {
+	song.URL = ""
+	entry := models.HistoryEntry{Song: song, PlayedAt: time.Now()}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := createUserBucket(tx, historyBucket, userID)
+		if err != nil {
+			return err
+		}
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("编码播放记录失败: %w", err)
+		}
+
+		// 大端序的序号保证按时间顺序遍历
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		if err := b.Put(key, data); err != nil {
+			return err
+		}
+
+		excess := b.Stats().KeyN - maxHistoryEntries
+		if excess <= 0 {
+			return nil
+		}
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && len(keys) < excess; k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// History 返回用户最近的播放记录，最新的在前
+func (s *Store) History(userID string, limit int) ([]models.HistoryEntry, error) {
+	if limit <= 0 || limit > maxHistoryEntries {
+		limit = maxHistoryEntries
+	}
+
+	entries := []models.HistoryEntry{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := userBucket(tx, historyBucket, userID)
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil && len(entries) < limit; k, v = c.Prev() {
+			var entry models.HistoryEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ClearHistory 清空用户的播放记录
+func (s *Store) ClearHistory(userID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if userBucket(tx, historyBucket, userID) == nil {
+			return nil
+		}
+		return tx.Bucket(historyBucket).DeleteBucket([]byte(userID))
+	})
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 存放服务器设置的bucket
+var settingsBucket = []byte("settings")
+
+// 被管理员禁用的音乐源列表
+const disabledSourcesKey = "disabledSources"
+
+// DisabledSources 返回管理员禁用的音乐源，不包括环境变量中禁用的
+func (s *Store) DisabledSources() ([]string, error) {
+	var sources []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		sources, err = disabledSources(tx)
+		return err
+	})
+	return sources, err
+}
+
+// SetSourceDisabled 保存管理员对单个音乐源的设置
+func (s *Store) SetSourceDisabled(name string, disabled bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		sources, err := disabledSources(tx)
+		if err != nil {
+			return err
+		}
+
+		updated := []string{}
+		for _, source := range sources {
+			if source != name {
+				updated = append(updated, source)
+			}
+		}
+		if disabled {
+			updated = append(updated, name)
+		}
+		return putJSON(tx.Bucket(settingsBucket), disabledSourcesKey, updated)
+	})
+}
+
+// disabledSources 读取管理员禁用的音乐源
+func disabledSources(tx *bolt.Tx) ([]string, error) {
+	data := tx.Bucket(settingsBucket).Get([]byte(disabledSourcesKey))
+	if data == nil {
+		return nil, nil
+	}
+	var sources []string
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, fmt.Errorf("解析音乐源设置失败: %w", err)
+	}
+	return sources, nil
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSetSourceDisabled(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	steps := []struct {
+		name     string
+		disabled bool
+		want     []string
+	}{
+		{"kuwo", true, []string{"kuwo"}},
+		{"qq", true, []string{"kuwo", "qq"}},
+		{"kuwo", true, []string{"qq", "kuwo"}},
+		{"qq", false, []string{"kuwo"}},
+		{"netease", false, []string{"kuwo"}},
+		{"kuwo", false, []string{}},
+	}
+	for i, step := range steps {
+		if err := s.SetSourceDisabled(step.name, step.disabled); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.DisabledSources()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("第%d步 SetSourceDisabled(%s, %v) 后 DisabledSources() = %v, want %v", i+1, step.name, step.disabled, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"web_music/models"
+)
+
+// FavoritesID 收藏列表使用的保留歌单ID
+const FavoritesID = "favorites"
+
+// 定义错误
+var (
+	ErrNotFound     = errors.New("歌单不存在")
+	ErrEmptyName    = errors.New("歌单名称不能为空")
+	ErrReserved     = errors.New("收藏列表不能重命名或删除")
+	ErrInvalidOrder = errors.New("排序列表与歌单中的歌曲不一致")
+)
+
+// 存放歌单的bucket，每个用户一个子bucket，键为歌单ID，值为JSON编码的歌单
+// 升级前没有用户时歌单直接保存在该bucket下，由第一个用户接管
+var playlistsBucket = []byte("playlists")
+
+// 数据库中所有的顶层bucket
+var buckets = [][]byte{
+	playlistsBucket,
+	usersBucket,
+	usernamesBucket,
+	sessionsBucket,
+	historyBucket,
+	settingsBucket,
+}
+
+// Store 基于bbolt的本地存储
+type Store struct {
+	db *bolt.DB
+}
+
+// Open 打开或创建数据库文件
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("创建数据目录失败: %w", err)
+		}
+	}
+
+	// 数据库文件被其他进程占用时不无限等待
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化数据库失败: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Playlists 按创建时间返回用户的所有歌单(不包括收藏列表)，结果中不包含歌曲
+func (s *Store) Playlists(userID string) ([]models.UserPlaylist, error) {
+	playlists := []models.UserPlaylist{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := userBucket(tx, playlistsBucket, userID)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if string(k) == FavoritesID {
+				return nil
+			}
+			var p models.UserPlaylist
+			if err := json.Unmarshal(v, &p); err != nil {
+				return fmt.Errorf("解析歌单 %s 失败: %w", k, err)
+			}
+			p.Songs = nil
+			playlists = append(playlists, p)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(playlists, func(i, j int) bool {
+		return playlists[i].CreatedAt.Before(playlists[j].CreatedAt)
+	})
+	return playlists, nil
+}
+
+// Playlist 获取用户的歌单及其歌曲，收藏列表不存在时返回空列表
+func (s *Store) Playlist(userID, id string) (*models.UserPlaylist, error) {
+	var p *models.UserPlaylist
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		p, err = getPlaylist(userBucket(tx, playlistsBucket, userID), id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CreatePlaylist 为用户创建歌单，可以同时添加歌曲
+func (s *Store) CreatePlaylist(userID, name string, songs []models.Song) (*models.UserPlaylist, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrEmptyName
+	}
+
+	var p *models.UserPlaylist
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := createUserBucket(tx, playlistsBucket, userID)
+		if err != nil {
+			return err
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		p = &models.UserPlaylist{
+			ID:        strconv.FormatUint(seq, 10),
+			Name:      name,
+			Songs:     []models.Song{},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		addSongs(p, songs)
+		return putPlaylist(b, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// RenamePlaylist 重命名歌单
+func (s *Store) RenamePlaylist(userID, id, name string) (*models.UserPlaylist, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrEmptyName
+	}
+	if id == FavoritesID {
+		return nil, ErrReserved
+	}
+
+	return s.update(userID, id, func(p *models.UserPlaylist) error {
+		p.Name = name
+		return nil
+	})
+}
+
+// DeletePlaylist 删除歌单
+func (s *Store) DeletePlaylist(userID, id string) error {
+	if id == FavoritesID {
+		return ErrReserved
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := userBucket(tx, playlistsBucket, userID)
+		if b == nil || b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// AddSongs 向歌单末尾添加歌曲，已经在歌单中的歌曲会被忽略
+func (s *Store) AddSongs(userID, id string, songs []models.Song) (*models.UserPlaylist, error) {
+	return s.update(userID, id, func(p *models.UserPlaylist) error {
+		addSongs(p, songs)
+		return nil
+	})
+}
+
+// RemoveSong 从歌单中移除歌曲
+func (s *Store) RemoveSong(userID, id string, ref models.SongRef) (*models.UserPlaylist, error) {
+	return s.update(userID, id, func(p *models.UserPlaylist) error {
+		for i, song := range p.Songs {
+			if song.Ref() == ref {
+				p.Songs = append(p.Songs[:i], p.Songs[i+1:]...)
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// ReorderSongs 按给定的顺序重新排列歌单中的歌曲，order必须包含歌单中的所有歌曲
+func (s *Store) ReorderSongs(userID, id string, order []models.SongRef) (*models.UserPlaylist, error) {
+	return s.update(userID, id, func(p *models.UserPlaylist) error {
+		if len(order) != len(p.Songs) {
+			return ErrInvalidOrder
+		}
+
+		songs := make(map[models.SongRef]models.Song, len(p.Songs))
+		for _, song := range p.Songs {
+			songs[song.Ref()] = song
+		}
+
+		reordered := make([]models.Song, 0, len(order))
+		for _, ref := range order {
+			song, ok := songs[ref]
+			if !ok {
+				return ErrInvalidOrder
+			}
+			// 同一首歌不能出现两次
+			delete(songs, ref)
+			reordered = append(reordered, song)
+		}
+		p.Songs = reordered
+		return nil
+	})
+}
+
+// update 在事务中修改歌单并保存，收藏列表不存在时自动创建
+func (s *Store) update(userID, id string, fn func(p *models.UserPlaylist) error) (*models.UserPlaylist, error) {
+	var p *models.UserPlaylist
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := createUserBucket(tx, playlistsBucket, userID)
+		if err != nil {
+			return err
+		}
+
+		p, err = getPlaylist(b, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+
+		p.UpdatedAt = time.Now()
+		return putPlaylist(b, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// getPlaylist 读取歌单，收藏列表不存在时返回一个新的空列表，b为空表示用户还没有歌单
+func getPlaylist(b *bolt.Bucket, id string) (*models.UserPlaylist, error) {
+	var data []byte
+	if b != nil {
+		data = b.Get([]byte(id))
+	}
+	if data == nil {
+		if id == FavoritesID {
+			now := time.Now()
+			return &models.UserPlaylist{
+				ID:        FavoritesID,
+				Name:      "我的收藏",
+				Songs:     []models.Song{},
+				CreatedAt: now,
+				UpdatedAt: now,
+			}, nil
+		}
+		return nil, ErrNotFound
+	}
+
+	var p models.UserPlaylist
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("解析歌单 %s 失败: %w", id, err)
+	}
+	if p.Songs == nil {
+		p.Songs = []models.Song{}
+	}
+	return &p, nil
+}
+
+// putPlaylist 保存歌单
+func putPlaylist(b *bolt.Bucket, p *models.UserPlaylist) error {
+	p.SongCount = len(p.Songs)
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("编码歌单失败: %w", err)
+	}
+	return b.Put([]byte(p.ID), data)
+}
+
+// addSongs 添加不在歌单中的歌曲
+func addSongs(p *models.UserPlaylist, songs []models.Song) {
+	exists := make(map[models.SongRef]bool, len(p.Songs))
+	for _, song := range p.Songs {
+		exists[song.Ref()] = true
+	}
+
+	for _, song := range songs {
+		ref := song.Ref()
+		if ref.Source == "" || ref.ID == "" || exists[ref] {
+			continue
+		}
+		exists[ref] = true
+
+		// 播放地址会过期，不保存
+		song.URL = ""
+		p.Songs = append(p.Songs, song)
+	}
+}
+
+// userBucket 返回用户在顶层bucket下的子bucket，不存在时返回nil
+func userBucket(tx *bolt.Tx, name []byte, userID string) *bolt.Bucket {
+	return tx.Bucket(name).Bucket([]byte(userID))
+}
+
+// createUserBucket 返回用户在顶层bucket下的子bucket，不存在时创建
+func createUserBucket(tx *bolt.Tx, name []byte, userID string) (*bolt.Bucket, error) {
+	if userID == "" {
+		return nil, ErrUserNotFound
+	}
+	return tx.Bucket(name).CreateBucketIfNotExists([]byte(userID))
+}
+
+// adoptLegacyPlaylists 将升级前直接保存在顶层bucket下的歌单移动到用户的子bucket中
+func adoptLegacyPlaylists(tx *bolt.Tx, userID string) error {
+	root := tx.Bucket(playlistsBucket)
+
+	// 子bucket的值为nil，只收集歌单
+	legacy := make(map[string][]byte)
+	err := root.ForEach(func(k, v []byte) error {
+		if v != nil {
+			legacy[string(k)] = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if err != nil || len(legacy) == 0 {
+		return err
+	}
+
+	b, err := createUserBucket(tx, playlistsBucket, userID)
+	if err != nil {
+		return err
+	}
+	// 沿用原来的序号，避免新歌单的ID与移动过来的歌单冲突
+	if err := b.SetSequence(root.Sequence()); err != nil {
+		return err
+	}
+	for k, v := range legacy {
+		if err := b.Put([]byte(k), v); err != nil {
+			return err
+		}
+		if err := root.Delete([]byte(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
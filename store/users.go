@@ -0,0 +1,491 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+
+	"web_music/models"
+)
+
+// SessionTTL 登录会话的有效期
+const SessionTTL = 30 * 24 * time.Hour
+
+// 密码最短长度
+const minPasswordLength = 6
+
+// 定义错误
+var (
+	ErrUserNotFound       = errors.New("用户不存在")
+	ErrUserExists         = errors.New("用户名已存在")
+	ErrInvalidUsername    = errors.New("用户名只能包含字母、数字、下划线、点和横线，长度为3到32")
+	ErrWeakPassword       = errors.New("密码长度不能少于6位")
+	ErrInvalidRole        = errors.New("无效的用户角色")
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	ErrInvalidToken       = errors.New("登录已失效")
+	ErrTokenNotFound      = errors.New("API令牌不存在")
+	ErrLastAdmin          = errors.New("不能删除或降级最后一个管理员")
+)
+
+var validUsername = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+// 会话类型
+const (
+	kindSession = "session" // 浏览器登录会话
+	kindToken   = "token"   // API令牌
+)
+
+var (
+	usersBucket     = []byte("users")     // 用户ID -> 用户信息
+	usernamesBucket = []byte("usernames") // 小写用户名 -> 用户ID
+	sessionsBucket  = []byte("sessions")  // 令牌的SHA-256 -> 会话信息
+)
+
+// userRecord 数据库中保存的用户信息
+type userRecord struct {
+	models.User
+	PasswordHash string `json:"passwordHash"`
+}
+
+// sessionRecord 数据库中保存的会话或API令牌，不保存令牌明文
+type sessionRecord struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"` // API令牌不过期
+}
+
+// UserCount 返回用户数量
+func (s *Store) UserCount() (int, error) {
+	count := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(usersBucket).Stats().KeyN
+		return nil
+	})
+	return count, err
+}
+
+// CreateUser 创建用户，第一个用户自动成为管理员并接管升级前保存的歌单
+func (s *Store) CreateUser(username, password, role string) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if !validUsername.MatchString(username) {
+		return nil, ErrInvalidUsername
+	}
+	if len(password) < minPasswordLength {
+		return nil, ErrWeakPassword
+	}
+	if role != models.RoleAdmin && role != models.RoleUser {
+		return nil, ErrInvalidRole
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("计算密码哈希失败: %w", err)
+	}
+
+	var user *models.User
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		usernames := tx.Bucket(usernamesBucket)
+
+		key := []byte(strings.ToLower(username))
+		if usernames.Get(key) != nil {
+			return ErrUserExists
+		}
+
+		first := users.Stats().KeyN == 0
+		if first {
+			role = models.RoleAdmin
+		}
+
+		seq, err := users.NextSequence()
+		if err != nil {
+			return err
+		}
+		record := userRecord{
+			User: models.User{
+				ID:        strconv.FormatUint(seq, 10),
+				Username:  username,
+				Role:      role,
+				CreatedAt: time.Now(),
+			},
+			PasswordHash: string(hash),
+		}
+		if err := putJSON(users, record.ID, record); err != nil {
+			return err
+		}
+		if err := usernames.Put(key, []byte(record.ID)); err != nil {
+			return err
+		}
+
+		if first {
+			if err := adoptLegacyPlaylists(tx, record.ID); err != nil {
+				return err
+			}
+		}
+
+		user = &record.User
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Authenticate 校验用户名和密码
+func (s *Store) Authenticate(username, password string) (*models.User, error) {
+	var record *userRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(usernamesBucket).Get([]byte(strings.ToLower(strings.TrimSpace(username))))
+		if id == nil {
+			return ErrInvalidCredentials
+		}
+		var err error
+		record, err = getUser(tx, string(id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(record.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &record.User, nil
+}
+
+// Users 按创建顺序返回所有用户
+func (s *Store) Users() ([]models.User, error) {
+	users := []models.User{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+			var record userRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("解析用户 %s 失败: %w", k, err)
+			}
+			users = append(users, record.User)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+	return users, nil
+}
+
+// UpdateUser 修改用户角色或密码，参数为空时不修改，修改密码后用户需要重新登录
+func (s *Store) UpdateUser(id, role, password string) (*models.User, error) {
+	if role != "" && role != models.RoleAdmin && role != models.RoleUser {
+		return nil, ErrInvalidRole
+	}
+	if password != "" && len(password) < minPasswordLength {
+		return nil, ErrWeakPassword
+	}
+
+	var hash []byte
+	if password != "" {
+		var err error
+		hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("计算密码哈希失败: %w", err)
+		}
+	}
+
+	var user *models.User
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		record, err := getUser(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if role != "" && role != record.Role {
+			if record.Role == models.RoleAdmin {
+				if err := checkNotLastAdmin(tx); err != nil {
+					return err
+				}
+			}
+			record.Role = role
+		}
+		if hash != nil {
+			record.PasswordHash = string(hash)
+			if err := deleteSessions(tx, id, kindSession); err != nil {
+				return err
+			}
+		}
+
+		if err := putJSON(tx.Bucket(usersBucket), id, record); err != nil {
+			return err
+		}
+		user = &record.User
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// DeleteUser 删除用户及其歌单、播放记录和登录会话
+func (s *Store) DeleteUser(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		record, err := getUser(tx, id)
+		if err != nil {
+			return err
+		}
+		if record.Role == models.RoleAdmin {
+			if err := checkNotLastAdmin(tx); err != nil {
+				return err
+			}
+		}
+
+		if err := deleteSessions(tx, id, ""); err != nil {
+			return err
+		}
+		for _, name := range [][]byte{playlistsBucket, historyBucket} {
+			if tx.Bucket(name).Bucket([]byte(id)) != nil {
+				if err := tx.Bucket(name).DeleteBucket([]byte(id)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Bucket(usernamesBucket).Delete([]byte(strings.ToLower(record.Username))); err != nil {
+			return err
+		}
+		return tx.Bucket(usersBucket).Delete([]byte(id))
+	})
+}
+
+// CreateSession 为用户创建登录会话，返回会话令牌
+func (s *Store) CreateSession(userID string) (string, error) {
+	token, _, err := s.createSession(userID, kindSession, "", time.Now().Add(SessionTTL))
+	return token, err
+}
+
+// CreateToken 为用户创建不过期的API令牌，令牌明文只在此时返回
+func (s *Store) CreateToken(userID, name string) (string, *models.APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "API令牌"
+	}
+	token, record, err := s.createSession(userID, kindToken, name, time.Time{})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &models.APIToken{ID: record.ID, Name: record.Name, CreatedAt: record.CreatedAt}, nil
+}
+
+// Tokens 返回用户的所有API令牌
+func (s *Store) Tokens(userID string) ([]models.APIToken, error) {
+	tokens := []models.APIToken{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+			var record sessionRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return nil
+			}
+			if record.UserID == userID && record.Kind == kindToken {
+				tokens = append(tokens, models.APIToken{ID: record.ID, Name: record.Name, CreatedAt: record.CreatedAt})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// DeleteToken 吊销用户的API令牌，令牌不存在或属于其他用户时返回ErrTokenNotFound
+func (s *Store) DeleteToken(userID, tokenID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var record sessionRecord
+			if json.Unmarshal(v, &record) != nil {
+				continue
+			}
+			if record.UserID == userID && record.Kind == kindToken && record.ID == tokenID {
+				return c.Delete()
+			}
+		}
+		return ErrTokenNotFound
+	})
+}
+
+// DeleteSession 删除登录会话，用于退出登录
+func (s *Store) DeleteSession(token string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete(hashToken(token))
+	})
+}
+
+// UserByToken 根据会话令牌或API令牌获取用户，过期的会话会被删除
+func (s *Store) UserByToken(token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	key := hashToken(token)
+
+	var (
+		record  *userRecord
+		expired bool
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get(key)
+		if data == nil {
+			return ErrInvalidToken
+		}
+		var session sessionRecord
+		if err := json.Unmarshal(data, &session); err != nil {
+			return ErrInvalidToken
+		}
+		if !session.ExpiresAt.IsZero() && time.Now().After(session.ExpiresAt) {
+			expired = true
+			return ErrInvalidToken
+		}
+
+		var err error
+		record, err = getUser(tx, session.UserID)
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	})
+	if expired {
+		s.DeleteSession(token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record.User, nil
+}
+
+// createSession 生成随机令牌并保存其哈希
+func (s *Store) createSession(userID, kind, name string, expiresAt time.Time) (string, *sessionRecord, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("生成令牌失败: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	key := hashToken(token)
+
+	record := &sessionRecord{
+		// 令牌哈希的前缀用于展示和吊销
+		ID:        hex.EncodeToString(key)[:16],
+		UserID:    userID,
+		Kind:      kind,
+		Name:      name,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if _, err := getUser(tx, userID); err != nil {
+			return err
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(sessionsBucket).Put(key, data)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, record, nil
+}
+
+// getUser 读取用户信息
+func getUser(tx *bolt.Tx, id string) (*userRecord, error) {
+	data := tx.Bucket(usersBucket).Get([]byte(id))
+	if data == nil {
+		return nil, ErrUserNotFound
+	}
+	var record userRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("解析用户 %s 失败: %w", id, err)
+	}
+	return &record, nil
+}
+
+// checkNotLastAdmin 确认除当前管理员外还有其他管理员
+func checkNotLastAdmin(tx *bolt.Tx) error {
+	admins := 0
+	err := tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+		var record userRecord
+		if json.Unmarshal(v, &record) == nil && record.Role == models.RoleAdmin {
+			admins++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// deleteSessions 删除用户的会话，kind为空时删除所有会话和API令牌
+func deleteSessions(tx *bolt.Tx, userID, kind string) error {
+	b := tx.Bucket(sessionsBucket)
+
+	// 遍历时删除会导致游标跳过元素，先收集再删除
+	var keys [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		var record sessionRecord
+		if json.Unmarshal(v, &record) == nil && record.UserID == userID && (kind == "" || record.Kind == kind) {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashToken 计算令牌的SHA-256，数据库中只保存哈希
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// putJSON 以JSON格式保存数据
+func putJSON(b *bolt.Bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("编码数据失败: %w", err)
+	}
+	return b.Put([]byte(key), data)
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"web_music/models"
+)
+
+func TestDeleteToken(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	alice, err := s.CreateUser("alice", "password", models.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := s.CreateUser("bob", "password", models.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, info, err := s.CreateToken(alice.ID, "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		userID  string
+		tokenID string
+		want    error
+	}{
+		{"不存在的令牌", alice.ID, "missing", ErrTokenNotFound},
+		{"其他用户的令牌", bob.ID, info.ID, ErrTokenNotFound},
+		{"吊销自己的令牌", alice.ID, info.ID, nil},
+		{"重复吊销", alice.ID, info.ID, ErrTokenNotFound},
+	}
+	for _, tt := range tests {
+		if err := s.DeleteToken(tt.userID, tt.tokenID); !errors.Is(err, tt.want) {
+			t.Errorf("%s: DeleteToken() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := s.UserByToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("吊销后 UserByToken() = %v, want %v", err, ErrInvalidToken)
+	}
+}
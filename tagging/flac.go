@@ -0,0 +1,140 @@
+package tagging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"web_music/lyrics"
+)
+
+// FLAC元数据块类型，参考 https://xiph.org/flac/format.html#metadata_block
+const (
+	flacBlockStreamInfo    = 0
+	flacBlockPadding       = 1
+	flacBlockVorbisComment = 4
+	flacBlockPicture       = 6
+)
+
+// 原文件元数据的大小上限，超过时认为文件损坏
+const maxFLACMetadataSize = 16 << 20
+
+// 写入Vorbis注释的编码器名称
+const vorbisVendor = "web_music"
+
+// flacBlock FLAC元数据块
+type flacBlock struct {
+	typ  byte
+	data []byte
+}
+
+// rewriteFLACHeader 读取FLAC文件头和全部元数据块，替换其中的注释，获取到封面时同时替换图片
+// 返回新的文件头以及从br中读取的字节数，br停在第一个音频帧
+func rewriteFLACHeader(br *bufio.Reader, meta *Metadata) ([]byte, int64, error) {
+	if _, err := br.Discard(4); err != nil {
+		return nil, 0, ErrInvalidAudio
+	}
+	consumed := int64(4)
+
+	var blocks, pictures []flacBlock
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return nil, 0, ErrInvalidAudio
+		}
+		last := header[0]&0x80 != 0
+		typ := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		consumed += 4 + length
+		if consumed > maxFLACMetadataSize {
+			return nil, 0, ErrInvalidAudio
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, 0, ErrInvalidAudio
+		}
+
+		// 丢弃原有的注释和填充，图片在没有新封面时保留
+		switch typ {
+		case flacBlockVorbisComment, flacBlockPadding:
+		case flacBlockPicture:
+			pictures = append(pictures, flacBlock{typ, data})
+		default:
+			blocks = append(blocks, flacBlock{typ, data})
+		}
+		if last {
+			break
+		}
+	}
+	if len(blocks) == 0 || blocks[0].typ != flacBlockStreamInfo {
+		return nil, 0, ErrInvalidAudio
+	}
+
+	blocks = append(blocks, flacBlock{flacBlockVorbisComment, vorbisComment(meta)})
+	if len(meta.Cover) > 0 {
+		blocks = append(blocks, flacBlock{flacBlockPicture, flacPicture(meta)})
+	} else {
+		blocks = append(blocks, pictures...)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("fLaC")
+	for i, block := range blocks {
+		typ := block.typ
+		if i == len(blocks)-1 {
+			typ |= 0x80
+		}
+		n := len(block.data)
+		out.Write([]byte{typ, byte(n >> 16), byte(n >> 8), byte(n)})
+		out.Write(block.data)
+	}
+	return out.Bytes(), consumed, nil
+}
+
+// vorbisComment 生成Vorbis注释块，长度使用小端序
+func vorbisComment(meta *Metadata) []byte {
+	var comments []string
+	for _, field := range []struct{ key, value string }{
+		{"TITLE", meta.Title},
+		{"ARTIST", meta.Artist},
+		{"ALBUM", meta.Album},
+	} {
+		if field.value != "" {
+			comments = append(comments, field.key+"="+field.value)
+		}
+	}
+	if len(meta.Lyrics) > 0 {
+		// 大多数播放器可以识别LYRICS字段中的LRC歌词
+		var lrc bytes.Buffer
+		for _, line := range meta.Lyrics {
+			lrc.WriteString(lyrics.FormatTime(line.Time) + line.Text + "\n")
+		}
+		comments = append(comments, "LYRICS="+lrc.String())
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(len(vorbisVendor)))
+	buf.WriteString(vorbisVendor)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(comment)))
+		buf.WriteString(comment)
+	}
+	return buf.Bytes()
+}
+
+// flacPicture 生成封面图片块，整数使用大端序，宽高等未知时为0
+func flacPicture(meta *Metadata) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(3)) // 3为封面
+	binary.Write(&buf, binary.BigEndian, uint32(len(meta.CoverMIME)))
+	buf.WriteString(meta.CoverMIME)
+	binary.Write(&buf, binary.BigEndian, uint32(0))   // 描述
+	binary.Write(&buf, binary.BigEndian, [4]uint32{}) // 宽、高、色深、索引色数
+	binary.Write(&buf, binary.BigEndian, uint32(len(meta.Cover)))
+	buf.Write(meta.Cover)
+	return buf.Bytes()
+}
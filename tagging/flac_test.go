@@ -0,0 +1,210 @@
+package tagging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"web_music/models"
+)
+
+// flacFile 生成包含指定元数据块和音频数据的FLAC文件
+func flacFile(blocks []flacBlock, audio string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("fLaC")
+	for i, block := range blocks {
+		typ := block.typ
+		if i == len(blocks)-1 {
+			typ |= 0x80
+		}
+		n := len(block.data)
+		buf.Write([]byte{typ, byte(n >> 16), byte(n >> 8), byte(n)})
+		buf.Write(block.data)
+	}
+	buf.WriteString(audio)
+	return buf.Bytes()
+}
+
+// parseFLACBlocks 解析FLAC文件头中的元数据块，返回元数据块和之后的数据
+func parseFLACBlocks(t *testing.T, data []byte) ([]flacBlock, []byte) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("fLaC")) {
+		t.Fatalf("不是FLAC文件: % x", data[:min(len(data), 4)])
+	}
+	data = data[4:]
+
+	var blocks []flacBlock
+	for {
+		if len(data) < 4 {
+			t.Fatal("元数据块头不完整")
+		}
+		last := data[0]&0x80 != 0
+		n := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+		if len(data) < 4+n {
+			t.Fatalf("元数据块大小 %d 超出文件", n)
+		}
+		blocks = append(blocks, flacBlock{data[0] & 0x7F, data[4 : 4+n]})
+		data = data[4+n:]
+		if last {
+			return blocks, data
+		}
+	}
+}
+
+// parseVorbisComment 解析Vorbis注释块，返回编码器名称和注释
+func parseVorbisComment(t *testing.T, data []byte) (string, []string) {
+	t.Helper()
+	read := func() string {
+		if len(data) < 4 {
+			t.Fatal("Vorbis注释不完整")
+		}
+		n := int(binary.LittleEndian.Uint32(data))
+		if len(data) < 4+n {
+			t.Fatal("Vorbis注释长度超出元数据块")
+		}
+		s := string(data[4 : 4+n])
+		data = data[4+n:]
+		return s
+	}
+
+	vendor := read()
+	if len(data) < 4 {
+		t.Fatal("缺少注释数量")
+	}
+	count := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+	comments := make([]string, count)
+	for i := range comments {
+		comments[i] = read()
+	}
+	if len(data) != 0 {
+		t.Errorf("Vorbis注释末尾多出 %d 字节", len(data))
+	}
+	return vendor, comments
+}
+
+func TestRewriteFLACHeader(t *testing.T) {
+	streamInfo := flacBlock{flacBlockStreamInfo, bytes.Repeat([]byte{1}, 34)}
+	seekTable := flacBlock{3, bytes.Repeat([]byte{2}, 18)}
+	src := flacFile([]flacBlock{
+		streamInfo,
+		{flacBlockVorbisComment, vorbisComment(&Metadata{Title: "旧标题"})},
+		seekTable,
+		{flacBlockPicture, flacPicture(&Metadata{Cover: []byte("old"), CoverMIME: "image/png"})},
+		{flacBlockPadding, make([]byte, 1024)},
+	}, "audio")
+
+	meta := &Metadata{
+		Title:     "Title",
+		Artist:    "Artist",
+		Album:     "Album",
+		Cover:     []byte("cover"),
+		CoverMIME: "image/jpeg",
+		Lyrics:    []models.LyricLine{{Time: 1500, Text: "第一句"}, {Time: 62000, Text: "第二句"}},
+	}
+	br := bufio.NewReader(bytes.NewReader(src))
+	header, consumed, err := rewriteFLACHeader(br, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consumed != int64(len(src)-len("audio")) {
+		t.Errorf("consumed = %d, want %d", consumed, len(src)-len("audio"))
+	}
+	if rest, _ := br.Peek(5); string(rest) != "audio" {
+		t.Errorf("读取文件头后为 %q", rest)
+	}
+
+	blocks, rest := parseFLACBlocks(t, header)
+	if len(rest) != 0 {
+		t.Errorf("文件头末尾多出 %d 字节", len(rest))
+	}
+	wantTypes := []byte{flacBlockStreamInfo, 3, flacBlockVorbisComment, flacBlockPicture}
+	if len(blocks) != len(wantTypes) {
+		t.Fatalf("元数据块数量 = %d, want %d", len(blocks), len(wantTypes))
+	}
+	for i, typ := range wantTypes {
+		if blocks[i].typ != typ {
+			t.Errorf("第%d个元数据块类型 = %d, want %d", i, blocks[i].typ, typ)
+		}
+	}
+	if !bytes.Equal(blocks[0].data, streamInfo.data) || !bytes.Equal(blocks[1].data, seekTable.data) {
+		t.Error("原有的STREAMINFO或SEEKTABLE被修改")
+	}
+
+	vendor, comments := parseVorbisComment(t, blocks[2].data)
+	if vendor != vorbisVendor {
+		t.Errorf("vendor = %q, want %q", vendor, vorbisVendor)
+	}
+	wantComments := []string{
+		"TITLE=Title",
+		"ARTIST=Artist",
+		"ALBUM=Album",
+		"LYRICS=[00:01.50]第一句\n[01:02.00]第二句\n",
+	}
+	if len(comments) != len(wantComments) {
+		t.Fatalf("comments = %q, want %q", comments, wantComments)
+	}
+	for i := range wantComments {
+		if comments[i] != wantComments[i] {
+			t.Errorf("comments[%d] = %q, want %q", i, comments[i], wantComments[i])
+		}
+	}
+
+	picture := blocks[3].data
+	if binary.BigEndian.Uint32(picture) != 3 {
+		t.Errorf("图片类型 = %d, want 3", binary.BigEndian.Uint32(picture))
+	}
+	if !bytes.Contains(picture, []byte("image/jpeg")) || !bytes.HasSuffix(picture, []byte("\x00\x00\x00\x05cover")) {
+		t.Errorf("图片块 = %q", picture)
+	}
+}
+
+func TestRewriteFLACHeaderKeepsPictures(t *testing.T) {
+	streamInfo := flacBlock{flacBlockStreamInfo, bytes.Repeat([]byte{1}, 34)}
+	front := flacBlock{flacBlockPicture, flacPicture(&Metadata{Cover: []byte("front"), CoverMIME: "image/png"})}
+	back := flacBlock{flacBlockPicture, []byte("back")}
+	src := flacFile([]flacBlock{streamInfo, front, {flacBlockVorbisComment, vorbisComment(&Metadata{})}, back}, "audio")
+
+	// 没有获取到封面时保留原有的全部图片
+	header, _, err := rewriteFLACHeader(bufio.NewReader(bytes.NewReader(src)), &Metadata{Title: "Title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, _ := parseFLACBlocks(t, header)
+	wantTypes := []byte{flacBlockStreamInfo, flacBlockVorbisComment, flacBlockPicture, flacBlockPicture}
+	if len(blocks) != len(wantTypes) {
+		t.Fatalf("元数据块数量 = %d, want %d", len(blocks), len(wantTypes))
+	}
+	for i, typ := range wantTypes {
+		if blocks[i].typ != typ {
+			t.Errorf("第%d个元数据块类型 = %d, want %d", i, blocks[i].typ, typ)
+		}
+	}
+	if !bytes.Equal(blocks[2].data, front.data) || !bytes.Equal(blocks[3].data, back.data) {
+		t.Error("原有的图片被修改")
+	}
+}
+
+func TestRewriteFLACHeaderInvalid(t *testing.T) {
+	streamInfo := flacBlock{flacBlockStreamInfo, make([]byte, 34)}
+	valid := flacFile([]flacBlock{streamInfo}, "")
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"只有文件头", []byte("fLaC")},
+		{"元数据块不完整", valid[:len(valid)-1]},
+		{"第一个块不是STREAMINFO", flacFile([]flacBlock{{3, make([]byte, 18)}, streamInfo}, "")},
+		{"元数据过大", []byte("fLaC\x80\xFF\xFF\xFF")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br := bufio.NewReader(bytes.NewReader(tt.data))
+			if _, _, err := rewriteFLACHeader(br, &Metadata{}); err != ErrInvalidAudio {
+				t.Errorf("rewriteFLACHeader() error = %v, want %v", err, ErrInvalidAudio)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package tagging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+)
+
+// ID3v2.4的文本编码，3表示UTF-8
+const id3EncodingUTF8 = 3
+
+// 歌词的语言代码，ISO-639-2中的und表示未指定
+const id3Language = "und"
+
+// buildID3v2 生成ID3v2.4标签，参考 https://id3.org/id3v2.4.0-structure
+func buildID3v2(meta *Metadata) []byte {
+	var frames bytes.Buffer
+	writeTextFrame(&frames, "TIT2", meta.Title)
+	writeTextFrame(&frames, "TPE1", meta.Artist)
+	writeTextFrame(&frames, "TALB", meta.Album)
+
+	if len(meta.Cover) > 0 {
+		// 编码、MIME类型、图片类型(3为封面)、描述、图片数据
+		var apic bytes.Buffer
+		apic.WriteByte(id3EncodingUTF8)
+		apic.WriteString(meta.CoverMIME)
+		apic.WriteByte(0)
+		apic.WriteByte(3)
+		apic.WriteByte(0)
+		apic.Write(meta.Cover)
+		writeFrame(&frames, "APIC", apic.Bytes())
+	}
+
+	if len(meta.Lyrics) > 0 {
+		// 不带时间戳的歌词，不支持SYLT的播放器也能显示
+		var uslt bytes.Buffer
+		uslt.WriteByte(id3EncodingUTF8)
+		uslt.WriteString(id3Language)
+		uslt.WriteByte(0)
+		uslt.WriteString(lyricsText(meta.Lyrics))
+		writeFrame(&frames, "USLT", uslt.Bytes())
+
+		// 带时间戳的歌词：编码、语言、时间格式(2为毫秒)、内容类型(1为歌词)、描述，之后是文本和时间
+		var sylt bytes.Buffer
+		sylt.WriteByte(id3EncodingUTF8)
+		sylt.WriteString(id3Language)
+		sylt.WriteByte(2)
+		sylt.WriteByte(1)
+		sylt.WriteByte(0)
+		for _, line := range meta.Lyrics {
+			sylt.WriteString(line.Text)
+			sylt.WriteByte(0)
+			binary.Write(&sylt, binary.BigEndian, uint32(max(line.Time, 0)))
+		}
+		writeFrame(&frames, "SYLT", sylt.Bytes())
+	}
+
+	header := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 0}
+	putSyncsafe(header[6:], frames.Len())
+	return append(header, frames.Bytes()...)
+}
+
+// writeTextFrame 写入UTF-8文本帧，内容为空时不写
+func writeTextFrame(buf *bytes.Buffer, id, text string) {
+	if text == "" {
+		return
+	}
+	writeFrame(buf, id, append([]byte{id3EncodingUTF8}, text...))
+}
+
+// writeFrame 写入一个ID3v2.4帧，帧大小使用同步安全整数
+func writeFrame(buf *bytes.Buffer, id string, data []byte) {
+	header := make([]byte, 10)
+	copy(header, id)
+	putSyncsafe(header[4:8], len(data))
+	buf.Write(header)
+	buf.Write(data)
+}
+
+// putSyncsafe 写入同步安全整数，每个字节只使用低7位
+func putSyncsafe(b []byte, n int) {
+	b[0] = byte(n >> 21 & 0x7F)
+	b[1] = byte(n >> 14 & 0x7F)
+	b[2] = byte(n >> 7 & 0x7F)
+	b[3] = byte(n & 0x7F)
+}
+
+// skipID3v2 跳过开头已有的ID3v2标签，返回跳过的字节数
+func skipID3v2(br *bufio.Reader) (int64, error) {
+	header, err := br.Peek(10)
+	if err != nil || !bytes.Equal(header[:3], []byte("ID3")) {
+		// 文件太短时交给后续步骤原样输出
+		return 0, nil
+	}
+
+	size := int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 | int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F)
+	size += 10
+	// 有footer时标签后还有10字节
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+
+	if _, err := br.Discard(int(size)); err != nil {
+		return 0, ErrInvalidAudio
+	}
+	return size, nil
+}
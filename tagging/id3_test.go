@@ -0,0 +1,137 @@
+package tagging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"web_music/models"
+)
+
+// parseID3v2 解析测试生成的ID3v2.4标签，返回帧ID和内容
+func parseID3v2(t *testing.T, tag []byte) map[string][]byte {
+	t.Helper()
+	if len(tag) < 10 || string(tag[:3]) != "ID3" || tag[3] != 4 {
+		t.Fatalf("不是ID3v2.4标签: % x", tag[:min(len(tag), 10)])
+	}
+	size := syncsafe(tag[6:10])
+	if size != len(tag)-10 {
+		t.Fatalf("标签大小为 %d，实际为 %d", size, len(tag)-10)
+	}
+
+	frames := make(map[string][]byte)
+	for data := tag[10:]; len(data) > 0; {
+		if len(data) < 10 {
+			t.Fatalf("帧头不完整: % x", data)
+		}
+		id, n := string(data[:4]), syncsafe(data[4:8])
+		if len(data) < 10+n {
+			t.Fatalf("帧 %s 的大小 %d 超出标签", id, n)
+		}
+		frames[id] = data[10 : 10+n]
+		data = data[10+n:]
+	}
+	return frames
+}
+
+// syncsafe 读取同步安全整数
+func syncsafe(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+func TestBuildID3v2(t *testing.T) {
+	meta := &Metadata{
+		Title:     "晴天",
+		Artist:    "周杰伦",
+		Album:     "叶惠美",
+		Cover:     bytes.Repeat([]byte{0xAB}, 300), // 超过127字节，检查同步安全整数
+		CoverMIME: "image/jpeg",
+		Lyrics: []models.LyricLine{
+			{Time: 0, Text: "故事的小黄花"},
+			{Time: 29300, Text: "从出生那年就飘着"},
+		},
+	}
+	frames := parseID3v2(t, buildID3v2(meta))
+
+	texts := map[string]string{"TIT2": meta.Title, "TPE1": meta.Artist, "TALB": meta.Album}
+	for id, want := range texts {
+		frame, ok := frames[id]
+		if !ok {
+			t.Errorf("缺少帧 %s", id)
+			continue
+		}
+		if frame[0] != id3EncodingUTF8 || string(frame[1:]) != want {
+			t.Errorf("帧 %s = %q, want %q", id, frame, want)
+		}
+	}
+
+	wantAPIC := append([]byte("\x03image/jpeg\x00\x03\x00"), meta.Cover...)
+	if !bytes.Equal(frames["APIC"], wantAPIC) {
+		t.Errorf("APIC = % x", frames["APIC"])
+	}
+
+	if want := "\x03und\x00故事的小黄花\n从出生那年就飘着\n"; string(frames["USLT"]) != want {
+		t.Errorf("USLT = %q, want %q", frames["USLT"], want)
+	}
+
+	sylt := frames["SYLT"]
+	if !bytes.HasPrefix(sylt, []byte("\x03und\x02\x01\x00")) {
+		t.Fatalf("SYLT头 = % x", sylt[:min(len(sylt), 7)])
+	}
+	sylt = sylt[7:]
+	for _, line := range meta.Lyrics {
+		i := bytes.IndexByte(sylt, 0)
+		if i < 0 || len(sylt) < i+5 {
+			t.Fatalf("SYLT中缺少歌词 %q", line.Text)
+		}
+		if text, ms := string(sylt[:i]), binary.BigEndian.Uint32(sylt[i+1:i+5]); text != line.Text || int(ms) != line.Time {
+			t.Errorf("SYLT = (%q, %d), want (%q, %d)", text, ms, line.Text, line.Time)
+		}
+		sylt = sylt[i+5:]
+	}
+	if len(sylt) != 0 {
+		t.Errorf("SYLT末尾多出 %d 字节", len(sylt))
+	}
+}
+
+func TestBuildID3v2Empty(t *testing.T) {
+	frames := parseID3v2(t, buildID3v2(&Metadata{}))
+	if len(frames) != 0 {
+		t.Errorf("空标签包含帧 %v", frames)
+	}
+}
+
+func TestSkipID3v2(t *testing.T) {
+	tag := buildID3v2(&Metadata{Title: "test"})
+	withFooter := append([]byte(nil), tag...)
+	withFooter[5] |= 0x10
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    int64
+		wantErr error
+	}{
+		{"没有标签", []byte("\xFF\xFBaudio"), 0, nil},
+		{"太短", []byte("ID3"), 0, nil},
+		{"有标签", append(append([]byte(nil), tag...), "audio"...), int64(len(tag)), nil},
+		{"有footer", append(withFooter, strings.Repeat("F", 10)+"audio"...), int64(len(tag) + 10), nil},
+		{"标签不完整", tag[:len(tag)-1], 0, ErrInvalidAudio},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br := bufio.NewReader(bytes.NewReader(tt.data))
+			got, err := skipID3v2(br)
+			if err != tt.wantErr || got != tt.want {
+				t.Fatalf("skipID3v2() = %d, %v, want %d, %v", got, err, tt.want, tt.wantErr)
+			}
+			if err == nil && got > 0 {
+				if rest, _ := br.Peek(5); string(rest) != "audio" {
+					t.Errorf("跳过标签后为 %q", rest)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package tagging
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"web_music/models"
+)
+
+// 支持写入标签的音频格式
+const (
+	FormatMP3  = "mp3"
+	FormatFLAC = "flac"
+)
+
+// 定义错误
+var (
+	ErrInvalidAudio      = errors.New("无法解析音频文件头")
+	ErrUnsupportedFormat = errors.New("不支持的音频格式")
+)
+
+// Metadata 定义写入音频文件的标签
+type Metadata struct {
+	Title  string
+	Artist string
+	Album  string
+
+	// 封面图片及其MIME类型，如 image/jpeg
+	Cover     []byte
+	CoverMIME string
+
+	// 带时间戳的歌词，MP3写入SYLT和USLT帧，FLAC写入LRC格式的LYRICS字段
+	Lyrics []models.LyricLine
+}
+
+// Apply 读取音频数据的开头，返回替换为新标签后的音频数据
+// format为识别出的格式，不支持的格式原样返回且format为空
+// 带ID3标签但之后既不是MP3也不是FLAC时返回ErrUnsupportedFormat
+// sizeDelta为输出比输入多出的字节数，用于计算Content-Length
+func Apply(src io.Reader, meta *Metadata) (out io.Reader, format string, sizeDelta int64, err error) {
+	br := bufio.NewReaderSize(src, 64<<10)
+
+	// 去掉已有的ID3v2标签，部分FLAC文件前面也有ID3标签
+	removed, err := skipID3v2(br)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.Equal(magic, []byte("fLaC")):
+		header, consumed, err := rewriteFLACHeader(br, meta)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		delta := int64(len(header)) - consumed - removed
+		return io.MultiReader(bytes.NewReader(header), br), FormatFLAC, delta, nil
+
+	case isMPEGFrame(magic):
+		tag := buildID3v2(meta)
+		delta := int64(len(tag)) - removed
+		return io.MultiReader(bytes.NewReader(tag), br), FormatMP3, delta, nil
+
+	case removed > 0:
+		return nil, "", 0, ErrUnsupportedFormat
+
+	default:
+		// 无法识别的格式(如m4a)保持原样，已读取的数据仍在br中
+		return br, "", 0, nil
+	}
+}
+
+// isMPEGFrame 判断是否为MPEG音频帧的同步字
+func isMPEGFrame(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0xFF && b[1]&0xE0 == 0xE0
+}
+
+// lyricsText 返回不带时间戳的歌词文本
+func lyricsText(lines []models.LyricLine) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(line.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
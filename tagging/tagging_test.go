@@ -0,0 +1,92 @@
+package tagging
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	meta := &Metadata{Title: "Title", Artist: "Artist"}
+	oldTag := buildID3v2(&Metadata{Title: "Old title", Album: "Old album"})
+	mpegAudio := "\xFF\xFB\x90\x64audio"
+	flac := flacFile([]flacBlock{{flacBlockStreamInfo, make([]byte, 34)}}, "audio")
+
+	tests := []struct {
+		name       string
+		src        []byte
+		wantFormat string
+		wantErr    error
+		wantAudio  string // 输出结尾应为原音频数据
+	}{
+		{"MP3", []byte(mpegAudio), FormatMP3, nil, mpegAudio},
+		{"带ID3标签的MP3", append(append([]byte(nil), oldTag...), mpegAudio...), FormatMP3, nil, mpegAudio},
+		{"FLAC", flac, FormatFLAC, nil, "audio"},
+		{"带ID3标签的FLAC", append(append([]byte(nil), oldTag...), flac...), FormatFLAC, nil, "audio"},
+		{"无法识别的格式原样输出", []byte("\x00\x00\x00\x20ftypM4A "), "", nil, "\x00\x00\x00\x20ftypM4A "},
+		{"空文件", nil, "", nil, ""},
+		{"带ID3标签的其他格式", append(append([]byte(nil), oldTag...), "\x00\x00\x00\x20ftypM4A "...), "", ErrUnsupportedFormat, ""},
+		{"损坏的FLAC", []byte("fLaC\x00\x00"), "", ErrInvalidAudio, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, format, delta, err := Apply(bytes.NewReader(tt.src), meta)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if format != tt.wantFormat {
+				t.Errorf("format = %q, want %q", format, tt.wantFormat)
+			}
+
+			data, err := io.ReadAll(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := int64(len(data) - len(tt.src)); got != delta {
+				t.Errorf("sizeDelta = %d, 实际为 %d", delta, got)
+			}
+			if !bytes.HasSuffix(data, []byte(tt.wantAudio)) {
+				t.Errorf("输出结尾 = %q, want %q", data[max(len(data)-len(tt.wantAudio), 0):], tt.wantAudio)
+			}
+
+			switch format {
+			case FormatMP3:
+				frames := parseID3v2(t, data[:len(data)-len(tt.wantAudio)])
+				if string(frames["TIT2"][1:]) != "Title" || frames["TALB"] != nil {
+					t.Errorf("标签没有被替换: %q", frames)
+				}
+			case FormatFLAC:
+				blocks, rest := parseFLACBlocks(t, data)
+				if string(rest) != tt.wantAudio {
+					t.Errorf("文件头之后为 %q", rest)
+				}
+				_, comments := parseVorbisComment(t, blocks[len(blocks)-1].data)
+				if len(comments) != 2 || comments[0] != "TITLE=Title" {
+					t.Errorf("comments = %q", comments)
+				}
+			}
+		})
+	}
+}
+
+func TestIsMPEGFrame(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want bool
+	}{
+		{[]byte{0xFF, 0xFB}, true},
+		{[]byte{0xFF, 0xE3}, true},
+		{[]byte{0xFF, 0xD0}, false},
+		{[]byte{0xFF}, false},
+		{[]byte("ID3"), false},
+	}
+	for _, tt := range tests {
+		if got := isMPEGFrame(tt.data); got != tt.want {
+			t.Errorf("isMPEGFrame(% x) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package urlcache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"web_music/api/providers"
+)
+
+// 缓存设置
+const (
+	resolveTimeout = 30 * time.Second // 共享的解析请求的超时，不随单个客户端断开而取消
+	expiryMargin   = 30 * time.Second // 提前视为过期，留出请求音频的时间
+	refreshAhead   = 2 * time.Minute  // 剩余有效期少于该值时在后台重新解析
+	sweepInterval  = time.Minute      // 清理过期地址的间隔
+)
+
+// Key 缓存键，同一首歌的不同音质分别缓存
+type Key struct {
+	Source  string
+	ID      string
+	Quality providers.Quality
+}
+
+// String 返回合并请求时使用的键
+func (k Key) String() string {
+	return k.Source + "\x00" + k.ID + "\x00" + string(k.Quality)
+}
+
+// ResolveFunc 向上游解析播放地址
+type ResolveFunc func(ctx context.Context, key Key) (*providers.SongURL, error)
+
+// TTLFunc 返回音乐源播放地址的默认有效期，上游没有给出过期时间时使用
+type TTLFunc func(source string) time.Duration
+
+// entry 缓存的播放地址
+type entry struct {
+	url        providers.SongURL
+	usable     time.Time // 超过该时间后不再使用
+	refreshAt  time.Time // 超过该时间后访问时在后台重新解析
+	refreshing bool
+}
+
+// Cache 播放地址缓存，同一首歌同时只会向上游发出一个解析请求
+type Cache struct {
+	resolve ResolveFunc
+	ttl     TTLFunc
+	group   singleflight.Group
+
+	mu        sync.Mutex
+	entries   map[Key]*entry
+	lastSweep time.Time
+}
+
+// New 创建播放地址缓存
+func New(resolve ResolveFunc, ttl TTLFunc) *Cache {
+	return &Cache{
+		resolve:   resolve,
+		ttl:       ttl,
+		entries:   make(map[Key]*entry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Get 返回未过期的播放地址，没有时向上游解析
+// 地址即将过期时仍返回当前地址，同时在后台重新解析
+func (c *Cache) Get(ctx context.Context, key Key) (*providers.SongURL, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && now.Before(e.usable) {
+		songURL := e.url
+		refresh := !e.refreshing && !now.Before(e.refreshAt)
+		if refresh {
+			e.refreshing = true
+		}
+		c.mu.Unlock()
+
+		if refresh {
+			go c.refresh(key)
+		}
+		return &songURL, nil
+	}
+	c.mu.Unlock()
+
+	return c.load(ctx, key)
+}
+
+// Invalidate 删除缓存的播放地址，上游拒绝该地址时调用
+func (c *Cache) Invalidate(key Key) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+	c.group.Forget(key.String())
+}
+
+// load 向上游解析播放地址，同一个键的并发请求合并为一次
+func (c *Cache) load(ctx context.Context, key Key) (*providers.SongURL, error) {
+	ch := c.group.DoChan(key.String(), func() (val interface{}, err error) {
+		// DoChan在单独的协程中解析，panic无法被调用者恢复，转换为错误返回
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("解析 %s:%s 的播放地址时发生panic: %v\n%s", key.Source, key.ID, r, debug.Stack())
+				err = fmt.Errorf("解析播放地址失败: %v", r)
+			}
+		}()
+
+		// 其他请求可能在等待结果，不随发起者取消
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), resolveTimeout)
+		defer cancel()
+
+		songURL, err := c.resolve(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return c.store(key, songURL), nil
+	})
+
+	select {
+	case result := <-ch:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		songURL := result.Val.(providers.SongURL)
+		return &songURL, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// refresh 在后台重新解析即将过期的播放地址
+func (c *Cache) refresh(key Key) {
+	if _, err := c.load(context.Background(), key); err != nil {
+		log.Printf("刷新 %s:%s 的播放地址失败: %v", key.Source, key.ID, err)
+
+		c.mu.Lock()
+		if e, ok := c.entries[key]; ok {
+			e.refreshing = false
+		}
+		c.mu.Unlock()
+	}
+}
+
+// store 保存解析结果，上游没有给出过期时间时使用音乐源的默认有效期
+func (c *Cache) store(key Key, songURL *providers.SongURL) providers.SongURL {
+	now := time.Now()
+	stored := *songURL
+	if stored.Expires.IsZero() {
+		stored.Expires = now.Add(c.ttl(key.Source))
+	}
+
+	// 有效期很短时按比例缩小提前量
+	ttl := stored.Expires.Sub(now)
+	usable := stored.Expires.Add(-min(expiryMargin, ttl/10))
+	e := &entry{
+		url:       stored,
+		usable:    usable,
+		refreshAt: usable.Add(-min(refreshAhead, ttl/4)),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = e
+	if now.Sub(c.lastSweep) >= sweepInterval {
+		for k, old := range c.entries {
+			if !now.Before(old.usable) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	return stored
+}
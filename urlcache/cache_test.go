@@ -0,0 +1,178 @@
+package urlcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"web_music/api/providers"
+)
+
+var testKey = Key{Source: "qq", ID: "1", Quality: providers.QualityStandard}
+
+// fixedTTL 所有音乐源使用相同的默认有效期
+func fixedTTL(ttl time.Duration) TTLFunc {
+	return func(string) time.Duration { return ttl }
+}
+
+func TestGet(t *testing.T) {
+	var calls atomic.Int32
+	c := New(func(ctx context.Context, key Key) (*providers.SongURL, error) {
+		calls.Add(1)
+		return &providers.SongURL{URL: "http://example.com/1.mp3"}, nil
+	}, fixedTTL(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		songURL, err := c.Get(context.Background(), testKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if songURL.URL != "http://example.com/1.mp3" || songURL.Expires.IsZero() {
+			t.Errorf("Get() = %+v", songURL)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("解析次数 = %d, want 1", calls.Load())
+	}
+}
+
+func TestGetPanic(t *testing.T) {
+	c := New(func(ctx context.Context, key Key) (*providers.SongURL, error) {
+		var info map[string]interface{}
+		return &providers.SongURL{URL: info["purl"].(string)}, nil
+	}, fixedTTL(time.Hour))
+
+	if _, err := c.Get(context.Background(), testKey); err == nil {
+		t.Error("解析时panic应返回错误")
+	}
+}
+
+// counter 返回依次生成v1、v2…地址的解析函数，地址在ttl后过期
+func counter(calls *atomic.Int32, ttl time.Duration) ResolveFunc {
+	return func(ctx context.Context, key Key) (*providers.SongURL, error) {
+		n := calls.Add(1)
+		return &providers.SongURL{URL: fmt.Sprintf("v%d", n), Expires: time.Now().Add(ttl)}, nil
+	}
+}
+
+// waitFor 等待条件成立，超时后测试失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	var calls atomic.Int32
+	c := New(counter(&calls, 100*time.Millisecond), fixedTTL(time.Hour))
+
+	if songURL, _ := c.Get(context.Background(), testKey); songURL.URL != "v1" {
+		t.Fatalf("Get() = %q, want v1", songURL.URL)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if songURL, _ := c.Get(context.Background(), testKey); songURL.URL != "v2" {
+		t.Errorf("过期后 Get() = %q, want v2", songURL.URL)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	var calls atomic.Int32
+	// 有效期400ms时，360ms后不再使用，260ms后在后台重新解析
+	c := New(counter(&calls, 400*time.Millisecond), fixedTTL(time.Hour))
+
+	c.Get(context.Background(), testKey)
+	time.Sleep(300 * time.Millisecond)
+	if songURL, _ := c.Get(context.Background(), testKey); songURL.URL != "v1" {
+		t.Errorf("即将过期时 Get() = %q, want 仍返回 v1", songURL.URL)
+	}
+	waitFor(t, func() bool { return calls.Load() == 2 })
+	waitFor(t, func() bool {
+		songURL, _ := c.Get(context.Background(), testKey)
+		return songURL.URL == "v2"
+	})
+	if calls.Load() != 2 {
+		t.Errorf("解析次数 = %d, want 2", calls.Load())
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	var calls atomic.Int32
+	c := New(counter(&calls, time.Hour), fixedTTL(time.Hour))
+
+	c.Get(context.Background(), testKey)
+	c.Invalidate(testKey)
+	if songURL, _ := c.Get(context.Background(), testKey); songURL.URL != "v2" {
+		t.Errorf("删除后 Get() = %q, want v2", songURL.URL)
+	}
+
+	// 其他键不受影响
+	other := Key{Source: "qq", ID: "1", Quality: providers.QualityHigh}
+	c.Get(context.Background(), other)
+	c.Invalidate(testKey)
+	if songURL, _ := c.Get(context.Background(), other); songURL.URL != "v3" {
+		t.Errorf("其他键 Get() = %q, want v3", songURL.URL)
+	}
+}
+
+func TestConcurrentLoads(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	c := New(func(ctx context.Context, key Key) (*providers.SongURL, error) {
+		calls.Add(1)
+		<-release
+		return &providers.SongURL{URL: "v1"}, nil
+	}, fixedTTL(time.Hour))
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Get(context.Background(), testKey)
+			errs <- err
+		}()
+	}
+	waitFor(t, func() bool { return calls.Load() == 1 })
+	time.Sleep(50 * time.Millisecond) // 等待其他请求加入
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("解析次数 = %d, want 1", calls.Load())
+	}
+}
+
+func TestErrorsNotCached(t *testing.T) {
+	var calls atomic.Int32
+	c := New(func(ctx context.Context, key Key) (*providers.SongURL, error) {
+		if calls.Add(1) == 1 {
+			return nil, errors.New("上游错误")
+		}
+		return &providers.SongURL{URL: "v2"}, nil
+	}, fixedTTL(time.Hour))
+
+	if _, err := c.Get(context.Background(), testKey); err == nil {
+		t.Fatal("第一次 Get() 应返回错误")
+	}
+	songURL, err := c.Get(context.Background(), testKey)
+	if err != nil || songURL.URL != "v2" {
+		t.Errorf("第二次 Get() = %v, %v, want v2", songURL, err)
+	}
+}